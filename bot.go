@@ -1,24 +1,39 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"sync"
+	"time"
 
-	"log"
+	"net"
 	"net/http"
 
-	"io/ioutil"
-
-	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 
+	"log/slog"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 
+	"github.com/purnet/TicTacToeBot/config"
+	"github.com/purnet/TicTacToeBot/engine"
+	"github.com/purnet/TicTacToeBot/merknera"
 	"github.com/purnet/TicTacToeBot/models"
+	"github.com/purnet/TicTacToeBot/record"
+	"github.com/purnet/TicTacToeBot/render"
+	"github.com/purnet/TicTacToeBot/rpc"
+	"github.com/purnet/TicTacToeBot/session"
+	"github.com/purnet/TicTacToeBot/store"
 )
 
 type GameBot interface {
-	Register(game string, botName string, rpcendpoint string, botversion string, website string, description string) bool
+	Register(game string, botName string, rpcendpoint string, botversion string, website string, description string) (models.RegistrationResponse, error)
 	StatusPing(id int) []byte
 	Error(rpcReq models.ServerRpcRequest) []byte
 	ServeHTTP(rw http.ResponseWriter, req *http.Request)
@@ -28,39 +43,387 @@ type GameBot interface {
 	SetToken(token string)
 }
 
+var _ GameBot = (*TicTacToeBot)(nil)
+
 type TicTacToeBot struct {
-	baseUrl string
-	token   string
+	baseUrl      string
+	token        string
+	table        *engine.Table
+	book         *engine.Book
+	strategy     engine.Strategy
+	strategyName string
+	tieBreak     engine.TieBreak
+	analysis     bool
+	server       *rpc.Server
+	recoverer    *rpc.Recoverer
+	logger       *slog.Logger
+	serverOnce   sync.Once
+	seed         int64
+	moveBudget   time.Duration
+
+	clientOptions merknera.Options
+	registrar     *merknera.Registrar
+	sessions      *session.Store
+	sessionsOnce  sync.Once
+
+	rejectAnomalies bool
 }
 
-func (b *TicTacToeBot) StatusPing(id int) []byte {
-	s := models.StatusPingResponse{"OK"}
-	rpc := CreateRPCResponse(s, "", id)
-	return rpc
+// DefaultMoveBudget bounds the time spent choosing a move when neither
+// the bot's move budget nor the game server's deadline does.
+const DefaultMoveBudget = 2 * time.Second
+
+// deadlineMargin is reserved from a NextMove deadline for sending the
+// reply back to the game server.
+const deadlineMargin = 50 * time.Millisecond
+
+// NewTicTacToeBot returns a bot playing the minimax strategy, which
+// answers standard positions from the embedded book and whose searches
+// share a transposition table across all games it plays.
+func NewTicTacToeBot() *TicTacToeBot {
+	b := &TicTacToeBot{table: engine.NewTable(0), book: engine.StandardBook()}
+	b.strategy = engine.Minimax{Options: b.searchOptions()}
+	b.strategyName = b.strategy.Name()
+	return b
+}
+
+// searchOptions returns the search options shared by the bot's strategies.
+func (b *TicTacToeBot) searchOptions() engine.Options {
+	return engine.Options{Table: b.table, Book: b.book, TieBreak: b.tieBreak, Analyze: b.analysis}
 }
 
-func (b *TicTacToeBot) Register(game string, botName string, rpcendpoint string, botversion string, website string, description string) bool {
-	params := models.RegistrationParams{b.Token(), botName, botversion, game, rpcendpoint, "Go", website, description}
-	JsonRpcBody := CreateRPCRequest("RegistrationService.Register", params, 1)
-	fmt.Println(string(JsonRpcBody))
-	respBody, _, _ := b.RpcRequest(JsonRpcBody)
+// SetStrategy selects the built-in strategy, as named by
+// engine.NewStrategy, that chooses the bot's moves. Strategies that make
+// random choices are seeded with seed.
+func (b *TicTacToeBot) SetStrategy(name string, seed int64) error {
+	s, err := engine.NewStrategy(name, engine.StrategyOptions{Options: b.searchOptions(), Seed: seed})
+	if err != nil {
+		return err
+	}
+	b.strategy = s
+	b.strategyName = name
+	b.seed = seed
+	return nil
+}
 
-	var resp models.ServerRpcResponse
-	err := json.Unmarshal(respBody, &resp)
+// SetTieBreak selects the policy, as named by engine.NewTieBreak, that
+// chooses between equally good moves. Random policies are seeded with
+// seed. The bot's strategy is rebuilt to use the new policy.
+func (b *TicTacToeBot) SetTieBreak(name string, seed int64) error {
+	tb, err := engine.NewTieBreak(name, seed)
 	if err != nil {
-		fmt.Println(err)
+		return err
+	}
+	b.tieBreak = tb
+	if b.strategyName == "" {
+		return nil
+	}
+	return b.SetStrategy(b.strategyName, b.seed)
+}
+
+// SetAnalysis controls whether the bot explains its moves. When enabled,
+// searching strategies report every move's score and the expected line of
+// play, which the bot logs and includes in its NextMove replies.
+func (b *TicTacToeBot) SetAnalysis(enabled bool) {
+	b.analysis = enabled
+	if b.strategyName != "" {
+		// The strategy was built by SetStrategy, so its name is valid.
+		b.SetStrategy(b.strategyName, b.seed)
+	}
+}
+
+// gameStrategy returns the strategy for one move of a game. A difficulty
+// requested by the game server overrides the bot's own strategy, with
+// random choices seeded from the bot's seed, the game and the move number.
+func (b *TicTacToeBot) gameStrategy(params models.NextMoveParams, board engine.Board) (engine.Strategy, error) {
+	if params.Difficulty != "" {
+		level, err := engine.ParseDifficulty(params.Difficulty)
+		if err != nil {
+			return nil, err
+		}
+		moveNumber := board.Len() - len(board.LegalMoves())
+		seed := b.seed + int64(params.GameId)*1000 + int64(moveNumber)
+		opts := b.searchOptions()
+		if b.tieBreak != nil {
+			// A fresh policy keeps the game's moves independent of any
+			// other games the bot is playing.
+			opts.TieBreak, _ = engine.NewTieBreak(b.tieBreak.Name(), seed)
+		}
+		return engine.NewHandicap(level, opts, seed), nil
+	}
+	if b.strategy == nil {
+		return engine.Minimax{Options: b.searchOptions()}, nil
 	}
-	rr := models.RegistrationResponse{}
-	byteResult, e := json.Marshal(resp.Result)
-	if e != nil {
-		fmt.Println(e)
+	return b.strategy, nil
+}
+
+// TableStats reports how the shared transposition table has been used.
+// A bot without a table reports zero stats.
+func (b *TicTacToeBot) TableStats() engine.TableStats {
+	if b.table == nil {
+		return engine.TableStats{}
+	}
+	return b.table.Stats()
+}
+
+// Server returns the JSON-RPC server answering the bot's methods, built
+// on first use.
+func (b *TicTacToeBot) Server() *rpc.Server {
+	b.serverOnce.Do(func() {
+		s := rpc.NewServer()
+		b.recoverer = rpc.NewRecoverer(nil)
+		s.Use(func(next rpc.HandlerFunc) rpc.HandlerFunc {
+			return rpc.Logging(b.log())(next)
+		}, b.recoverer.Middleware)
+		rpc.Handle(s, "Status.Ping", func(ctx context.Context, _ json.RawMessage) (models.StatusPingResponse, error) {
+			return b.ping(), nil
+		})
+		rpc.Handle(s, "TicTacToe.NextMove", b.nextMove)
+		rpc.Handle(s, "TicTacToe.Error", func(ctx context.Context, params models.ErrorParams) (models.StatusResponseParams, error) {
+			return b.gameError(ctx, params), nil
+		})
+		rpc.Handle(s, "TicTacToe.Complete", func(ctx context.Context, params models.Complete) (models.StatusResponseParams, error) {
+			return b.complete(ctx, params)
+		})
+		b.server = s
+	})
+	return b.server
+}
+
+// SetLogger sets the logger the bot writes to. Bots without one use the
+// default logger.
+func (b *TicTacToeBot) SetLogger(logger *slog.Logger) {
+	b.logger = logger
+}
+
+func (b *TicTacToeBot) log() *slog.Logger {
+	if b.logger == nil {
+		return slog.Default()
+	}
+	return b.logger
+}
+
+// legacyContext annotates ctx with the logger rpc.Logging would give a
+// handler, for requests handed to the bot's Merknera-style methods
+// directly rather than through its server.
+func (b *TicTacToeBot) legacyContext(ctx context.Context, method string, id int) context.Context {
+	return rpc.WithLogger(ctx, b.log().With("method", method, "rpcid", strconv.Itoa(id)))
+}
+
+// Panics returns the number of panics in RPC handlers the bot recovered
+// from, answering the request with an internal error instead.
+func (b *TicTacToeBot) Panics() int64 {
+	b.Server()
+	return b.recoverer.Panics()
+}
+
+func (b *TicTacToeBot) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if b.registrar != nil {
+		b.registrar.Seen()
 	}
+	b.Server().ServeHTTP(rw, req)
+}
 
-	json.Unmarshal(byteResult, &rr)
-	fmt.Println("Response message: ", rr.Message)
+// BotStatus is the body of the status endpoint.
+type BotStatus struct {
+	Registration *merknera.Status `json:"registration,omitempty"`
+	Strategy     string           `json:"strategy"`
+	Panics       int64            `json:"panics"`
+}
 
-	return true
+// Status reports the bot's registration state and health.
+func (b *TicTacToeBot) Status() BotStatus {
+	status := BotStatus{Strategy: b.strategyName, Panics: b.Panics()}
+	if b.registrar != nil {
+		reg := b.registrar.Status()
+		status.Registration = &reg
+	}
+	return status
+}
 
+// Handler returns the bot's HTTP handler: the status endpoint at /status,
+// live game sessions at /sessions and /sessions/{gameid}, the latter also
+// as a game record or image with ?format=, and JSON-RPC everywhere else. The
+// status endpoint answers 503 while a registrar is set and the bot is not
+// registered.
+func (b *TicTacToeBot) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(rw http.ResponseWriter, req *http.Request) {
+		status := b.Status()
+		code := http.StatusOK
+		if status.Registration != nil && status.Registration.State != merknera.StateRegistered {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(rw, code, status)
+	})
+	mux.HandleFunc("/sessions", func(rw http.ResponseWriter, req *http.Request) {
+		writeJSON(rw, http.StatusOK, b.Sessions().Live())
+	})
+	mux.HandleFunc("/sessions/", func(rw http.ResponseWriter, req *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(req.URL.Path, "/sessions/"))
+		if err != nil {
+			http.Error(rw, "invalid game id", http.StatusBadRequest)
+			return
+		}
+		sess, ok := b.Sessions().Get(id)
+		if !ok {
+			http.Error(rw, "no live session for that game", http.StatusNotFound)
+			return
+		}
+		writeSession(rw, sess, req.URL.Query().Get("format"))
+	})
+	mux.Handle("/", b)
+	return mux
+}
+
+// writeSession writes a session in the given format: JSON by default, a
+// game record, or the latest position as an SVG or PNG image, or the
+// whole game as an animated GIF.
+func writeSession(rw http.ResponseWriter, sess session.Session, format string) {
+	var (
+		buf         bytes.Buffer
+		contentType string
+		err         error
+	)
+	switch format {
+	case "", "json":
+		writeJSON(rw, http.StatusOK, sess)
+		return
+	case "record", "gif":
+		var rec record.Record
+		rec, err = record.FromSession(sess)
+		if err != nil {
+			break
+		}
+		if format == "gif" {
+			contentType = "image/gif"
+			err = render.GIF(&buf, rec, render.Options{WinningLine: true})
+		} else {
+			contentType = "text/plain; charset=utf-8"
+			err = rec.Write(&buf, record.Algebraic)
+		}
+	case "svg", "png":
+		if len(sess.Turns) == 0 {
+			http.Error(rw, "the game has no moves yet", http.StatusConflict)
+			return
+		}
+		turn := sess.Turns[len(sess.Turns)-1]
+		size := engine.Size{Width: sess.Width, Height: sess.Height, K: sess.WinLength}
+		board := size.FromStrings(turn.Board)
+		var opts render.Options
+		if turn.Move >= 0 {
+			last := engine.Move(turn.Move)
+			board = board.Play(last, engine.Mark(sess.Mark))
+			opts.LastMove = &last
+		}
+		if format == "svg" {
+			contentType = "image/svg+xml"
+			err = render.SVG(&buf, board, opts)
+		} else {
+			contentType = "image/png"
+			err = render.PNG(&buf, board, opts)
+		}
+	default:
+		http.Error(rw, "format must be json, record, svg, png or gif", http.StatusBadRequest)
+		return
+	}
+	// Render fully first, so a game that cannot be shown gets an error
+	// status rather than a 200 and half a body.
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	rw.Header().Set("Content-Type", contentType)
+	buf.WriteTo(rw)
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(v)
+}
+
+// SetStrictRPC controls whether the bot rejects requests in the Merknera
+// wire format, speaking only JSON-RPC 2.0.
+func (b *TicTacToeBot) SetStrictRPC(strict bool) {
+	b.Server().SetStrict(strict)
+}
+
+// legacyParams returns the params of a request handed to one of the bot's
+// Merknera-style methods directly.
+func legacyParams(rpcReq models.ServerRpcRequest) json.RawMessage {
+	if rpcReq.Params == nil {
+		return nil
+	}
+	return *rpcReq.Params
+}
+
+func (b *TicTacToeBot) StatusPing(id int) []byte {
+	return CreateRPCResponse(b.ping(), "", id)
+}
+
+func (b *TicTacToeBot) ping() models.StatusPingResponse {
+	return models.StatusPingResponse{Ping: "OK"}
+}
+
+// Register registers the bot with the game server, returning the server's
+// reply. Transient failures are retried; see RegisterContext.
+func (b *TicTacToeBot) Register(game string, botName string, rpcendpoint string, botversion string, website string, description string) (models.RegistrationResponse, error) {
+	params := registrationParams(b.Token(), game, botName, rpcendpoint, botversion, website, description)
+	return b.RegisterContext(context.Background(), params)
+}
+
+// registrationParams returns the params registering a bot written in Go.
+func registrationParams(token, game, botName, rpcendpoint, botversion, website, description string) models.RegistrationParams {
+	return models.RegistrationParams{
+		Token:               token,
+		BotName:             botName,
+		BotVersion:          botversion,
+		Game:                game,
+		RpcEndPoint:         rpcendpoint,
+		ProgrammingLanguage: "Go",
+		Website:             website,
+		Description:         description,
+	}
+}
+
+// RegisterContext registers the bot with the game server, retrying
+// transient failures until ctx is done. Use a merknera.Registrar to retry
+// until the server accepts.
+func (b *TicTacToeBot) RegisterContext(ctx context.Context, params models.RegistrationParams) (models.RegistrationResponse, error) {
+	logger := b.log().With("method", "RegistrationService.Register")
+	body, _ := json.Marshal(params)
+	logger.DebugContext(ctx, "registering", "url", b.BaseUrl(), "params", rpc.SafeBody(body))
+	resp, err := b.Client().Register(ctx, params)
+	if err != nil {
+		logger.ErrorContext(ctx, "registration failed", "error", err)
+		return resp, err
+	}
+	logger.InfoContext(ctx, "registered", "message", resp.Message)
+	return resp, nil
+}
+
+// SetRegistrar sets the registrar keeping the bot registered. The bot
+// tells it when the game server is in touch or reports the bot unknown,
+// and reports its state on the status endpoint.
+func (b *TicTacToeBot) SetRegistrar(r *merknera.Registrar) {
+	b.registrar = r
+}
+
+// SetClientOptions configures the client used to call the game server.
+func (b *TicTacToeBot) SetClientOptions(opts merknera.Options) {
+	b.clientOptions = opts
+}
+
+// Client returns a client for the game server at BaseUrl, logging to the
+// bot's logger unless its options name another.
+func (b *TicTacToeBot) Client() *merknera.Client {
+	opts := b.clientOptions
+	if opts.Logger == nil {
+		opts.Logger = b.log()
+	}
+	return merknera.NewClient(b.BaseUrl(), opts)
 }
 
 func (b *TicTacToeBot) SetBaseUrl(baseUrl string) {
@@ -71,6 +434,13 @@ func (b *TicTacToeBot) SetToken(token string) {
 	b.token = token
 }
 
+// SetMoveBudget limits the time spent choosing each move. Zero means no
+// limit beyond the deadline the game server sends, or DefaultMoveBudget
+// for requests without one.
+func (b *TicTacToeBot) SetMoveBudget(budget time.Duration) {
+	b.moveBudget = budget
+}
+
 func (b *TicTacToeBot) BaseUrl() string {
 	return b.baseUrl
 }
@@ -81,255 +451,385 @@ func (b *TicTacToeBot) Token() string {
 
 func (b *TicTacToeBot) Error(rpcReq models.ServerRpcRequest) []byte {
 	params := models.ErrorParams{}
-	byteResult, e := json.Marshal(rpcReq.Params)
-	if e != nil {
-		fmt.Println(e)
+	if err := rpc.DecodeParams(legacyParams(rpcReq), &params); err != nil {
+		return CreateRPCResponse(nil, err.Error(), rpcReq.Id)
 	}
-	json.Unmarshal(byteResult, &params)
-	fmt.Printf("Game: %v encounted Error: %v: %s\n", params.GameId, params.ErrorCode, params.Message)
+	ctx := b.legacyContext(context.Background(), "TicTacToe.Error", rpcReq.Id)
+	return CreateRPCResponse(b.gameError(ctx, params), "", rpcReq.Id)
+}
 
-	s := models.StatusResponseParams{"OK"}
-	rpc := CreateRPCResponse(s, "", rpcReq.Id)
-	return rpc
+func (b *TicTacToeBot) gameError(ctx context.Context, params models.ErrorParams) models.StatusResponseParams {
+	rpc.Logger(ctx).WarnContext(ctx, "game server reported an error",
+		"gameid", params.GameId, "errorcode", params.ErrorCode, "message", params.Message)
+	b.Sessions().Error(params.GameId, params.ErrorCode, params.Message)
+	if b.registrar != nil && merknera.UnknownBot(params.Message) {
+		b.registrar.Reregister(params.Message)
+	}
+	return models.StatusResponseParams{Status: "OK"}
 }
 
 func CreateRPCRequest(method string, params interface{}, id int) []byte {
-	req := models.ClientRpcRequest{method, params, id}
+	req := models.ClientRpcRequest{Method: method, Params: params, Id: id}
 	JsonReqBody, _ := json.Marshal(req)
 	return JsonReqBody
 }
 
 func CreateRPCResponse(result interface{}, error string, id int) []byte {
-	resp := models.ClientRpcResponse{result, error, id}
+	resp := models.ClientRpcResponse{Result: result, Error: error, Id: id}
 	JsonRespBody, _ := json.Marshal(resp)
 	return JsonRespBody
 }
 
-func (b TicTacToeBot) RpcRequest(body []byte) ([]byte, string, int) {
-	client := &http.Client{}
-	fmt.Printf("sdf%s", b.BaseUrl())
-	req, err := http.NewRequest("POST", b.BaseUrl(), bytes.NewBuffer(body))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+func (b *TicTacToeBot) NextMove(rpcReq models.ServerRpcRequest) []byte {
+	return b.NextMoveContext(context.Background(), rpcReq)
+}
+
+// NextMoveContext is like NextMove but stops searching and replies with
+// the best move found so far when ctx is done, the bot's move budget runs
+// out or the deadline in the request params passes.
+func (b *TicTacToeBot) NextMoveContext(ctx context.Context, rpcReq models.ServerRpcRequest) []byte {
+	params := models.NextMoveParams{}
+	if err := rpc.DecodeParams(legacyParams(rpcReq), &params); err != nil {
+		return CreateRPCResponse(nil, err.Error(), rpcReq.Id)
+	}
+	pos, err := b.nextMove(b.legacyContext(ctx, "TicTacToe.NextMove", rpcReq.Id), params)
 	if err != nil {
-		log.Fatal(err)
-	}
-	defer resp.Body.Close()
-	respBody, err := ioutil.ReadAll(resp.Body)
-
-	return respBody, resp.Status, resp.StatusCode
-}
-
-func isGameOver(gs []string) (bool, string) {
-	switch {
-	case gs[0] != "" && gs[0] == gs[3] && gs[3] == gs[6]:
-		return true, gs[0]
-	case gs[0] != "" && gs[0] == gs[4] && gs[4] == gs[8]:
-		return true, gs[0]
-	case gs[1] != "" && gs[1] == gs[4] && gs[4] == gs[7]:
-		return true, gs[1]
-	case gs[2] != "" && gs[2] == gs[5] && gs[5] == gs[8]:
-		return true, gs[2]
-	case gs[2] != "" && gs[2] == gs[4] && gs[4] == gs[6]:
-		return true, gs[2]
-	case gs[0] != "" && gs[0] == gs[1] && gs[1] == gs[2]:
-		return true, gs[0]
-	case gs[3] != "" && gs[3] == gs[4] && gs[4] == gs[5]:
-		return true, gs[3]
-	case gs[6] != "" && gs[6] == gs[7] && gs[7] == gs[8]:
-		return true, gs[6]
-	default:
-		for _, s := range gs {
-			if s == "" {
-				return false, ""
-			}
-		}
-		return true, ""
-	}
-}
-
-func MiniMax(stateOfGame []string, player string, move int, turn string, level int) int {
-	sog := make([]string, 9, 9)
-	copy(sog, stateOfGame)
-	sog[move] = turn
-	gameOver, piece := isGameOver(sog)
-	if gameOver {
-		switch piece {
-		case "":
-			return 0
-		case player:
-			return 10 + level
-		default:
-			return -10 + level
-		}
-	} else {
-		var newTurn string
-		if turn == "X" {
-			newTurn = "O"
-		} else {
-			newTurn = "X"
+		return CreateRPCResponse(nil, err.Error(), rpcReq.Id)
+	}
+	return CreateRPCResponse(pos, "", rpcReq.Id)
+}
+
+func (b *TicTacToeBot) nextMove(ctx context.Context, params models.NextMoveParams) (models.NextMoveResponseParams, error) {
+	logger := rpc.Logger(ctx).With("gameid", params.GameId, "mark", params.Mark)
+	board, err := validateNextMove(params)
+	if err != nil {
+		logger.WarnContext(ctx, "rejected move request", "error", err)
+		return models.NextMoveResponseParams{}, err
+	}
+	logger.InfoContext(ctx, "choosing move", "board", formatBoard(board))
+	size := board.Size()
+	if b.rejectAnomalies {
+		// A rejected board is not recorded, so sending it again is compared
+		// with the same answered turn and rejected again.
+		inf := b.Sessions().Infer(params.GameId, params.Mark, size.Width, size.Height, size.K, params.GameState)
+		if len(inf.Anomalies) > 0 {
+			logger.WarnContext(ctx, "rejected board that is not a legal successor of the last one",
+				"anomalies", inf.Anomalies.String(), "board", formatBoard(board))
+			return models.NextMoveResponseParams{}, &models.RpcError{Code: models.BoardAnomaly, Message: "Board anomaly", Data: inf.Anomalies}
 		}
-		moves := make(map[int]int)
-		for i, s := range sog {
-			if s == "" {
-				moves[i] = MiniMax(sog, player, i, newTurn, level-1)
-			}
+	}
+	inf := b.Sessions().Received(params.GameId, params.Mark, size.Width, size.Height, size.K, params.GameState)
+	if move, ok := inf.OpponentMove(); ok {
+		logger.DebugContext(ctx, "opponent moved", "position", move)
+	}
+	if len(inf.Anomalies) > 0 {
+		logger.WarnContext(ctx, "board is not a legal successor of the last one",
+			"anomalies", inf.Anomalies.String(), "board", formatBoard(board))
+	}
+	if params.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, time.UnixMilli(params.Deadline).Add(-deadlineMargin))
+		defer cancel()
+	}
+	budget := b.moveBudget
+	if budget == 0 && params.Deadline <= 0 {
+		budget = DefaultMoveBudget
+	}
+	if budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
+	}
+	strategy, err := b.gameStrategy(params, board)
+	if err != nil {
+		logger.WarnContext(ctx, "rejected move request", "error", err)
+		return models.NextMoveResponseParams{}, rpc.InvalidParams(err)
+	}
+	decision, err := strategy.Choose(ctx, board, engine.Mark(params.Mark))
+	if err != nil {
+		logger.ErrorContext(ctx, "could not choose a move", "error", err)
+		if errors.Is(err, engine.ErrNoMoves) {
+			return models.NextMoveResponseParams{}, rpc.InvalidParams(err)
 		}
-		var bestScore int
-		scoreSet := false
-		for _, score := range moves {
-			if !scoreSet || (score > bestScore && newTurn == player) || (newTurn != player && score < bestScore) {
-				bestScore = score
-				scoreSet = true
+		return models.NextMoveResponseParams{}, err
+	}
+	myMove := decision.Move
+	b.Sessions().Moved(params.GameId, int(myMove))
+	logger.InfoContext(ctx, "chose move",
+		"position", int(myMove),
+		"strategy", strategy.Name(),
+		"diagnostics", decision.Diagnostics,
+	)
+	pos := models.NextMoveResponseParams{Position: int(myMove)}
+	if decision.Analysis != nil {
+		for _, m := range decision.Analysis.Moves {
+			if m.Move == myMove {
+				b.Sessions().Scored(params.GameId, m.Score, m.Outcome.String())
 			}
 		}
-		return bestScore
+		pos.Analysis = analysisParams(decision.Analysis)
+		logger.InfoContext(ctx, "move analysis",
+			"strategy", strategy.Name(),
+			"position", pos.Position,
+			"outcome", pos.Analysis.Outcome,
+			"score", pos.Analysis.Score,
+			"pv", pos.Analysis.PV,
+			"moves", pos.Analysis.Moves,
+			"nodes", pos.Analysis.Nodes,
+			"depth", pos.Analysis.Depth,
+			"complete", pos.Analysis.Complete,
+			"book", pos.Analysis.Book,
+		)
 	}
+	return pos, nil
 }
 
-type ChannelResult struct {
-	Position int
-	Score    int
+func (b *TicTacToeBot) Complete(rpcReq models.ServerRpcRequest) []byte {
+	params := models.Complete{}
+	if err := rpc.DecodeParams(legacyParams(rpcReq), &params); err != nil {
+		return CreateRPCResponse(nil, err.Error(), rpcReq.Id)
+	}
+	status, err := b.complete(b.legacyContext(context.Background(), "TicTacToe.Complete", rpcReq.Id), params)
+	if err != nil {
+		return CreateRPCResponse(nil, err.Error(), rpcReq.Id)
+	}
+	return CreateRPCResponse(status, "", rpcReq.Id)
 }
 
-func MakeBestMove(gameState []string, player string, gameId int) (pos int) {
-	availableMoves := make(map[int]int)
-	var wg sync.WaitGroup
-	ch := make(chan ChannelResult)
-	for i, s := range gameState {
-		if s == "" {
-			wg.Add(1)
-
-			go func(c chan ChannelResult, pos int, state []string) {
-				defer wg.Done()
-				score := MiniMax(state, player, pos, player, 0)
-				c <- ChannelResult{pos, score}
-			}(ch, i, gameState)
-		}
+func (b *TicTacToeBot) complete(ctx context.Context, params models.Complete) (models.StatusResponseParams, error) {
+	logger := rpc.Logger(ctx).With("gameid", params.GameId, "mark", params.Mark)
+	board, err := validateComplete(params)
+	if err != nil {
+		logger.WarnContext(ctx, "rejected game result", "error", err)
+		return models.StatusResponseParams{}, err
 	}
+	sess := b.Sessions().Complete(params.GameId, params.Mark, gameResult(board, params.Winner), params.GameState)
+	logger.InfoContext(ctx, "game complete",
+		"winner", params.Winner,
+		"result", sess.Result,
+		"board", formatBoard(board),
+		"turns", len(sess.Turns),
+		"duration", sess.Ended.Sub(sess.Started),
+	)
+	return models.StatusResponseParams{Status: "OK"}, nil
+}
 
-	go func() {
-		wg.Wait()
-		fmt.Println("Closing Channel")
-		close(ch)
-	}()
-
-	for c := range ch {
-		fmt.Printf("Channel read: %d - %d\n", c.Position, c.Score)
-		availableMoves[c.Position] = c.Score
+// gameResult names the result of a finished game for its session: a win
+// if we won, a loss if the board has another winner and otherwise a draw.
+func gameResult(board engine.Board, winner bool) string {
+	if winner {
+		return session.Win
 	}
+	if _, mark := board.Winner(); mark != engine.Empty {
+		return session.Loss
+	}
+	return session.Draw
+}
 
-	var bestScore int
-	scoreSet := false
-	for move, score := range availableMoves {
-		fmt.Printf("Game:%v Position: %v has score of %v \n", gameId, move, score)
-		if !scoreSet || score > bestScore {
-			bestScore = score
-			pos = move
-			scoreSet = true
+// Sessions returns the store tracking the games the bot is playing.
+func (b *TicTacToeBot) Sessions() *session.Store {
+	b.sessionsOnce.Do(func() {
+		if b.sessions == nil {
+			b.sessions = session.NewStore(0)
 		}
+	})
+	return b.sessions
+}
+
+// SetRejectAnomalies controls whether the bot refuses to move, replying
+// with a board anomaly error, when a board is not a legal successor of
+// the one it last answered in the same game. Anomalies are logged and
+// recorded in the game's session either way.
+func (b *TicTacToeBot) SetRejectAnomalies(reject bool) {
+	b.rejectAnomalies = reject
+}
+
+// SetSessions replaces the bot's session store. It must be called before
+// the bot plays.
+func (b *TicTacToeBot) SetSessions(s *session.Store) {
+	b.sessions = s
+}
+
+// analysisParams converts an engine analysis to its wire form.
+func analysisParams(a *engine.Analysis) *models.Analysis {
+	p := &models.Analysis{
+		Score:    a.Score,
+		Outcome:  a.Outcome.String(),
+		Moves:    make([]models.MoveScore, len(a.Moves)),
+		PV:       make([]int, len(a.PV)),
+		Nodes:    a.Nodes,
+		Depth:    a.Depth,
+		Complete: a.Complete,
+		Book:     a.FromBook,
 	}
-	return pos
+	for i, m := range a.Moves {
+		p.Moves[i] = models.MoveScore{Position: int(m.Move), Score: m.Score, Outcome: m.Outcome.String()}
+	}
+	for i, m := range a.PV {
+		p.PV[i] = int(m)
+	}
+	return p
 }
 
-func (b TicTacToeBot) NextMove(rpcReq models.ServerRpcRequest) []byte {
-	params := models.NextMoveParams{}
-	byteResult, e := json.Marshal(rpcReq.Params)
-	if e != nil {
-		fmt.Println(e)
+// boardSize returns the game variant described by request params, using
+// the standard 3x3 three-in-a-row game for any dimension that is absent.
+func boardSize(width, height, winLength int) engine.Size {
+	size := engine.Standard
+	if width > 0 {
+		size.Width = width
 	}
+	if height > 0 {
+		size.Height = height
+	}
+	if winLength > 0 {
+		size.K = winLength
+	} else if width > 0 || height > 0 {
+		size.K = min(size.Width, size.Height)
+	}
+	return size
+}
 
-	json.Unmarshal(byteResult, &params)
-	fmt.Printf("Game: %v You are playing %s \n", params.GameId, params.Mark)
-	PrintGameState(params.GameState)
-	myMove := MakeBestMove(params.GameState, params.Mark, params.GameId)
-	fmt.Printf("Game: %v your chosen move is position %v \n", params.GameId, myMove)
-	pos := models.NextMoveResponseParams{myMove}
-	rpc := CreateRPCResponse(pos, "", rpcReq.Id)
-	return rpc
+// FormatGameState renders a standard board on one line, rows separated
+// by slashes and empty squares shown as dashes, as in "X-O/-X-/--O".
+func FormatGameState(gameBoard []string) string {
+	return formatBoard(engine.FromStrings(gameBoard))
 }
 
-func (b TicTacToeBot) Complete(rpcReq models.ServerRpcRequest) []byte {
-	params := models.Complete{}
-	byteResult, e := json.Marshal(rpcReq.Params)
-	if e != nil {
-		fmt.Println(e)
-	}
-
-	json.Unmarshal(byteResult, &params)
-	var tellMe string
-	if params.Winner {
-		tellMe = "Congatulations you WON!!"
-	} else {
-		tellMe = "Better Luck next time fool.."
-	}
-	fmt.Printf("%s GameId: %v where you were playing %s \n", tellMe, params.GameId, params.Mark)
-	PrintGameState(params.GameState)
-	s := models.StatusResponseParams{"OK"}
-	rpc := CreateRPCResponse(s, "", rpcReq.Id)
-	return rpc
-}
-
-func PrintGameState(gameBoard []string) {
-	for num, s := range gameBoard {
-		var val string
-		if s == "" {
-			val = " "
-		} else {
-			val = s
+// formatBoard renders board on one line for logs, like FormatGameState.
+func formatBoard(board engine.Board) string {
+	var sb strings.Builder
+	size := board.Size()
+	for row := 0; row < size.Height; row++ {
+		if row > 0 {
+			sb.WriteByte('/')
 		}
-		if (num+1)%3 == 0 {
-			fmt.Printf("%s \n", val)
-		} else {
-			fmt.Printf("%s | ", val)
+		for col := 0; col < size.Width; col++ {
+			m := board.MoveAt(row, col)
+			if int(m) >= board.Len() || board.At(m) == engine.Empty {
+				sb.WriteByte('-')
+			} else {
+				sb.WriteString(string(board.At(m)))
+			}
 		}
 	}
+	return sb.String()
 }
 
-func (b *TicTacToeBot) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	decoder := json.NewDecoder(req.Body)
-	var rpcRequest models.ServerRpcRequest
-	err := decoder.Decode(&rpcRequest)
-	if err != nil {
-		panic(err)
-	}
-	var body []byte
-	switch rpcRequest.Method {
-	case "Status.Ping":
-		body = b.StatusPing(rpcRequest.Id)
-		rw.Write(body)
-	case "TicTacToe.NextMove":
-		body = b.NextMove(rpcRequest)
-		rw.Write(body)
-	case "TicTacToe.Error":
-		body = b.Error(rpcRequest)
-		rw.Write(body)
-	case "TicTacToe.Complete":
-		body = b.Complete(rpcRequest)
-		rw.Write(body)
-	default:
-		fmt.Printf("Request method %s is of unknown type\n", rpcRequest.Method)
-	}
-
-	return
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
 
 func main() {
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, config.Usage())
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if cfg.PrintConfig {
+		cfg.Print(os.Stdout)
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger, err := cfg.Logger(os.Stderr)
+	if err != nil {
+		fatal("invalid logging configuration", "error", err)
+	}
+	slog.SetDefault(logger)
 
-	var b GameBot
-	b = &TicTacToeBot{}
-	b.SetBaseUrl(os.Getenv("MERKNERA_URL"))
-	b.SetToken(os.Getenv("TOKEN"))
-
-	if b.Register("TICTACTOE", os.Getenv("BOTNAME"), os.Getenv("MY_URL"), "2.1", "", "") {
-		fmt.Println("Registration Complete... Tic Tac Toe Has begun")
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	b := NewTicTacToeBot()
+	b.SetBaseUrl(cfg.MerkneraURL)
+	b.SetToken(cfg.Token)
+	b.SetMoveBudget(cfg.MoveBudget)
+	b.SetAnalysis(cfg.Analysis)
+	b.SetStrictRPC(cfg.StrictJSONRPC)
+	if err := b.SetTieBreak(cfg.TieBreak, seed); err != nil {
+		fatal("invalid tie-break policy", "error", err)
+	}
+	if err := b.SetStrategy(cfg.Strategy, seed); err != nil {
+		fatal("invalid strategy", "error", err)
 	}
 
-	http.Handle("/", b)
+	sessions := session.NewStore(cfg.SessionTTL)
+	history, err := openHistory(cfg.HistoryBackend, cfg.HistoryFile)
+	if err != nil {
+		fatal("could not open game history", "file", cfg.HistoryFile, "error", err)
+	}
+	if history != nil {
+		defer history.Close()
+		sessions.OnClose(func(s session.Session) {
+			if err := history.Save(store.NewGame(s)); err != nil {
+				slog.Error("could not save game", "gameId", s.GameId, "error", err)
+			}
+		})
+	}
+	b.SetSessions(sessions)
+	b.SetRejectAnomalies(cfg.RejectAnomalies)
+	b.SetClientOptions(merknera.Options{Timeout: cfg.MerkneraTimeout, MaxAttempts: cfg.MerkneraAttempts})
+	regOpts := merknera.RegistrarOptions{
+		Params:           registrationParams(cfg.Token, "TICTACTOE", cfg.BotName, cfg.BotURL, cfg.BotVersion, cfg.Website, cfg.Description),
+		QuietPeriod:      cfg.QuietPeriod,
+		DeregisterMethod: cfg.DeregisterMethod,
+	}
+	if cfg.WaitForEndpoint {
+		regOpts.Probe = merknera.NewClient(cfg.BotURL, merknera.Options{Timeout: cfg.MerkneraTimeout, MaxAttempts: 1})
+	}
+	registrar := merknera.NewRegistrar(b.Client(), regOpts)
+	b.SetRegistrar(registrar)
 
-	err := http.ListenAndServe(":3003", nil)
+	// Listen before registering, so the game server can reach the bot as
+	// soon as it knows about it.
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
+	if err != nil {
+		fatal("could not listen", "port", cfg.Port, "error", err)
+	}
+	srv := &http.Server{
+		Handler:      b.Handler(),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = run(ctx, srv, ln, registrar, runOptions{
+		Drain:             cfg.DrainTimeout,
+		Deregister:        cfg.Deregister,
+		DeregisterTimeout: cfg.DeregisterTimeout,
+	})
 	if err != nil {
-		log.Fatal(err)
+		if history != nil {
+			history.Close()
+		}
+		fatal("server stopped", "error", err)
+	}
+}
+
+// openHistory opens the game history file with the named backend, or
+// returns nil if there is no file.
+func openHistory(backend, path string) (store.GameStore, error) {
+	if path == "" {
+		return nil, nil
+	}
+	switch backend {
+	case "jsonl":
+		return store.OpenJSONL(path)
+	case "kv":
+		return store.OpenKVStore(path)
 	}
+	return nil, fmt.Errorf("unknown history backend %q", backend)
 }
@@ -2,211 +2,29 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/purnet/TicTacToeBot/engine"
+	"github.com/purnet/TicTacToeBot/merknera"
 	"github.com/purnet/TicTacToeBot/models"
+	"github.com/purnet/TicTacToeBot/session"
 )
 
-// Test isGameOver function
-func TestIsGameOver(t *testing.T) {
-	tests := []struct {
-		name      string
-		gameState []string
-		expected  bool
-		winner    string
-	}{
-		{
-			name:      "Empty board",
-			gameState: []string{"", "", "", "", "", "", "", "", ""},
-			expected:  false,
-			winner:    "",
-		},
-		{
-			name:      "X wins horizontally (top row)",
-			gameState: []string{"X", "X", "X", "", "", "", "", "", ""},
-			expected:  true,
-			winner:    "X",
-		},
-		{
-			name:      "O wins horizontally (middle row)",
-			gameState: []string{"", "", "", "O", "O", "O", "", "", ""},
-			expected:  true,
-			winner:    "O",
-		},
-		{
-			name:      "X wins vertically (left column)",
-			gameState: []string{"X", "", "", "X", "", "", "X", "", ""},
-			expected:  true,
-			winner:    "X",
-		},
-		{
-			name:      "O wins vertically (right column)",
-			gameState: []string{"", "", "O", "", "", "O", "", "", "O"},
-			expected:  true,
-			winner:    "O",
-		},
-		{
-			name:      "X wins diagonally (main diagonal)",
-			gameState: []string{"X", "", "", "", "X", "", "", "", "X"},
-			expected:  true,
-			winner:    "X",
-		},
-		{
-			name:      "O wins diagonally (anti-diagonal)",
-			gameState: []string{"", "", "O", "", "O", "", "O", "", ""},
-			expected:  true,
-			winner:    "O",
-		},
-		{
-			name:      "Draw game",
-			gameState: []string{"X", "O", "X", "O", "X", "O", "O", "X", "O"},
-			expected:  true,
-			winner:    "",
-		},
-		{
-			name:      "Game in progress",
-			gameState: []string{"X", "O", "", "X", "", "", "", "", ""},
-			expected:  false,
-			winner:    "",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			gameOver, winner := isGameOver(tt.gameState)
-			if gameOver != tt.expected {
-				t.Errorf("isGameOver() gameOver = %v, expected %v", gameOver, tt.expected)
-			}
-			if winner != tt.winner {
-				t.Errorf("isGameOver() winner = %v, expected %v", winner, tt.winner)
-			}
-		})
-	}
-}
-
-// Test MiniMax function
-func TestMiniMax(t *testing.T) {
-	tests := []struct {
-		name        string
-		stateOfGame []string
-		player      string
-		move        int
-		turn        string
-		level       int
-		expected    int
-	}{
-		{
-			name:        "X wins immediately",
-			stateOfGame: []string{"X", "X", "", "", "", "", "", "", ""},
-			player:      "X",
-			move:        2,
-			turn:        "X",
-			level:       0,
-			expected:    10, // 10 + level (0)
-		},
-		{
-			name:        "O blocks X from winning",
-			stateOfGame: []string{"X", "X", "", "", "", "", "", "", ""},
-			player:      "O",
-			move:        2,
-			turn:        "O",
-			level:       0,
-			expected:    -10, // -10 + level (0)
-		},
-		{
-			name:        "Draw game",
-			stateOfGame: []string{"X", "O", "X", "O", "X", "O", "", "", ""},
-			player:      "X",
-			move:        6,
-			turn:        "X",
-			level:       0,
-			expected:    0,
-		},
-		{
-			name:        "Game in progress",
-			stateOfGame: []string{"X", "", "", "", "", "", "", "", ""},
-			player:      "X",
-			move:        1,
-			turn:        "X",
-			level:       0,
-			expected:    0, // Will depend on the game tree
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := MiniMax(tt.stateOfGame, tt.player, tt.move, tt.turn, tt.level)
-			// For complex game states, we just check that the result is reasonable
-			if result < -20 || result > 20 {
-				t.Errorf("MiniMax() result = %v, expected reasonable score", result)
-			}
-		})
-	}
-}
-
-// Test MakeBestMove function
-func TestMakeBestMove(t *testing.T) {
-	tests := []struct {
-		name       string
-		gameState  []string
-		player     string
-		gameId     int
-		validMoves []int // Valid positions that could be returned
-	}{
-		{
-			name:       "Empty board",
-			gameState:  []string{"", "", "", "", "", "", "", "", ""},
-			player:     "X",
-			gameId:     1,
-			validMoves: []int{0, 1, 2, 3, 4, 5, 6, 7, 8},
-		},
-		{
-			name:       "One move left",
-			gameState:  []string{"X", "O", "X", "O", "X", "O", "O", "X", ""},
-			player:     "X",
-			gameId:     2,
-			validMoves: []int{8},
-		},
-		{
-			name:       "X can win",
-			gameState:  []string{"X", "X", "", "", "", "", "", "", ""},
-			player:     "X",
-			gameId:     3,
-			validMoves: []int{2}, // Should choose winning move
-		},
-		{
-			name:       "O can win",
-			gameState:  []string{"O", "O", "", "", "", "", "", "", ""},
-			player:     "O",
-			gameId:     4,
-			validMoves: []int{2}, // Should choose winning move
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := MakeBestMove(tt.gameState, tt.player, tt.gameId)
-
-			// Check if result is a valid move
-			valid := false
-			for _, validMove := range tt.validMoves {
-				if result == validMove {
-					valid = true
-					break
-				}
-			}
-			if !valid {
-				t.Errorf("MakeBestMove() returned %v, expected one of %v", result, tt.validMoves)
-			}
-
-			// Check if the position is empty
-			if result >= 0 && result < len(tt.gameState) && tt.gameState[result] != "" {
-				t.Errorf("MakeBestMove() returned position %v that is not empty", result)
-			}
-		})
-	}
+// TestMain keeps the bot's logs out of test output.
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
 }
 
 // Test TicTacToeBot methods
@@ -369,6 +187,269 @@ func TestTicTacToeBot_Complete(t *testing.T) {
 	}
 }
 
+func TestTicTacToeBot_Register(t *testing.T) {
+	var params models.RegistrationParams
+	fails := 1
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if fails > 0 {
+			fails--
+			http.Error(rw, "starting up", http.StatusServiceUnavailable)
+			return
+		}
+		var body struct {
+			Method string                    `json:"method"`
+			Params models.RegistrationParams `json:"params"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+		params = body.Params
+		if body.Params.Token != "secret" {
+			rw.Write(CreateRPCResponse(nil, "invalid token", 1))
+			return
+		}
+		rw.Write(CreateRPCResponse(models.RegistrationResponse{Message: "welcome"}, "", 1))
+	}))
+	defer server.Close()
+
+	bot := NewTicTacToeBot()
+	bot.SetBaseUrl(server.URL)
+	bot.SetToken("secret")
+	bot.SetClientOptions(merknera.Options{Backoff: merknera.Backoff{Initial: time.Millisecond, Multiplier: 1}})
+	resp, err := bot.Register("TICTACTOE", "bot", "http://bot/rpc", "2.1", "", "")
+	if err != nil || resp.Message != "welcome" {
+		t.Fatalf("Register() after a transient error = %+v, %v", resp, err)
+	}
+	if params.BotName != "bot" || params.RpcEndPoint != "http://bot/rpc" || params.ProgrammingLanguage != "Go" {
+		t.Errorf("registered with %+v", params)
+	}
+
+	bot.SetToken("wrong")
+	_, err = bot.Register("TICTACTOE", "bot", "http://bot/rpc", "2.1", "", "")
+	var rpcErr *merknera.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Message != "invalid token" {
+		t.Errorf("Register() with a bad token = %v", err)
+	}
+}
+
+func TestTicTacToeBot_StatusEndpoint(t *testing.T) {
+	var registrations atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		registrations.Add(1)
+		rw.Write(CreateRPCResponse(models.RegistrationResponse{Message: "welcome"}, "", 1))
+	}))
+	defer server.Close()
+
+	bot := NewTicTacToeBot()
+	bot.SetBaseUrl(server.URL)
+	registrar := merknera.NewRegistrar(bot.Client(), merknera.RegistrarOptions{})
+	bot.SetRegistrar(registrar)
+	handler := bot.Handler()
+
+	status := func() (int, BotStatus) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+		var s BotStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+			t.Fatalf("status body %q: %v", rec.Body.String(), err)
+		}
+		return rec.Code, s
+	}
+	if code, s := status(); code != http.StatusServiceUnavailable || s.Registration.State != merknera.StateUnregistered {
+		t.Errorf("status before registering = %d %+v", code, s.Registration)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go registrar.Run(ctx)
+	for !registrar.Registered() {
+		time.Sleep(time.Millisecond)
+	}
+	code, s := status()
+	if code != http.StatusOK || s.Registration.Message != "welcome" || s.Strategy != "minimax" {
+		t.Errorf("status after registering = %d %+v", code, s)
+	}
+
+	// RPC still works alongside the status endpoint, and an error saying
+	// the bot is unknown makes it register again.
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(
+		`{"method":"TicTacToe.Error","params":{"gameid":1,"message":"unknown bot"},"id":1}`)))
+	if !strings.Contains(rec.Body.String(), `"OK"`) {
+		t.Errorf("TicTacToe.Error reply = %s", rec.Body.String())
+	}
+	for registrar.Status().Registrations < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if registrations.Load() != 2 {
+		t.Errorf("registered %d times, expected 2", registrations.Load())
+	}
+}
+
+func TestTicTacToeBot_Sessions(t *testing.T) {
+	bot := NewTicTacToeBot()
+	handler := bot.Handler()
+	post := func(body string) string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		return rec.Body.String()
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	post(`{"method":"TicTacToe.NextMove","params":{"gameid":5,"mark":"X","gamestate":["","","","","","","","",""]},"id":1}`)
+	post(`{"method":"TicTacToe.NextMove","params":{"gameid":6,"mark":"O","gamestate":["X","","","","","","","",""]},"id":2}`)
+	post(`{"method":"TicTacToe.Error","params":{"gameid":5,"message":"slow","errorcode":2},"id":3}`)
+
+	var live []session.Session
+	if err := json.Unmarshal(get("/sessions").Body.Bytes(), &live); err != nil {
+		t.Fatal(err)
+	}
+	if len(live) != 2 || live[0].GameId != 5 || live[1].GameId != 6 {
+		t.Fatalf("GET /sessions = %+v", live)
+	}
+	game := live[0]
+	if game.Mark != "X" || len(game.Turns) != 1 || game.Turns[0].Move < 0 || len(game.Errors) != 1 || game.Errors[0].Message != "slow" {
+		t.Errorf("session of game 5 = %+v", game)
+	}
+
+	rec := get("/sessions/6")
+	var one session.Session
+	json.Unmarshal(rec.Body.Bytes(), &one)
+	if rec.Code != http.StatusOK || one.Mark != "O" || one.Width != 3 || one.WinLength != 3 {
+		t.Errorf("GET /sessions/6 = %d %+v", rec.Code, one)
+	}
+	rec = get("/sessions/6?format=record")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `[Mark "O"]`) || !strings.Contains(rec.Body.String(), "1. a3 ") {
+		t.Errorf("GET /sessions/6?format=record = %d %s", rec.Code, rec.Body.String())
+	}
+	for format, typ := range map[string]string{"svg": "image/svg+xml", "png": "image/png", "gif": "image/gif"} {
+		rec = get("/sessions/6?format=" + format)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != typ || rec.Body.Len() == 0 {
+			t.Errorf("GET /sessions/6?format=%s = %d %q", format, rec.Code, rec.Header().Get("Content-Type"))
+		}
+	}
+	if rec := get("/sessions/6?format=bmp"); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /sessions/6?format=bmp = %d", rec.Code)
+	}
+
+	var closed []session.Session
+	bot.Sessions().OnClose(func(s session.Session) { closed = append(closed, s) })
+	post(`{"method":"TicTacToe.Complete","params":{"gameid":5,"mark":"X","winner":true,"gamestate":["X","X","X","O","O","","","",""]},"id":4}`)
+	if len(closed) != 1 || closed[0].Result != session.Win || len(closed[0].Turns) != 1 {
+		t.Errorf("closed sessions = %+v", closed)
+	}
+	if rec := get("/sessions/5"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /sessions/5 after Complete = %d", rec.Code)
+	}
+	if rec := get("/sessions/five"); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /sessions/five = %d", rec.Code)
+	}
+}
+
+func TestWriteSessionError(t *testing.T) {
+	unwritable := session.Session{
+		GameId: 7, Mark: "X", Width: 3, Height: 3, WinLength: 3,
+		Turns: []session.Turn{{Board: make([]string, 9), Opponent: -1, Move: 4, Outcome: "a } b"}},
+	}
+	// The opponent is recorded playing on our square.
+	illegal := unwritable
+	illegal.Turns = []session.Turn{
+		{Board: make([]string, 9), Opponent: -1, Move: 4},
+		{Board: make([]string, 9), Opponent: 4, Move: 0},
+	}
+	tests := []struct {
+		sess   session.Session
+		format string
+	}{
+		{unwritable, "record"},
+		{illegal, "record"},
+		{illegal, "gif"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeSession(rec, tt.sess, tt.format)
+		if rec.Code != http.StatusConflict || strings.HasPrefix(rec.Header().Get("Content-Type"), "image/") {
+			t.Errorf("writeSession(%s) of an unwritable game = %d %q %q", tt.format, rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+		}
+	}
+}
+
+func TestTicTacToeBot_BoardAnomalies(t *testing.T) {
+	bot := NewTicTacToeBot()
+	bot.SetTieBreak("lowest", 0)
+	move := func(gameId int, gs ...string) models.ClientRpcResponse {
+		raw, _ := json.Marshal(models.NextMoveParams{GameId: gameId, Mark: "X", GameState: gs})
+		params := json.RawMessage(raw)
+		var resp models.ClientRpcResponse
+		json.Unmarshal(bot.NextMove(models.ServerRpcRequest{Method: "TicTacToe.NextMove", Params: &params, Id: 1}), &resp)
+		return resp
+	}
+	first := move(1, "", "", "", "", "", "", "", "", "")
+	pos := int(first.Result.(map[string]interface{})["position"].(float64))
+
+	// The opponent's mark replaces ours: logged and recorded, but played.
+	tampered := make([]string, 9)
+	tampered[pos] = "O"
+	if resp := move(1, tampered...); resp.Error != "" {
+		t.Fatalf("NextMove() on a tampered board = %+v, expected a move", resp)
+	}
+	sess, _ := bot.Sessions().Get(1)
+	if anomalies := sess.Turns[1].Anomalies; len(anomalies) == 0 || anomalies[0].Kind != session.OwnMarkRemoved {
+		t.Errorf("recorded anomalies = %+v", anomalies)
+	}
+
+	// With rejection on, the same board gets an error naming the problem.
+	bot.SetRejectAnomalies(true)
+	move(2, "", "", "", "", "", "", "", "", "")
+	resp := move(2, tampered...)
+	if !strings.HasPrefix(resp.Error, "Board anomaly: our X at ") || resp.Result != nil {
+		t.Errorf("NextMove() on a tampered board = %+v, expected a board anomaly", resp)
+	}
+	// Sending it again does not get it played, and it is not recorded.
+	if resp := move(2, tampered...); !strings.HasPrefix(resp.Error, "Board anomaly: our X at ") || resp.Result != nil {
+		t.Errorf("NextMove() on the tampered board sent again = %+v, expected a board anomaly", resp)
+	}
+	if sess, _ := bot.Sessions().Get(2); len(sess.Turns) != 1 {
+		t.Errorf("session after rejected boards has %d turns, expected 1", len(sess.Turns))
+	}
+
+	// A legal reply is accepted, and the opponent's move inferred.
+	move(3, "", "", "", "", "", "", "", "", "")
+	legal := make([]string, 9)
+	legal[pos] = "X"
+	legal[(pos+1)%9] = "O"
+	if resp := move(3, legal...); resp.Error != "" {
+		t.Errorf("NextMove() on a legal successor = %+v", resp)
+	}
+	sess, _ = bot.Sessions().Get(3)
+	if sess.Turns[1].Opponent != (pos+1)%9 {
+		t.Errorf("inferred opponent move %d, expected %d", sess.Turns[1].Opponent, (pos+1)%9)
+	}
+}
+
+func TestGameResult(t *testing.T) {
+	board := func(gs ...string) engine.Board {
+		b, err := engine.Standard.ParseStrings(gs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	won := board("X", "X", "X", "O", "O", "", "", "", "")
+	drawn := board("X", "O", "X", "X", "O", "O", "O", "X", "X")
+	if r := gameResult(won, true); r != session.Win {
+		t.Errorf("gameResult(won, true) = %q", r)
+	}
+	if r := gameResult(won, false); r != session.Loss {
+		t.Errorf("gameResult(won, false) = %q", r)
+	}
+	if r := gameResult(drawn, false); r != session.Draw {
+		t.Errorf("gameResult(drawn, false) = %q", r)
+	}
+}
+
 // Test utility functions
 func TestCreateRPCRequest(t *testing.T) {
 	params := models.RegistrationParams{
@@ -422,11 +503,11 @@ func TestTicTacToeBot_ServeHTTP(t *testing.T) {
 	bot := &TicTacToeBot{}
 
 	tests := []struct {
-		name                string
-		method              string
-		params              interface{}
-		expectedStatus      int
-		expectEmptyResponse bool
+		name           string
+		method         string
+		params         interface{}
+		expectedStatus int
+		expectError    bool
 	}{
 		{
 			name:           "Status.Ping",
@@ -466,11 +547,11 @@ func TestTicTacToeBot_ServeHTTP(t *testing.T) {
 			expectedStatus: 200,
 		},
 		{
-			name:                "Unknown method",
-			method:              "Unknown.Method",
-			params:              nil,
-			expectedStatus:      200,  // Handler doesn't return error status
-			expectEmptyResponse: true, // Unknown methods don't write response
+			name:           "Unknown method",
+			method:         "Unknown.Method",
+			params:         nil,
+			expectedStatus: 200, // Errors are reported in the response body
+			expectError:    true,
 		},
 	}
 
@@ -507,33 +588,482 @@ func TestTicTacToeBot_ServeHTTP(t *testing.T) {
 				t.Errorf("ServeHTTP() status = %v, expected %v", rr.Code, tt.expectedStatus)
 			}
 
-			// Check that response is valid JSON (unless expecting empty response)
-			if !tt.expectEmptyResponse {
-				var response models.ClientRpcResponse
-				err := json.Unmarshal(rr.Body.Bytes(), &response)
-				if err != nil {
-					t.Errorf("ServeHTTP() response is not valid JSON: %v", err)
-				}
-			} else {
-				// For unknown methods, expect empty response body
-				if len(rr.Body.Bytes()) != 0 {
-					t.Errorf("ServeHTTP() expected empty response for unknown method, got: %s", rr.Body.String())
-				}
+			// Check that response is valid JSON
+			var response models.ClientRpcResponse
+			err := json.Unmarshal(rr.Body.Bytes(), &response)
+			if err != nil {
+				t.Errorf("ServeHTTP() response is not valid JSON: %v", err)
+			}
+			if (response.Error != "") != tt.expectError {
+				t.Errorf("ServeHTTP() error = %q, expected error: %v", response.Error, tt.expectError)
 			}
 		})
 	}
 }
 
-// Test PrintGameState (output testing)
-func TestPrintGameState(t *testing.T) {
-	// This test mainly ensures the function doesn't panic
-	// In a real scenario, you might want to capture stdout
-	gameBoard := []string{"X", "O", "X", "O", "X", "O", "O", "X", "O"}
+func TestFormatGameState(t *testing.T) {
+	tests := []struct {
+		gameBoard []string
+		expected  string
+	}{
+		{[]string{"X", "O", "X", "O", "X", "O", "O", "X", "O"}, "XOX/OXO/OXO"},
+		{[]string{"", "", "", "", "", "", "", "", ""}, "---/---/---"},
+		{[]string{"X", "", "", "", "O", "", "", "", "X"}, "X--/-O-/--X"},
+	}
+	for _, tt := range tests {
+		if got := FormatGameState(tt.gameBoard); got != tt.expected {
+			t.Errorf("FormatGameState(%q) = %q, expected %q", tt.gameBoard, got, tt.expected)
+		}
+	}
+}
+
+func TestBoardSize(t *testing.T) {
+	tests := []struct {
+		name                     string
+		width, height, winLength int
+		expected                 engine.Size
+	}{
+		{"Absent defaults to standard", 0, 0, 0, engine.Standard},
+		{"Square board defaults win length", 5, 5, 0, engine.Size{Width: 5, Height: 5, K: 5}},
+		{"Explicit win length", 15, 15, 5, engine.Size{Width: 15, Height: 15, K: 5}},
+		{"Missing height", 4, 0, 0, engine.Size{Width: 4, Height: 3, K: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := boardSize(tt.width, tt.height, tt.winLength); got != tt.expected {
+				t.Errorf("boardSize() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
 
-	// This should not panic
-	PrintGameState(gameBoard)
+func TestTicTacToeBot_NextMoveGeneralized(t *testing.T) {
+	bot := &TicTacToeBot{}
 
-	// Test with empty board
-	emptyBoard := []string{"", "", "", "", "", "", "", "", ""}
-	PrintGameState(emptyBoard)
+	nextMoveParams := models.NextMoveParams{
+		GameId: 790,
+		Mark:   "O",
+		GameState: []string{
+			"X", "O", "X", "",
+			"X", "O", "", "",
+			"", "", "X", "",
+			"O", "X", "", "O",
+		},
+		Width:     4,
+		Height:    4,
+		WinLength: 3,
+	}
+	paramsBytes, _ := json.Marshal(nextMoveParams)
+
+	rpcReq := models.ServerRpcRequest{
+		Method: "TicTacToe.NextMove",
+		Params: (*json.RawMessage)(&paramsBytes),
+		Id:     790,
+	}
+
+	var response models.ClientRpcResponse
+	if err := json.Unmarshal(bot.NextMove(rpcReq), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	var nextMoveResponse models.NextMoveResponseParams
+	resultBytes, _ := json.Marshal(response.Result)
+	json.Unmarshal(resultBytes, &nextMoveResponse)
+
+	// O must complete the 1-5-9 column.
+	if nextMoveResponse.Position != 9 {
+		t.Errorf("NextMove() position = %v, expected 9", nextMoveResponse.Position)
+	}
+}
+
+func TestTicTacToeBot_TableSharedAcrossGames(t *testing.T) {
+	// Without a book so that standard positions are searched.
+	bot := &TicTacToeBot{table: engine.NewTable(0)}
+
+	for gameId := 1; gameId <= 2; gameId++ {
+		paramsBytes, _ := json.Marshal(models.NextMoveParams{
+			GameId:    gameId,
+			Mark:      "O",
+			GameState: []string{"X", "", "", "", "", "", "", "", ""},
+		})
+		bot.NextMove(models.ServerRpcRequest{
+			Method: "TicTacToe.NextMove",
+			Params: (*json.RawMessage)(&paramsBytes),
+			Id:     gameId,
+		})
+	}
+
+	stats := bot.TableStats()
+	if stats.Hits == 0 || stats.Stores == 0 {
+		t.Errorf("TableStats() = %+v, expected hits and stores", stats)
+	}
+	if (&TicTacToeBot{}).TableStats() != (engine.TableStats{}) {
+		t.Errorf("TableStats() on a bot without a table should be zero")
+	}
+}
+
+func TestTicTacToeBot_NextMoveDeadline(t *testing.T) {
+	bot := NewTicTacToeBot()
+
+	// A deadline that has already passed still yields a legal move from
+	// the first ply of the search.
+	paramsBytes, _ := json.Marshal(models.NextMoveParams{
+		GameId:    791,
+		Mark:      "X",
+		GameState: make([]string, 36),
+		Width:     6,
+		Height:    6,
+		WinLength: 4,
+		Deadline:  time.Now().Add(-time.Second).UnixMilli(),
+	})
+	rpcReq := models.ServerRpcRequest{
+		Method: "TicTacToe.NextMove",
+		Params: (*json.RawMessage)(&paramsBytes),
+		Id:     791,
+	}
+
+	start := time.Now()
+	result := bot.NextMove(rpcReq)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("NextMove() took %v after its deadline", elapsed)
+	}
+
+	var response models.ClientRpcResponse
+	if err := json.Unmarshal(result, &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	var nextMoveResponse models.NextMoveResponseParams
+	resultBytes, _ := json.Marshal(response.Result)
+	json.Unmarshal(resultBytes, &nextMoveResponse)
+	if nextMoveResponse.Position < 0 || nextMoveResponse.Position >= 36 {
+		t.Errorf("NextMove() position = %v, expected 0-35", nextMoveResponse.Position)
+	}
+}
+
+func TestTicTacToeBot_NextMoveDefaultBudget(t *testing.T) {
+	bot := NewTicTacToeBot()
+
+	// Neither a budget nor a deadline: a board this large would otherwise
+	// be searched to the end.
+	paramsBytes, _ := json.Marshal(models.NextMoveParams{
+		GameId:    793,
+		Mark:      "X",
+		GameState: make([]string, 32*32),
+		Width:     32,
+		Height:    32,
+		WinLength: 5,
+	})
+	rpcReq := models.ServerRpcRequest{
+		Method: "TicTacToe.NextMove",
+		Params: (*json.RawMessage)(&paramsBytes),
+		Id:     793,
+	}
+
+	start := time.Now()
+	result := bot.NextMove(rpcReq)
+	if elapsed := time.Since(start); elapsed > DefaultMoveBudget+time.Second {
+		t.Errorf("NextMove() took %v, expected about %v", elapsed, DefaultMoveBudget)
+	}
+	var response models.ClientRpcResponse
+	if err := json.Unmarshal(result, &response); err != nil || response.Error != "" {
+		t.Fatalf("NextMove() = %s, %v", result, err)
+	}
+}
+
+func TestTicTacToeBot_NextMoveCancelledRequest(t *testing.T) {
+	bot := NewTicTacToeBot()
+	bot.SetMoveBudget(time.Minute)
+
+	paramsBytes, _ := json.Marshal(models.NextMoveParams{
+		GameId:    792,
+		Mark:      "X",
+		GameState: make([]string, 25),
+		Width:     5,
+		Height:    5,
+		WinLength: 4,
+	})
+	body, _ := json.Marshal(models.ServerRpcRequest{
+		Method: "TicTacToe.NextMove",
+		Params: (*json.RawMessage)(&paramsBytes),
+		Id:     792,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("POST", "/", bytes.NewBuffer(body)).WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	start := time.Now()
+	bot.ServeHTTP(rr, req)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("ServeHTTP() took %v after the request was cancelled", elapsed)
+	}
+
+	var response models.ClientRpcResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("ServeHTTP() response is not valid JSON: %v", err)
+	}
+}
+
+func TestTicTacToeBot_NextMoveFromBook(t *testing.T) {
+	bot := NewTicTacToeBot()
+
+	paramsBytes, _ := json.Marshal(models.NextMoveParams{
+		GameId:    793,
+		Mark:      "O",
+		GameState: []string{"", "", "X", "", "", "", "", "", ""},
+	})
+	result := bot.NextMove(models.ServerRpcRequest{
+		Method: "TicTacToe.NextMove",
+		Params: (*json.RawMessage)(&paramsBytes),
+		Id:     793,
+	})
+
+	var response models.ClientRpcResponse
+	if err := json.Unmarshal(result, &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	var nextMoveResponse models.NextMoveResponseParams
+	resultBytes, _ := json.Marshal(response.Result)
+	json.Unmarshal(resultBytes, &nextMoveResponse)
+	if nextMoveResponse.Position != 4 {
+		t.Errorf("NextMove() position = %v, expected 4", nextMoveResponse.Position)
+	}
+
+	// Book answers never touch the transposition table.
+	if stats := bot.TableStats(); stats.Stores != 0 {
+		t.Errorf("TableStats() = %+v, expected the move to come from the book", stats)
+	}
+}
+
+func TestTicTacToeBot_SetStrategy(t *testing.T) {
+	bot := NewTicTacToeBot()
+	if err := bot.SetStrategy("no-such-strategy", 1); err == nil {
+		t.Error("SetStrategy() accepted an unknown strategy")
+	}
+
+	for _, name := range engine.StrategyNames {
+		if err := bot.SetStrategy(name, 1); err != nil {
+			t.Fatalf("SetStrategy(%q) = %v", name, err)
+		}
+		paramsBytes, _ := json.Marshal(models.NextMoveParams{
+			GameId:    794,
+			Mark:      "O",
+			GameState: []string{"X", "X", "", "", "O", "", "", "", ""},
+		})
+		result := bot.NextMove(models.ServerRpcRequest{
+			Method: "TicTacToe.NextMove",
+			Params: (*json.RawMessage)(&paramsBytes),
+			Id:     794,
+		})
+
+		var response models.ClientRpcResponse
+		if err := json.Unmarshal(result, &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		var nextMoveResponse models.NextMoveResponseParams
+		resultBytes, _ := json.Marshal(response.Result)
+		json.Unmarshal(resultBytes, &nextMoveResponse)
+		// Random play and the weaker difficulty levels may miss the block.
+		_, err := engine.ParseDifficulty(name)
+		handicapped := err == nil && name != "perfect"
+		if name != "random" && !handicapped && nextMoveResponse.Position != 2 {
+			t.Errorf("%s: NextMove() position = %v, expected the block at 2", name, nextMoveResponse.Position)
+		}
+	}
+}
+
+func TestTicTacToeBot_NextMoveFinishedGame(t *testing.T) {
+	bot := NewTicTacToeBot()
+
+	paramsBytes, _ := json.Marshal(models.NextMoveParams{
+		GameId:    795,
+		Mark:      "O",
+		GameState: []string{"X", "X", "X", "O", "O", "", "", "", ""},
+	})
+	result := bot.NextMove(models.ServerRpcRequest{
+		Method: "TicTacToe.NextMove",
+		Params: (*json.RawMessage)(&paramsBytes),
+		Id:     795,
+	})
+
+	var response models.ClientRpcResponse
+	if err := json.Unmarshal(result, &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Error == "" {
+		t.Errorf("NextMove() on a finished game returned no error")
+	}
+}
+
+func TestTicTacToeBot_NextMoveDifficulty(t *testing.T) {
+	bot := NewTicTacToeBot()
+	if err := bot.SetStrategy("random", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	move := func(difficulty string, gameId int) models.ClientRpcResponse {
+		paramsBytes, _ := json.Marshal(models.NextMoveParams{
+			GameId:     gameId,
+			Mark:       "O",
+			GameState:  []string{"X", "", "", "", "", "", "", "", ""},
+			Difficulty: difficulty,
+		})
+		var response models.ClientRpcResponse
+		json.Unmarshal(bot.NextMove(models.ServerRpcRequest{
+			Method: "TicTacToe.NextMove",
+			Params: (*json.RawMessage)(&paramsBytes),
+			Id:     gameId,
+		}), &response)
+		return response
+	}
+	position := func(response models.ClientRpcResponse) int {
+		var nextMoveResponse models.NextMoveResponseParams
+		resultBytes, _ := json.Marshal(response.Result)
+		json.Unmarshal(resultBytes, &nextMoveResponse)
+		return nextMoveResponse.Position
+	}
+
+	// A perfect opponent overrides the random strategy and takes the
+	// only drawing reply.
+	if got := position(move("perfect", 796)); got != 4 {
+		t.Errorf("NextMove() perfect position = %v, expected 4", got)
+	}
+	if response := move("easy", 798); response.Error != "" {
+		t.Errorf("NextMove() easy error = %v", response.Error)
+	}
+	if response := move("impossible", 797); response.Error == "" {
+		t.Errorf("NextMove() accepted an unknown difficulty")
+	}
+}
+
+func TestTicTacToeBot_SetTieBreak(t *testing.T) {
+	bot := NewTicTacToeBot()
+	if err := bot.SetTieBreak("no-such-policy", 1); err == nil {
+		t.Error("SetTieBreak() accepted an unknown policy")
+	}
+
+	move := func(bot *TicTacToeBot, gameId int, difficulty string) int {
+		paramsBytes, _ := json.Marshal(models.NextMoveParams{
+			GameId:     gameId,
+			Mark:       "X",
+			GameState:  []string{"", "", "", "", "", "", "", "", ""},
+			Difficulty: difficulty,
+		})
+		var response models.ClientRpcResponse
+		json.Unmarshal(bot.NextMove(models.ServerRpcRequest{
+			Method: "TicTacToe.NextMove",
+			Params: (*json.RawMessage)(&paramsBytes),
+			Id:     gameId,
+		}), &response)
+		var nextMoveResponse models.NextMoveResponseParams
+		resultBytes, _ := json.Marshal(response.Result)
+		json.Unmarshal(resultBytes, &nextMoveResponse)
+		return nextMoveResponse.Position
+	}
+
+	// Every opening move draws, so the policy alone picks the move.
+	if err := bot.SetTieBreak("lowest", 1); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if got := move(bot, 800+i, ""); got != 0 {
+			t.Fatalf("NextMove() with lowest tie-break = %v, expected 0", got)
+		}
+	}
+
+	// Seeded bots replay the same choices, including in games with a
+	// difficulty.
+	play := func() []int {
+		bot := NewTicTacToeBot()
+		if err := bot.SetTieBreak("random", 11); err != nil {
+			t.Fatal(err)
+		}
+		var positions []int
+		for i := 0; i < 5; i++ {
+			positions = append(positions, move(bot, 810+i, ""), move(bot, 820+i, "easy"))
+		}
+		return positions
+	}
+	first, second := play(), play()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("seeded bots played %v then %v", first, second)
+		}
+	}
+}
+
+func TestTicTacToeBot_NextMoveAnalysis(t *testing.T) {
+	bot := NewTicTacToeBot()
+	request := func() []byte {
+		paramsBytes, _ := json.Marshal(models.NextMoveParams{
+			GameId:    830,
+			Mark:      "O",
+			GameState: []string{"X", "X", "", "O", "O", "", "", "", ""},
+		})
+		return bot.NextMove(models.ServerRpcRequest{
+			Method: "TicTacToe.NextMove",
+			Params: (*json.RawMessage)(&paramsBytes),
+			Id:     830,
+		})
+	}
+
+	if result := request(); bytes.Contains(result, []byte(`"analysis"`)) {
+		t.Errorf("NextMove() sent an analysis without being asked: %s", result)
+	}
+
+	bot.SetAnalysis(true)
+	var response struct {
+		Result models.NextMoveResponseParams `json:"result"`
+	}
+	if err := json.Unmarshal(request(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	a := response.Result.Analysis
+	if a == nil {
+		t.Fatal("NextMove() sent no analysis")
+	}
+	if response.Result.Position != 5 || a.Outcome != "win in 1 ply" {
+		t.Errorf("NextMove() = %v (%s), expected the win at 5", response.Result.Position, a.Outcome)
+	}
+	if len(a.PV) == 0 || a.PV[0] != response.Result.Position {
+		t.Errorf("NextMove() PV = %v, expected it to start with %v", a.PV, response.Result.Position)
+	}
+	if len(a.Moves) != 5 || a.Moves[0].Position != 5 {
+		t.Errorf("NextMove() move scores = %v, expected 5 moves led by 5", a.Moves)
+	}
+}
+
+func TestTicTacToeBot_LogsGameAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	bot := NewTicTacToeBot()
+	bot.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	rr := serve(bot, `{"jsonrpc":"2.0","method":"TicTacToe.NextMove","params":{"gameid":840,"mark":"O","gamestate":["X","","","","","","","",""]},"id":"m1"}`)
+	if !strings.Contains(rr.Body.String(), `"position"`) {
+		t.Fatalf("NextMove() = %s", rr.Body)
+	}
+
+	var chose map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		if entry["msg"] == "choosing move" && entry["board"] != "X--/---/---" {
+			t.Errorf("choosing move board = %v, expected X--/---/---", entry["board"])
+		}
+		if entry["msg"] == "chose move" {
+			chose = entry
+		}
+	}
+	if chose == nil {
+		t.Fatalf("no chose move entry in %s", buf.String())
+	}
+	want := map[string]any{"gameid": 840.0, "mark": "O", "method": "TicTacToe.NextMove", "rpcid": `"m1"`}
+	for k, v := range want {
+		if chose[k] != v {
+			t.Errorf("chose move %s = %v, expected %v", k, chose[k], v)
+		}
+	}
 }
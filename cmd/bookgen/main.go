@@ -0,0 +1,30 @@
+// Command bookgen solves every reachable position of standard tic-tac-toe
+// and writes the engine's embedded book.
+//
+// Run it through go generate in the engine package:
+//
+//	go generate ./engine
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/purnet/TicTacToeBot/engine"
+)
+
+func main() {
+	out := flag.String("o", "book.bin", "output file")
+	flag.Parse()
+
+	bk := engine.GenerateBook()
+	data, err := bk.MarshalBinary()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %d positions (%d bytes) to %s", bk.Len(), len(data), *out)
+}
@@ -0,0 +1,441 @@
+// Package config resolves the bot's settings from defaults, a config file,
+// environment variables and command-line flags, in increasing order of
+// precedence.
+//
+// Every setting has a key used in config files, an environment variable
+// and a flag, for example bot_url, MY_URL and --bot-url. Usage lists them
+// all.
+//
+// Config files may be JSON, YAML or TOML, chosen by extension. Only flat
+// files of keys and scalar values are supported.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/engine"
+)
+
+// Config holds the bot's settings.
+type Config struct {
+	// MerkneraURL is the game server's RPC endpoint.
+	MerkneraURL string
+	// Token authenticates the bot with the game server.
+	Token string
+	// BotName, BotVersion, Website and Description identify the bot when
+	// it registers.
+	BotName     string
+	BotVersion  string
+	Website     string
+	Description string
+	// BotURL is the address the game server reaches the bot at.
+	BotURL string
+	// Port is the port the bot listens on.
+	Port int
+
+	// Strategy and TieBreak name the engine's strategy and tie-breaking
+	// policy. Seed seeds their random choices; zero seeds from the clock.
+	Strategy string
+	TieBreak string
+	Seed     int64
+	// Analysis adds move analysis to logs and NextMove replies.
+	Analysis bool
+	// StrictJSONRPC rejects requests in the Merknera wire format.
+	StrictJSONRPC bool
+
+	// MoveBudget bounds the time spent choosing a move. Zero means no
+	// limit beyond the game server's deadline, or the bot's default budget
+	// for moves without one.
+	MoveBudget time.Duration
+	// ReadTimeout and WriteTimeout bound reading a request and writing
+	// its reply. Zero means no limit.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// MerkneraTimeout bounds each attempt at calling the game server, and
+	// MerkneraAttempts the attempts per call.
+	MerkneraTimeout  time.Duration
+	MerkneraAttempts int
+
+	// WaitForEndpoint delays registration until BotURL answers pings.
+	WaitForEndpoint bool
+	// QuietPeriod, if set, is how long the game server may go without
+	// contacting the bot before the bot registers again.
+	QuietPeriod time.Duration
+
+	// SessionTTL is how long a game may go without a call before its
+	// session is dropped.
+	SessionTTL time.Duration
+	// RejectAnomalies refuses to move on boards that are not a legal
+	// successor of the game's previous board.
+	RejectAnomalies bool
+	// HistoryFile, if set, is where finished games are saved, in the
+	// HistoryBackend format: "jsonl" or "kv".
+	HistoryFile    string
+	HistoryBackend string
+
+	// DrainTimeout is how long requests in progress at shutdown get to
+	// finish.
+	DrainTimeout time.Duration
+	// Deregister takes the bot offline with the game server on shutdown,
+	// by calling DeregisterMethod and waiting at most DeregisterTimeout.
+	Deregister        bool
+	DeregisterMethod  string
+	DeregisterTimeout time.Duration
+
+	// LogFormat is "text" or "json"; LogLevel is "debug", "info", "warn"
+	// or "error".
+	LogFormat string
+	LogLevel  string
+
+	// PrintConfig asks for the resolved configuration to be printed
+	// instead of running the bot. It can only be set by flag.
+	PrintConfig bool
+}
+
+// Default returns the settings used when no source provides a value.
+func Default() Config {
+	return Config{
+		BotVersion:  "2.1",
+		Port:        3003,
+		Strategy:    "minimax",
+		TieBreak:    "random",
+		ReadTimeout: 10 * time.Second,
+
+		MerkneraTimeout:  10 * time.Second,
+		MerkneraAttempts: 5,
+
+		SessionTTL:     30 * time.Minute,
+		HistoryBackend: "jsonl",
+
+		DrainTimeout:      30 * time.Second,
+		DeregisterMethod:  "RegistrationService.Deregister",
+		DeregisterTimeout: 5 * time.Second,
+
+		LogFormat: "text",
+		LogLevel:  "info",
+	}
+}
+
+// field describes one setting and how to read and write it as text.
+type field struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	bool   bool
+	number bool
+	get    func(c *Config) string
+	set    func(c *Config, v string) error
+}
+
+func stringField(key, env, flag, usage string, p func(c *Config) *string) field {
+	return field{
+		key: key, env: env, flag: flag, usage: usage,
+		get: func(c *Config) string { return *p(c) },
+		set: func(c *Config, v string) error { *p(c) = v; return nil },
+	}
+}
+
+func intField(key, env, flag, usage string, p func(c *Config) *int) field {
+	return field{
+		key: key, env: env, flag: flag, usage: usage, number: true,
+		get: func(c *Config) string { return strconv.Itoa(*p(c)) },
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			*p(c) = n
+			return err
+		},
+	}
+}
+
+func int64Field(key, env, flag, usage string, p func(c *Config) *int64) field {
+	return field{
+		key: key, env: env, flag: flag, usage: usage, number: true,
+		get: func(c *Config) string { return strconv.FormatInt(*p(c), 10) },
+		set: func(c *Config, v string) error {
+			n, err := strconv.ParseInt(v, 10, 64)
+			*p(c) = n
+			return err
+		},
+	}
+}
+
+func boolField(key, env, flag, usage string, p func(c *Config) *bool) field {
+	return field{
+		key: key, env: env, flag: flag, usage: usage, bool: true,
+		get: func(c *Config) string { return strconv.FormatBool(*p(c)) },
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			*p(c) = b
+			return err
+		},
+	}
+}
+
+func durationField(key, env, flag, usage string, p func(c *Config) *time.Duration) field {
+	return field{
+		key: key, env: env, flag: flag, usage: usage,
+		get: func(c *Config) string { return p(c).String() },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			*p(c) = d
+			return err
+		},
+	}
+}
+
+var fields = []field{
+	stringField("merknera_url", "MERKNERA_URL", "merknera-url", "game server RPC endpoint", func(c *Config) *string { return &c.MerkneraURL }),
+	func() field {
+		f := stringField("token", "TOKEN", "token", "game server token", func(c *Config) *string { return &c.Token })
+		f.secret = true
+		return f
+	}(),
+	stringField("bot_name", "BOTNAME", "bot-name", "name to register the bot under", func(c *Config) *string { return &c.BotName }),
+	stringField("bot_version", "BOT_VERSION", "bot-version", "version to register the bot with", func(c *Config) *string { return &c.BotVersion }),
+	stringField("website", "WEBSITE", "website", "website to register the bot with", func(c *Config) *string { return &c.Website }),
+	stringField("description", "DESCRIPTION", "description", "description to register the bot with", func(c *Config) *string { return &c.Description }),
+	stringField("bot_url", "MY_URL", "bot-url", "URL the game server reaches the bot at", func(c *Config) *string { return &c.BotURL }),
+	intField("port", "PORT", "port", "port to listen on", func(c *Config) *int { return &c.Port }),
+	stringField("strategy", "STRATEGY", "strategy", "move strategy: "+strings.Join(engine.StrategyNames, ", "), func(c *Config) *string { return &c.Strategy }),
+	stringField("tie_break", "TIE_BREAK", "tie-break", "tie-break policy: "+strings.Join(engine.TieBreakNames, ", "), func(c *Config) *string { return &c.TieBreak }),
+	int64Field("seed", "SEED", "seed", "seed for random choices, 0 to seed from the clock", func(c *Config) *int64 { return &c.Seed }),
+	boolField("analysis", "ANALYSIS", "analysis", "explain moves in logs and replies", func(c *Config) *bool { return &c.Analysis }),
+	boolField("strict_jsonrpc", "STRICT_JSONRPC", "strict-jsonrpc", "reject the Merknera wire format", func(c *Config) *bool { return &c.StrictJSONRPC }),
+	durationField("move_budget", "MOVE_BUDGET", "move-budget", "time limit per move, 0 for the game server's deadline or the default", func(c *Config) *time.Duration { return &c.MoveBudget }),
+	durationField("read_timeout", "READ_TIMEOUT", "read-timeout", "time limit for reading a request, 0 for none", func(c *Config) *time.Duration { return &c.ReadTimeout }),
+	durationField("write_timeout", "WRITE_TIMEOUT", "write-timeout", "time limit for writing a reply, 0 for none", func(c *Config) *time.Duration { return &c.WriteTimeout }),
+	durationField("merknera_timeout", "MERKNERA_TIMEOUT", "merknera-timeout", "time limit per attempt at calling the game server", func(c *Config) *time.Duration { return &c.MerkneraTimeout }),
+	intField("merknera_attempts", "MERKNERA_ATTEMPTS", "merknera-attempts", "attempts per call to the game server", func(c *Config) *int { return &c.MerkneraAttempts }),
+	boolField("wait_for_endpoint", "WAIT_FOR_ENDPOINT", "wait-for-endpoint", "register only once bot_url answers pings", func(c *Config) *bool { return &c.WaitForEndpoint }),
+	durationField("quiet_period", "QUIET_PERIOD", "quiet-period", "register again after this long without hearing from the game server, 0 never", func(c *Config) *time.Duration { return &c.QuietPeriod }),
+	durationField("session_ttl", "SESSION_TTL", "session-ttl", "time a game may go without a call before its session is dropped", func(c *Config) *time.Duration { return &c.SessionTTL }),
+	boolField("reject_anomalies", "REJECT_ANOMALIES", "reject-anomalies", "reply with an error to boards that do not follow from the last one", func(c *Config) *bool { return &c.RejectAnomalies }),
+	stringField("history_file", "HISTORY_FILE", "history-file", "file to save finished games to, empty for none", func(c *Config) *string { return &c.HistoryFile }),
+	stringField("history_backend", "HISTORY_BACKEND", "history-backend", "history file format: jsonl or kv", func(c *Config) *string { return &c.HistoryBackend }),
+	durationField("drain_timeout", "DRAIN_TIMEOUT", "drain-timeout", "time requests in progress at shutdown get to finish", func(c *Config) *time.Duration { return &c.DrainTimeout }),
+	boolField("deregister", "DEREGISTER", "deregister", "take the bot offline with the game server on shutdown", func(c *Config) *bool { return &c.Deregister }),
+	stringField("deregister_method", "DEREGISTER_METHOD", "deregister-method", "method taking the bot offline", func(c *Config) *string { return &c.DeregisterMethod }),
+	durationField("deregister_timeout", "DEREGISTER_TIMEOUT", "deregister-timeout", "time limit for deregistering", func(c *Config) *time.Duration { return &c.DeregisterTimeout }),
+	stringField("log_format", "LOG_FORMAT", "log-format", "log format: text or json", func(c *Config) *string { return &c.LogFormat }),
+	stringField("log_level", "LOG_LEVEL", "log-level", "log level: debug, info, warn or error", func(c *Config) *string { return &c.LogLevel }),
+}
+
+// fieldByKey returns the field with the given config file key.
+func fieldByKey(key string) (field, bool) {
+	for _, f := range fields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// flagValue records a flag's text so that only flags given on the command
+// line override other sources.
+type flagValue struct {
+	text   string
+	set    bool
+	isBool bool
+}
+
+func (v *flagValue) String() string   { return v.text }
+func (v *flagValue) IsBoolFlag() bool { return v.isBool }
+func (v *flagValue) Set(s string) error {
+	v.text, v.set = s, true
+	return nil
+}
+
+// Load resolves the configuration from args, the command-line arguments
+// without the program name, and getenv. The config file is named by the
+// --config flag or the CONFIG_FILE environment variable. Load returns
+// flag.ErrHelp if args ask for help, and does not validate the result.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	fs := flag.NewFlagSet("tictactoebot", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	values := make([]*flagValue, len(fields))
+	for i, f := range fields {
+		values[i] = &flagValue{isBool: f.bool}
+		fs.Var(values[i], f.flag, f.usage)
+	}
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "config file (.json, .yaml, .yml or .toml)")
+	printConfig := fs.Bool("print-config", false, "print the configuration, with secrets redacted, and exit")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return Config{}, err
+		}
+		return Config{}, fmt.Errorf("config: %w", err)
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("config: unexpected argument %q", fs.Arg(0))
+	}
+
+	c := Default()
+	if *configFile != "" {
+		if err := c.loadFile(*configFile); err != nil {
+			return Config{}, err
+		}
+	}
+	for _, f := range fields {
+		if v := getenv(f.env); v != "" {
+			if err := f.set(&c, v); err != nil {
+				return Config{}, fmt.Errorf("config: environment variable %s: %w", f.env, err)
+			}
+		}
+	}
+	for i, f := range fields {
+		if values[i].set {
+			if err := f.set(&c, values[i].text); err != nil {
+				return Config{}, fmt.Errorf("config: flag --%s: %w", f.flag, err)
+			}
+		}
+	}
+	c.PrintConfig = *printConfig
+	return c, nil
+}
+
+// loadFile applies the settings in the config file at path.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	var values map[string]string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		values, err = parseJSON(data)
+	case ".yaml", ".yml":
+		values, err = parseYAML(data)
+	case ".toml":
+		values, err = parseTOML(data)
+	default:
+		err = fmt.Errorf("unsupported format %q", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	for key, v := range values {
+		f, ok := fieldByKey(key)
+		if !ok {
+			return fmt.Errorf("config: %s: unknown key %q", path, key)
+		}
+		if err := f.set(c, v); err != nil {
+			return fmt.Errorf("config: %s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// Validate reports every problem with the configuration at once.
+func (c Config) Validate() error {
+	var errs []error
+	required := map[string]string{
+		"merknera_url": c.MerkneraURL,
+		"token":        c.Token,
+		"bot_name":     c.BotName,
+		"bot_url":      c.BotURL,
+	}
+	for _, f := range fields {
+		if v, ok := required[f.key]; ok && v == "" {
+			errs = append(errs, fmt.Errorf("%s is required (set %s or --%s)", f.key, f.env, f.flag))
+		}
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", c.Port))
+	}
+	if _, err := engine.NewStrategy(c.Strategy, engine.StrategyOptions{}); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := engine.NewTieBreak(c.TieBreak, 0); err != nil {
+		errs = append(errs, err)
+	}
+	if c.MerkneraAttempts < 1 {
+		errs = append(errs, fmt.Errorf("merknera_attempts %d must be at least 1", c.MerkneraAttempts))
+	}
+	if c.MoveBudget < 0 || c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.MerkneraTimeout <= 0 || c.QuietPeriod < 0 || c.SessionTTL <= 0 ||
+		c.DrainTimeout < 0 || c.DeregisterTimeout < 0 {
+		errs = append(errs, errors.New("durations must not be negative, and merknera_timeout and session_ttl must be positive"))
+	}
+	if c.WriteTimeout > 0 && (c.MoveBudget == 0 || c.MoveBudget >= c.WriteTimeout) {
+		errs = append(errs, fmt.Errorf("move_budget must be set below write_timeout %v", c.WriteTimeout))
+	}
+	if c.Deregister && (c.DeregisterMethod == "" || c.DeregisterTimeout == 0) {
+		errs = append(errs, errors.New("deregister needs deregister_method and a deregister_timeout"))
+	}
+	if c.HistoryBackend != "jsonl" && c.HistoryBackend != "kv" {
+		errs = append(errs, fmt.Errorf("history_backend %q must be jsonl or kv", c.HistoryBackend))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("log_format %q must be text or json", c.LogFormat))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		errs = append(errs, fmt.Errorf("log_level %q must be debug, info, warn or error", c.LogLevel))
+	}
+	return errors.Join(errs...)
+}
+
+// Logger returns a logger writing to w in the configured format and at
+// the configured level.
+func (c Config) Logger(w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return nil, fmt.Errorf("config: invalid log level %q", c.LogLevel)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	switch c.LogFormat {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("config: invalid log format %q", c.LogFormat)
+}
+
+// Print writes the configuration to w as a JSON config file, with secrets
+// redacted.
+func (c Config) Print(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	for i, f := range fields {
+		v := f.get(&c)
+		if f.secret && v != "" {
+			v = "REDACTED"
+		}
+		fmt.Fprintf(&sb, "  %q: %s", f.key, jsonValue(f, v))
+		if i < len(fields)-1 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('\n')
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// Usage describes every flag, with its environment variable and default.
+func Usage() string {
+	defaults := Default()
+	var sb strings.Builder
+	sb.WriteString("Usage of tictactoebot:\n")
+	sb.WriteString("  --config string\n    \tconfig file (.json, .yaml, .yml or .toml) [CONFIG_FILE]\n")
+	sb.WriteString("  --print-config\n    \tprint the configuration, with secrets redacted, and exit\n")
+	for _, f := range fields {
+		fmt.Fprintf(&sb, "  --%s\n    \t%s [%s]", f.flag, f.usage, f.env)
+		if v := f.get(&defaults); v != "" && v != "0" && v != "0s" && v != "false" {
+			fmt.Fprintf(&sb, " (default %s)", v)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env returns a getenv function reading from vars.
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+// writeFile writes a config file named name in a temporary directory.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// valid returns settings that pass validation.
+func valid() map[string]string {
+	return map[string]string{
+		"MERKNERA_URL": "http://merknera/rpc",
+		"TOKEN":        "s3cret",
+		"BOTNAME":      "bot",
+		"MY_URL":       "http://bot:3003/rpc",
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, err := Load(nil, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != Default() {
+		t.Errorf("Load() with no sources = %+v, expected the defaults", c)
+	}
+	if c.Port != 3003 || c.BotVersion != "2.1" || c.Strategy != "minimax" {
+		t.Errorf("Default() = %+v", c)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "bot.json", `{"bot_name":"file","port":4000,"strategy":"rules","analysis":true,"move_budget":"2s"}`)
+	vars := map[string]string{"CONFIG_FILE": path, "PORT": "5000", "STRATEGY": "greedy"}
+
+	c, err := Load([]string{"--strategy", "mcts"}, env(vars))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.BotName != "file" || !c.Analysis || c.MoveBudget != 2*time.Second {
+		t.Errorf("file settings were not applied: %+v", c)
+	}
+	if c.Port != 5000 {
+		t.Errorf("Port = %d, expected the environment to override the file", c.Port)
+	}
+	if c.Strategy != "mcts" {
+		t.Errorf("Strategy = %q, expected the flag to override the environment", c.Strategy)
+	}
+	if c.LogLevel != "info" {
+		t.Errorf("LogLevel = %q, expected the default", c.LogLevel)
+	}
+
+	// --config overrides CONFIG_FILE, and bool flags need no value.
+	other := writeFile(t, "other.json", `{"bot_name":"other"}`)
+	c, err = Load([]string{"--config=" + other, "--strict-jsonrpc", "--print-config"}, env(vars))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.BotName != "other" || !c.StrictJSONRPC || !c.PrintConfig {
+		t.Errorf("Load() = %+v", c)
+	}
+}
+
+func TestLoadFileFormats(t *testing.T) {
+	files := map[string]string{
+		"bot.json": `{"bot_name": "my bot", "port": 4000, "analysis": true, "move_budget": "1.5s"}`,
+		"bot.yaml": "---\n# my bot\nbot_name: \"my bot\"\nport: 4000 # listen here\nanalysis: true\nmove_budget: 1.5s\n",
+		"bot.yml":  "bot_name: 'my bot'\nport: 4000\nanalysis: yes_is_not_a_bool\n",
+		"bot.toml": "# my bot\nbot_name = \"my bot\"\nport = 4000\nanalysis = true\nmove_budget = \"1.5s\"\n",
+	}
+	for name, content := range files {
+		c, err := Load([]string{"--config", writeFile(t, name, content)}, env(nil))
+		if name == "bot.yml" {
+			if err == nil {
+				t.Errorf("%s: expected an error for a non-boolean analysis", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if c.BotName != "my bot" || c.Port != 4000 || !c.Analysis || c.MoveBudget != 1500*time.Millisecond {
+			t.Errorf("%s: Load() = %+v", name, c)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		args    []string
+		vars    map[string]string
+	}{
+		{name: "unknown flag", args: []string{"--colour"}},
+		{name: "stray argument", args: []string{"serve"}},
+		{name: "bad flag value", args: []string{"--port", "http"}},
+		{name: "bad env value", vars: map[string]string{"MOVE_BUDGET": "soon"}},
+		{name: "missing file", args: []string{"--config", "/nonexistent/bot.json"}},
+		{name: "unknown format", file: "bot.ini", content: "port=1"},
+		{name: "unknown key", file: "bot.json", content: `{"colour":"blue"}`},
+		{name: "nested JSON", file: "bot.json", content: `{"port":{"http":1}}`},
+		{name: "nested YAML", file: "bot.yaml", content: "server:\n  port: 1\n"},
+		{name: "TOML table", file: "bot.toml", content: "[server]\nport = 1\n"},
+		{name: "duplicate key", file: "bot.toml", content: "port = 1\nport = 2\n"},
+		{name: "unterminated string", file: "bot.toml", content: "token = \"abc\n"},
+	}
+	for _, tt := range tests {
+		args := tt.args
+		if tt.file != "" {
+			args = []string{"--config", writeFile(t, tt.file, tt.content)}
+		}
+		if _, err := Load(args, env(tt.vars)); err == nil {
+			t.Errorf("%s: Load() expected an error", tt.name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c, err := Load(nil, env(valid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   string
+	}{
+		{"missing token", func(c *Config) { c.Token = "" }, "token is required (set TOKEN or --token)"},
+		{"port", func(c *Config) { c.Port = 70000 }, "port 70000 is out of range"},
+		{"strategy", func(c *Config) { c.Strategy = "psychic" }, `unknown strategy "psychic"`},
+		{"tie-break", func(c *Config) { c.TieBreak = "coin" }, `"coin"`},
+		{"budget", func(c *Config) { c.WriteTimeout = time.Second }, "move_budget must be set below write_timeout"},
+		{"log format", func(c *Config) { c.LogFormat = "xml" }, `log_format "xml"`},
+		{"history backend", func(c *Config) { c.HistoryBackend = "sqlite" }, `history_backend "sqlite"`},
+		{"log level", func(c *Config) { c.LogLevel = "loud" }, `log_level "loud"`},
+	}
+	for _, tt := range tests {
+		bad := c
+		tt.change(&bad)
+		if err := bad.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Validate() = %v, expected %q", tt.name, err, tt.want)
+		}
+	}
+
+	// Every problem is reported at once.
+	err = Default().Validate()
+	for _, key := range []string{"merknera_url", "token", "bot_name", "bot_url"} {
+		if err == nil || !strings.Contains(err.Error(), key+" is required") {
+			t.Errorf("Validate() of the defaults = %v, expected %s to be required", err, key)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	c, _ := Load([]string{"--seed", "7", "--bot-version", "3"}, env(valid()))
+	var buf bytes.Buffer
+	if err := c.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "s3cret") {
+		t.Fatalf("Print() revealed the token:\n%s", buf.String())
+	}
+
+	// The output is a config file reproducing the settings.
+	path := writeFile(t, "printed.json", buf.String())
+	reloaded, err := Load([]string{"--config", path}, env(nil))
+	if err != nil {
+		t.Fatalf("Print() output does not load: %v\n%s", err, buf.String())
+	}
+	if reloaded.Token != "REDACTED" {
+		t.Errorf("reloaded token = %q, expected it to be redacted", reloaded.Token)
+	}
+	reloaded.Token = c.Token
+	if reloaded != c {
+		t.Errorf("reloaded config = %+v, expected %+v", reloaded, c)
+	}
+
+	// An unset token stays visibly unset.
+	buf.Reset()
+	Default().Print(&buf)
+	var printed map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &printed); err != nil {
+		t.Fatal(err)
+	}
+	if printed["token"] != "" || printed["port"] != 3003.0 || printed["bot_version"] != "2.1" {
+		t.Errorf("Print() of the defaults = %v", printed)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	c := Default()
+	c.LogFormat, c.LogLevel = "json", "warn"
+	logger, err := c.Logger(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "gameid", 1)
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log %q is not a single JSON entry: %v", buf.String(), err)
+	}
+	if entry["msg"] != "shown" || entry["gameid"] != 1.0 {
+		t.Errorf("log entry = %v", entry)
+	}
+
+	buf.Reset()
+	c.LogFormat, c.LogLevel = "text", "debug"
+	if logger, err = c.Logger(&buf); err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("hello", "mark", "X")
+	if !strings.Contains(buf.String(), "msg=hello mark=X") {
+		t.Errorf("text log = %q", buf.String())
+	}
+
+	c.LogFormat = "xml"
+	if _, err := c.Logger(&buf); err == nil {
+		t.Error("Logger() accepted format xml")
+	}
+	c.LogFormat, c.LogLevel = "text", "loud"
+	if _, err := c.Logger(&buf); err == nil {
+		t.Error("Logger() accepted level loud")
+	}
+}
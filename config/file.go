@@ -0,0 +1,147 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseJSON reads a JSON object of scalar values.
+func parseJSON(data []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for key, v := range raw {
+		switch v := v.(type) {
+		case string:
+			values[key] = v
+		case json.Number:
+			values[key] = v.String()
+		case bool:
+			values[key] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("%s: value must be a string, number or boolean", key)
+		}
+	}
+	return values, nil
+}
+
+// parseYAML reads a YAML mapping of scalar values, one "key: value" per
+// line. Nested mappings, lists and multi-line strings are not supported.
+func parseYAML(data []byte) (map[string]string, error) {
+	return parseLines(data, ":", func(line string) error {
+		if line == "---" {
+			return errSkip
+		}
+		if line[0] == ' ' || line[0] == '\t' || line[0] == '-' {
+			return fmt.Errorf("nested values are not supported")
+		}
+		return nil
+	})
+}
+
+// parseTOML reads TOML "key = value" pairs. Tables and arrays are not
+// supported.
+func parseTOML(data []byte) (map[string]string, error) {
+	return parseLines(data, "=", func(line string) error {
+		if line[0] == '[' {
+			return fmt.Errorf("tables are not supported")
+		}
+		return nil
+	})
+}
+
+// errSkip tells parseLines to ignore a line.
+var errSkip = fmt.Errorf("skip")
+
+// parseLines reads one key and value per line, separated by sep. Blank
+// lines and comments starting with '#' are ignored; check may reject or
+// skip other lines before they are split.
+func parseLines(data []byte, sep string, check func(line string) error) (map[string]string, error) {
+	values := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if trimmed := strings.TrimSpace(line); trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		if err := check(line); err == errSkip {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		key, value, ok := strings.Cut(line, sep)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key %s value", n, sep)
+		}
+		key = strings.TrimSpace(key)
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", n, key)
+		}
+		v, err := scalar(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		values[key] = v
+	}
+	return values, sc.Err()
+}
+
+// scalar returns the text of a value, unquoting quoted strings and
+// dropping trailing comments from bare ones.
+func scalar(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		end := closingQuote(v)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string %s", v)
+		}
+		if rest := strings.TrimSpace(v[end+1:]); rest != "" && rest[0] != '#' {
+			return "", fmt.Errorf("unexpected %q after string", rest)
+		}
+		return strconv.Unquote(v[:end+1])
+	case strings.HasPrefix(v, "'"):
+		end := strings.IndexByte(v[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string %s", v)
+		}
+		if rest := strings.TrimSpace(v[end+2:]); rest != "" && rest[0] != '#' {
+			return "", fmt.Errorf("unexpected %q after string", rest)
+		}
+		return v[1 : end+1], nil
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	return v, nil
+}
+
+// closingQuote returns the index of the quote ending the double-quoted
+// string at the start of v, or -1.
+func closingQuote(v string) int {
+	for i := 1; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// jsonValue formats the text of f's value as JSON.
+func jsonValue(f field, v string) string {
+	if f.bool || f.number {
+		return v
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+)
+
+const infinity = math.MaxInt32
+
+// Searcher scores moves with a negamax alpha-beta search. Moves are tried
+// in order of the number of winning lines through their square (centre,
+// then corners, then edges on the standard board), with killer moves and
+// the history heuristic promoting moves that caused cutoffs earlier.
+//
+// When given a Table, a Searcher stores the result of every position it
+// searches there and reuses results found by earlier searches, including
+// those run by other Searchers sharing the table.
+//
+// A Searcher keeps its move ordering state between searches and must not
+// be used by more than one goroutine at a time.
+type Searcher struct {
+	table    *Table
+	useTable bool
+	ctx      context.Context
+	aborted  bool
+	nodes    int64
+	killers  [][2]Move
+	history  []int
+}
+
+// NewSearcher returns a Searcher with empty move ordering state that uses
+// table, which may be nil.
+func NewSearcher(table *Table) *Searcher {
+	return &Searcher{table: table}
+}
+
+// Nodes returns the number of positions visited by all searches so far.
+func (s *Searcher) Nodes() int64 {
+	return s.nodes
+}
+
+// Score returns the value MiniMax would return for the same arguments.
+func (s *Searcher) Score(b Board, player Mark, move Move, turn Mark, level int) int {
+	v, _ := s.ScoreContext(context.Background(), b, player, move, turn, level, 0)
+	return v
+}
+
+// ScoreContext is like Score but searches at most depth plies, counting
+// move itself, or to the end of the game if depth is zero. Positions at
+// the depth limit score as draws. If ctx is done before the search
+// finishes, ScoreContext returns false and the score is meaningless.
+func (s *Searcher) ScoreContext(ctx context.Context, b Board, player Mark, move Move, turn Mark, level, depth int) (int, bool) {
+	if ctx.Err() != nil {
+		return 0, false
+	}
+	s.nodes++
+	next := b.Play(move, turn)
+	if gameOver, piece := next.Winner(); gameOver {
+		return terminalScore(b, piece, player, level), true
+	}
+	if depth <= 0 || depth > next.empties()+1 {
+		depth = next.empties() + 1
+	}
+	if depth == 1 {
+		return 0, true
+	}
+	s.ctx, s.aborted = ctx, false
+	if len(s.history) != b.Len() {
+		s.history = make([]int, b.Len())
+	}
+	// Table scores are stored relative to the level they were found at,
+	// which relies on every win scoring above a draw and every loss below.
+	w := winScore(b)
+	s.useTable = s.table != nil && level < w && level > next.empties()-w
+	side := turn.Opponent()
+	v := s.negamax(next, player, side, level-1, 0, depth-1, -infinity, infinity)
+	if side != player {
+		v = -v
+	}
+	return v, !s.aborted
+}
+
+// negamax returns the value of b with side to move from side's point of
+// view, searching at most depth plies. Scores are MiniMax scores for
+// player, negated when side is not player. The search is fail-soft: the
+// result may lie outside alpha, beta and is then a bound on the true value.
+func (s *Searcher) negamax(b Board, player, side Mark, level, ply, depth int, alpha, beta int) int {
+	if s.nodes%1024 == 0 && s.ctx.Err() != nil {
+		s.aborted = true
+	}
+	if s.aborted {
+		return 0
+	}
+	depth = min(depth, b.empties())
+	var key uint64
+	var sym int
+	ttMove := Move(-1)
+	if s.useTable {
+		key, sym = canonicalHash(b, side, player)
+		if e, ok := s.table.probe(key); ok {
+			if e.move >= 0 {
+				ttMove = zobristFor(b.size).inverse[sym][e.move]
+			}
+			if int(e.depth) >= depth {
+				v := fromTable(e.score, side, player, level)
+				switch {
+				case e.bound == Exact:
+					return v
+				case e.bound == Lower && v >= beta:
+					return v
+				case e.bound == Upper && v <= alpha:
+					return v
+				}
+			}
+		}
+	}
+
+	alphaOrig := alpha
+	best, bestMove := -infinity, Move(-1)
+	for _, m := range s.orderMoves(b, ply, ttMove) {
+		s.nodes++
+		child := b.Play(m, side)
+		var v int
+		if child.completesLine(m) {
+			v = terminalScore(b, side, player, level)
+			if side != player {
+				v = -v
+			}
+		} else if depth == 1 {
+			v = 0
+		} else {
+			v = -s.negamax(child, player, side.Opponent(), level-1, ply+1, depth-1, -beta, -alpha)
+			if s.aborted {
+				return 0
+			}
+		}
+		if v > best {
+			best, bestMove = v, m
+		}
+		if v > alpha {
+			alpha = v
+		}
+		if alpha >= beta {
+			s.recordCutoff(m, ply, depth)
+			break
+		}
+	}
+
+	if s.useTable {
+		e := tableEntry{
+			key:   key,
+			score: toTable(best, side, player, level),
+			depth: int16(depth),
+			bound: Exact,
+			move:  int16(zobristFor(b.size).syms[sym][bestMove]),
+		}
+		if best <= alphaOrig {
+			e.bound = Upper
+		} else if best >= beta {
+			e.bound = Lower
+		}
+		s.table.store(e)
+	}
+	return best
+}
+
+// toTable converts a negamax score found at level into the level
+// independent form kept in the table. Draws stay zero; wins and losses are
+// stored relative to level from player's point of view.
+func toTable(v int, side, player Mark, level int) int32 {
+	if side != player {
+		v = -v
+	}
+	if v != 0 {
+		v -= level
+	}
+	return int32(v)
+}
+
+// fromTable reverses toTable for a position reached at level.
+func fromTable(stored int32, side, player Mark, level int) int {
+	v := int(stored)
+	if v != 0 {
+		v += level
+	}
+	if side != player {
+		v = -v
+	}
+	return v
+}
+
+// recordCutoff updates the killer and history tables after move m caused
+// a beta cutoff at ply with depth squares left to fill.
+func (s *Searcher) recordCutoff(m Move, ply, depth int) {
+	for len(s.killers) <= ply {
+		s.killers = append(s.killers, [2]Move{-1, -1})
+	}
+	if s.killers[ply][0] != m {
+		s.killers[ply][1] = s.killers[ply][0]
+		s.killers[ply][0] = m
+	}
+	s.history[m] += depth * depth
+}
+
+// orderMoves returns the legal moves of b, most promising first. The
+// table's best move, if any, is tried before everything else.
+func (s *Searcher) orderMoves(b Board, ply int, ttMove Move) []Move {
+	moves := b.LegalMoves()
+	lines := lineCounts(b.Size())
+	var killers [2]Move
+	if ply < len(s.killers) {
+		killers = s.killers[ply]
+	} else {
+		killers = [2]Move{-1, -1}
+	}
+	key := func(m Move) int {
+		switch m {
+		case ttMove:
+			return math.MaxInt
+		case killers[0]:
+			return math.MaxInt - 1
+		case killers[1]:
+			return math.MaxInt - 2
+		}
+		// No square lies on more than 4*K lines, so history dominates.
+		return s.history[m]*(4*b.size.K+1) + lines[m]
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return key(moves[i]) > key(moves[j])
+	})
+	return moves
+}
+
+var lineCountCache sync.Map
+
+// lineCounts returns, for every square of a board of the given size, the
+// number of K-in-a-row lines passing through it.
+func lineCounts(size Size) []int {
+	if c, ok := lineCountCache.Load(size); ok {
+		return c.([]int)
+	}
+	counts := make([]int, size.Cells())
+	b := size.NewBoard()
+	for i := range counts {
+		row, col := b.Coord(Move(i))
+		for _, d := range directions {
+			if b.inBounds(row+(size.K-1)*d[0], col+(size.K-1)*d[1]) {
+				for n := 0; n < size.K; n++ {
+					counts[b.MoveAt(row+n*d[0], col+n*d[1])]++
+				}
+			}
+		}
+	}
+	lineCountCache.Store(size, counts)
+	return counts
+}
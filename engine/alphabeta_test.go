@@ -0,0 +1,126 @@
+package engine
+
+import "testing"
+
+// reachable returns every position reachable from b with turn to move,
+// excluding finished games.
+func reachable(b Board, turn Mark, seen map[string]bool, positions *[]Board) {
+	key := b.String()
+	if seen[key] {
+		return
+	}
+	seen[key] = true
+	if over, _ := b.Winner(); over {
+		return
+	}
+	*positions = append(*positions, b)
+	for _, m := range b.LegalMoves() {
+		reachable(b.Play(m, turn), turn.Opponent(), seen, positions)
+	}
+}
+
+func sideToMove(b Board) Mark {
+	var xs, os int
+	for i := 0; i < b.Len(); i++ {
+		switch b.At(Move(i)) {
+		case X:
+			xs++
+		case O:
+			os++
+		}
+	}
+	if xs > os {
+		return O
+	}
+	return X
+}
+
+func TestSearcherMatchesMiniMax(t *testing.T) {
+	var positions []Board
+	reachable(NewBoard(), X, map[string]bool{}, &positions)
+
+	s := NewSearcher(nil)
+	for _, b := range positions {
+		turn := sideToMove(b)
+		for _, m := range b.LegalMoves() {
+			for _, player := range []Mark{turn, turn.Opponent()} {
+				want := MiniMax(b, player, m, turn, 0)
+				if got := s.Score(b, player, m, turn, 0); got != want {
+					t.Fatalf("Score() for %s at %d = %d, MiniMax = %d\n%v", player, m, got, want, b)
+				}
+			}
+		}
+	}
+}
+
+func TestSearcherMatchesMiniMaxAtLevel(t *testing.T) {
+	b := FromStrings([]string{"X", "", "", "", "O", "", "", "", ""})
+	s := NewSearcher(nil)
+	for _, m := range b.LegalMoves() {
+		for _, level := range []int{-3, 0, 5} {
+			want := MiniMax(b, X, m, X, level)
+			if got := s.Score(b, X, m, X, level); got != want {
+				t.Errorf("Score() at %d level %d = %d, MiniMax = %d", m, level, got, want)
+			}
+		}
+	}
+}
+
+func TestSearcherVisitsFewerNodes(t *testing.T) {
+	b := NewBoard()
+	var miniMaxNodes int64
+	s := NewSearcher(nil)
+	for _, m := range b.LegalMoves() {
+		minimax(b, X, m, X, 0, &miniMaxNodes)
+		s.Score(b, X, m, X, 0)
+	}
+	if s.Nodes() >= miniMaxNodes/10 {
+		t.Errorf("Searcher visited %d nodes, expected well under MiniMax's %d", s.Nodes(), miniMaxNodes)
+	}
+}
+
+func TestLineCounts(t *testing.T) {
+	want := []int{3, 2, 3, 2, 4, 2, 3, 2, 3}
+	got := lineCounts(Standard)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("lineCounts(Standard) = %v, expected %v", got, want)
+		}
+	}
+}
+
+func benchmarkPositions() []Board {
+	return []Board{
+		NewBoard(),
+		FromStrings([]string{"X", "", "", "", "", "", "", "", ""}),
+		FromStrings([]string{"", "", "", "", "X", "", "", "", "O"}),
+	}
+}
+
+func BenchmarkMiniMax(b *testing.B) {
+	var nodes int64
+	for i := 0; i < b.N; i++ {
+		for _, pos := range benchmarkPositions() {
+			turn := sideToMove(pos)
+			for _, m := range pos.LegalMoves() {
+				minimax(pos, turn, m, turn, 0, &nodes)
+			}
+		}
+	}
+	b.ReportMetric(float64(nodes)/float64(b.N), "nodes/op")
+}
+
+func BenchmarkAlphaBeta(b *testing.B) {
+	var nodes int64
+	for i := 0; i < b.N; i++ {
+		s := NewSearcher(nil)
+		for _, pos := range benchmarkPositions() {
+			turn := sideToMove(pos)
+			for _, m := range pos.LegalMoves() {
+				s.Score(pos, turn, m, turn, 0)
+			}
+		}
+		nodes += s.Nodes()
+	}
+	b.ReportMetric(float64(nodes)/float64(b.N), "nodes/op")
+}
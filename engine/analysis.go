@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Outcome classifies a score as the result of the game with best play.
+type Outcome struct {
+	// Result is "win", "draw" or "loss" for the player the score belongs
+	// to, or "unknown" when a depth-limited search stopped before the end
+	// of the game.
+	Result string
+	// Plies counts the moves of both sides, starting with the move scored,
+	// up to and including the one that decides a win or loss.
+	Plies int
+}
+
+func (o Outcome) String() string {
+	switch {
+	case o.Plies == 1:
+		return o.Result + " in 1 ply"
+	case o.Plies > 1:
+		return fmt.Sprintf("%s in %d plies", o.Result, o.Plies)
+	}
+	return o.Result
+}
+
+// ScoreOutcome returns the outcome of a move on b given its MiniMax score
+// at level zero. complete reports whether the search that found the score
+// reached the end of the game, without which a zero score only means that
+// no result was found.
+func ScoreOutcome(b Board, score int, complete bool) Outcome {
+	w := winScore(b)
+	switch {
+	case score > 0:
+		return Outcome{Result: "win", Plies: w - score + 1}
+	case score < 0:
+		return Outcome{Result: "loss", Plies: -score - w + 1}
+	case complete:
+		return Outcome{Result: "draw"}
+	}
+	return Outcome{Result: "unknown"}
+}
+
+// MoveAnalysis is the score of one legal move.
+type MoveAnalysis struct {
+	Move    Move
+	Score   int
+	Outcome Outcome
+}
+
+// Analysis explains a search: how every legal move scored, how the game
+// is expected to continue and how much work that took.
+type Analysis struct {
+	Move    Move
+	Score   int
+	Outcome Outcome
+	// Moves holds every legal move, best first and lowest numbered first
+	// among equal scores.
+	Moves []MoveAnalysis
+	// PV is the principal variation, if the search was asked for one.
+	PV       []Move
+	Nodes    int64
+	Depth    int
+	Complete bool
+	FromBook bool
+}
+
+// Analysis returns the analysis of r, a search of b.
+func (r Result) Analysis(b Board) *Analysis {
+	a := &Analysis{
+		Move:     r.Move,
+		Score:    r.Score,
+		Outcome:  ScoreOutcome(b, r.Score, r.Complete),
+		PV:       r.PV,
+		Nodes:    r.Nodes,
+		Depth:    r.Depth,
+		Complete: r.Complete,
+		FromBook: r.FromBook,
+	}
+	for m, score := range r.Scores {
+		a.Moves = append(a.Moves, MoveAnalysis{Move: m, Score: score, Outcome: ScoreOutcome(b, score, r.Complete)})
+	}
+	sort.Slice(a.Moves, func(i, j int) bool {
+		if a.Moves[i].Score != a.Moves[j].Score {
+			return a.Moves[i].Score > a.Moves[j].Score
+		}
+		return a.Moves[i].Move < a.Moves[j].Move
+	})
+	return a
+}
+
+// principalVariation returns the line starting with res.Move in which each
+// side in turn plays its best move, searched as deep as res was. It stops
+// at the end of the game or, after the first move, when ctx is done.
+func principalVariation(ctx context.Context, b Board, player Mark, res Result, opts Options) []Move {
+	opts.Analyze = false
+	opts.TieBreak = nil
+	opts.Budget = 0
+
+	pv := []Move{res.Move}
+	next, side := b.Play(res.Move, player), player.Opponent()
+	for depth := res.Depth - 1; depth > 0 && ctx.Err() == nil; depth-- {
+		if over, _ := next.Winner(); over {
+			break
+		}
+		if !res.Complete {
+			opts.MaxDepth = depth
+		}
+		m := SearchContext(ctx, next, side, opts).Move
+		pv = append(pv, m)
+		next, side = next.Play(m, side), side.Opponent()
+	}
+	return pv
+}
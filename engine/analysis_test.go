@@ -0,0 +1,90 @@
+package engine
+
+import "testing"
+
+func TestScoreOutcome(t *testing.T) {
+	b := NewBoard()
+	tests := []struct {
+		score    int
+		complete bool
+		want     string
+	}{
+		{10, true, "win in 1 ply"},
+		{8, true, "win in 3 plies"},
+		{-11, true, "loss in 2 plies"},
+		{-11, false, "loss in 2 plies"},
+		{0, true, "draw"},
+		{0, false, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := ScoreOutcome(b, tt.score, tt.complete).String(); got != tt.want {
+			t.Errorf("ScoreOutcome(%d, %v) = %q, expected %q", tt.score, tt.complete, got, tt.want)
+		}
+	}
+
+	big := Size{Width: 4, Height: 4, K: 3}.NewBoard()
+	if got := ScoreOutcome(big, winScore(big)-2, true); got != (Outcome{Result: "win", Plies: 3}) {
+		t.Errorf("ScoreOutcome() on 4x4 = %v, expected a win in 3", got)
+	}
+}
+
+func TestAnalysis(t *testing.T) {
+	b := FromStrings([]string{"X", "X", "", "O", "O", "", "", "", ""})
+	res := Search(b, O, Options{Analyze: true})
+	a := res.Analysis(b)
+
+	if a.Move != 5 || a.Outcome.String() != "win in 1 ply" {
+		t.Errorf("Analysis() = %d (%v), expected the win at 5", a.Move, a.Outcome)
+	}
+	if len(a.PV) != 1 || a.PV[0] != 5 {
+		t.Errorf("Analysis() PV = %v, expected [5]", a.PV)
+	}
+	if len(a.Moves) != len(b.LegalMoves()) {
+		t.Fatalf("Analysis() scored %d moves, expected %d", len(a.Moves), len(b.LegalMoves()))
+	}
+	if a.Moves[0].Move != 5 {
+		t.Errorf("Analysis() best move = %d, expected 5", a.Moves[0].Move)
+	}
+	for i := 1; i < len(a.Moves); i++ {
+		if a.Moves[i].Score > a.Moves[i-1].Score {
+			t.Errorf("Analysis() moves out of order: %v", a.Moves)
+		}
+	}
+	// Moves that neither win nor block let X complete the top row.
+	if got := a.Moves[len(a.Moves)-1].Outcome.String(); got != "loss in 2 plies" {
+		t.Errorf("Analysis() worst outcome = %q, expected a loss in 2", got)
+	}
+	if a.Nodes == 0 {
+		t.Errorf("Analysis() reported no nodes searched")
+	}
+}
+
+func TestPrincipalVariation(t *testing.T) {
+	for _, book := range []*Book{nil, StandardBook()} {
+		res := Search(NewBoard(), X, Options{Analyze: true, Book: book})
+		if len(res.PV) != 9 {
+			t.Fatalf("PV = %v, expected a full game", res.PV)
+		}
+		// Each move of the line is the best reply to the moves before it.
+		b, side := NewBoard(), X
+		for _, m := range res.PV {
+			if want := Search(b, side, Options{}).Move; m != want {
+				t.Errorf("PV %v plays %d after %v, expected %d", res.PV, m, b.Strings(), want)
+			}
+			b, side = b.Play(m, side), side.Opponent()
+		}
+		if over, winner := b.Winner(); !over || winner != Empty {
+			t.Errorf("PV %v does not end in a draw", res.PV)
+		}
+	}
+}
+
+func TestPrincipalVariationDepthLimited(t *testing.T) {
+	res := Search(NewBoard(), X, Options{Analyze: true, MaxDepth: 3})
+	if len(res.PV) != 3 {
+		t.Errorf("PV = %v, expected 3 moves", res.PV)
+	}
+	if got := res.Analysis(NewBoard()).Outcome.Result; got != "unknown" {
+		t.Errorf("depth limited outcome = %q, expected unknown", got)
+	}
+}
@@ -0,0 +1,307 @@
+// Package engine implements tic-tac-toe game rules and move search.
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mark is the symbol occupying a square. The zero value is an empty square.
+type Mark string
+
+const (
+	Empty Mark = ""
+	X     Mark = "X"
+	O     Mark = "O"
+)
+
+// Opponent returns the mark that plays against m.
+func (m Mark) Opponent() Mark {
+	if m == X {
+		return O
+	}
+	return X
+}
+
+// Move is a board position, numbered left to right, top to bottom from 0.
+type Move int
+
+// Size describes an m,n,k game: a Width×Height board won by the first
+// player to place K marks in a horizontal, vertical or diagonal line.
+type Size struct {
+	Width  int
+	Height int
+	K      int
+}
+
+// Standard is the classic 3×3 three-in-a-row game.
+var Standard = Size{Width: 3, Height: 3, K: 3}
+
+// Cells returns the number of squares on a board of this size.
+func (s Size) Cells() int {
+	return s.Width * s.Height
+}
+
+// Valid reports whether s describes a playable game.
+func (s Size) Valid() error {
+	if s.Width < 1 || s.Height < 1 {
+		return fmt.Errorf("engine: invalid board dimensions %dx%d", s.Width, s.Height)
+	}
+	if s.K < 1 || (s.K > s.Width && s.K > s.Height) {
+		return fmt.Errorf("engine: win length %d does not fit a %dx%d board", s.K, s.Width, s.Height)
+	}
+	return nil
+}
+
+// String formats the size as "WxH/K".
+func (s Size) String() string {
+	return fmt.Sprintf("%dx%d/%d", s.Width, s.Height, s.K)
+}
+
+// NewBoard returns an empty board of this size.
+func (s Size) NewBoard() Board {
+	return Board{size: s, cells: make([]Mark, s.Cells())}
+}
+
+// FromStrings builds a board of this size from the wire representation
+// used by the game server, where "" is an empty square.
+func (s Size) FromStrings(gameState []string) Board {
+	cells := make([]Mark, len(gameState))
+	for i, str := range gameState {
+		cells[i] = Mark(str)
+	}
+	return Board{size: s, cells: cells}
+}
+
+// ParseStrings is like FromStrings but checks that the size is valid and
+// that gameState holds one "", "X" or "O" for every square.
+func (s Size) ParseStrings(gameState []string) (Board, error) {
+	if err := s.Valid(); err != nil {
+		return Board{}, err
+	}
+	if len(gameState) != s.Cells() {
+		return Board{}, fmt.Errorf("engine: %s board needs %d squares, got %d", s, s.Cells(), len(gameState))
+	}
+	for i, str := range gameState {
+		switch Mark(str) {
+		case Empty, X, O:
+		default:
+			return Board{}, fmt.Errorf("engine: square %d holds %q, expected \"\", \"X\" or \"O\"", i, str)
+		}
+	}
+	return s.FromStrings(gameState), nil
+}
+
+// Board is a game position. Boards are values: Play returns a new board
+// and never modifies the receiver.
+type Board struct {
+	size  Size
+	cells []Mark
+}
+
+// NewBoard returns an empty 3×3 board.
+func NewBoard() Board {
+	return Standard.NewBoard()
+}
+
+// FromStrings builds a 3×3 board from the wire representation used by the
+// game server, where "" is an empty square.
+func FromStrings(gameState []string) Board {
+	return Standard.FromStrings(gameState)
+}
+
+// Size returns the dimensions and win length of the board.
+func (b Board) Size() Size {
+	return b.size
+}
+
+// Strings returns the wire representation of the board.
+func (b Board) Strings() []string {
+	gs := make([]string, len(b.cells))
+	for i, m := range b.cells {
+		gs[i] = string(m)
+	}
+	return gs
+}
+
+// Len returns the number of squares on the board.
+func (b Board) Len() int {
+	return len(b.cells)
+}
+
+// At returns the mark at position m.
+func (b Board) At(m Move) Mark {
+	return b.cells[m]
+}
+
+// Play returns a copy of the board with mark placed at position m.
+func (b Board) Play(m Move, mark Mark) Board {
+	next := b.clone()
+	next.cells[m] = mark
+	return next
+}
+
+// clone returns a copy of b that does not share its squares.
+func (b Board) clone() Board {
+	cells := make([]Mark, len(b.cells))
+	copy(cells, b.cells)
+	return Board{size: b.size, cells: cells}
+}
+
+// Coord returns the zero-based row and column of position m.
+func (b Board) Coord(m Move) (row, col int) {
+	return int(m) / b.size.Width, int(m) % b.size.Width
+}
+
+// MoveAt returns the position at row and column.
+func (b Board) MoveAt(row, col int) Move {
+	return Move(row*b.size.Width + col)
+}
+
+// LegalMoves returns the empty positions in ascending order.
+func (b Board) LegalMoves() []Move {
+	var moves []Move
+	for i, m := range b.cells {
+		if m == Empty {
+			moves = append(moves, Move(i))
+		}
+	}
+	return moves
+}
+
+// directions holds the row and column steps of the four line orientations:
+// horizontal, vertical, diagonal and anti-diagonal.
+var directions = [4][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+
+// Winner reports whether the game is over and, if so, which mark won.
+// A finished game with an Empty winner is a draw.
+func (b Board) Winner() (over bool, winner Mark) {
+	full := true
+	for i, m := range b.cells {
+		if m == Empty {
+			full = false
+			continue
+		}
+		row, col := b.Coord(Move(i))
+		for _, d := range directions {
+			if b.lineFrom(row, col, d[0], d[1], m) {
+				return true, m
+			}
+		}
+	}
+	return full, Empty
+}
+
+// WinningLine returns the squares of the first line of K found, in order
+// along the line, or nil if nobody has won.
+func (b Board) WinningLine() []Move {
+	for i, m := range b.cells {
+		if m == Empty {
+			continue
+		}
+		row, col := b.Coord(Move(i))
+		for _, d := range directions {
+			if !b.lineFrom(row, col, d[0], d[1], m) {
+				continue
+			}
+			line := make([]Move, b.size.K)
+			for n := range line {
+				line[n] = b.MoveAt(row+n*d[0], col+n*d[1])
+			}
+			return line
+		}
+	}
+	return nil
+}
+
+// HasLine reports whether mark has a line of K anywhere on the board.
+func (b Board) HasLine(mark Mark) bool {
+	for i, m := range b.cells {
+		if m != mark {
+			continue
+		}
+		row, col := b.Coord(Move(i))
+		for _, d := range directions {
+			if b.lineFrom(row, col, d[0], d[1], mark) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lineFrom reports whether K consecutive squares starting at row, col and
+// stepping by dr, dc all hold mark.
+func (b Board) lineFrom(row, col, dr, dc int, mark Mark) bool {
+	w := b.size.Width
+	for n := 0; n < b.size.K; n++ {
+		r, c := row+n*dr, col+n*dc
+		if !b.inBounds(r, c) || b.cells[r*w+c] != mark {
+			return false
+		}
+	}
+	return true
+}
+
+// completesLine reports whether the mark at m is part of a line of K.
+func (b Board) completesLine(m Move) bool {
+	mark := b.cells[m]
+	row, col := b.Coord(m)
+	for _, d := range directions {
+		n := 1
+		for r, c := row+d[0], col+d[1]; b.inBounds(r, c) && b.cells[b.MoveAt(r, c)] == mark; r, c = r+d[0], c+d[1] {
+			n++
+		}
+		for r, c := row-d[0], col-d[1]; b.inBounds(r, c) && b.cells[b.MoveAt(r, c)] == mark; r, c = r-d[0], c-d[1] {
+			n++
+		}
+		if n >= b.size.K {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of squares holding mark.
+func (b Board) Count(mark Mark) int {
+	n := 0
+	for _, c := range b.cells {
+		if c == mark {
+			n++
+		}
+	}
+	return n
+}
+
+// empties returns the number of unoccupied squares.
+func (b Board) empties() int {
+	return b.Count(Empty)
+}
+
+// inBounds reports whether row, col lies on the board.
+func (b Board) inBounds(row, col int) bool {
+	return row >= 0 && row < b.size.Height && col >= 0 && col < b.size.Width
+}
+
+// IsDraw reports whether the board is full with no winner.
+func (b Board) IsDraw() bool {
+	over, winner := b.Winner()
+	return over && winner == Empty
+}
+
+// String renders the board as a grid, one row per line.
+func (b Board) String() string {
+	var sb strings.Builder
+	for num, s := range b.cells {
+		val := string(s)
+		if s == Empty {
+			val = " "
+		}
+		if (num+1)%b.size.Width == 0 {
+			sb.WriteString(val + " \n")
+		} else {
+			sb.WriteString(val + " | ")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,208 @@
+package engine
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+//go:generate go run ../cmd/bookgen -o book.bin
+
+//go:embed book.bin
+var standardBookData []byte
+
+const (
+	bookMagic   = "TTTB"
+	bookVersion = 1
+	// bookRecordLen is the size of one position: its code followed by a
+	// score for each square.
+	bookRecordLen = 2 + 9
+	// bookOccupied marks a square that is not a legal move.
+	bookOccupied = math.MinInt8
+)
+
+// Book holds the MiniMax score of every legal move in every reachable
+// position of the standard game, so that positions can be answered
+// without searching.
+//
+// Positions are stored as played with X moving first. Lookup swaps the
+// marks of games where O moved first, which are otherwise identical.
+type Book struct {
+	positions map[uint16][9]int8
+}
+
+var (
+	standardBook     *Book
+	standardBookOnce sync.Once
+)
+
+// StandardBook returns the book embedded in the package, generated by
+// cmd/bookgen. It is decoded on first use.
+func StandardBook() *Book {
+	standardBookOnce.Do(func() {
+		standardBook = &Book{}
+		if err := standardBook.UnmarshalBinary(standardBookData); err != nil {
+			panic("engine: embedded book is corrupt: " + err.Error())
+		}
+	})
+	return standardBook
+}
+
+// GenerateBook solves every position reachable from the empty standard
+// board with X moving first, scoring each legal move with MiniMax.
+func GenerateBook() *Book {
+	bk := &Book{positions: make(map[uint16][9]int8)}
+	var visit func(b Board, turn Mark)
+	visit = func(b Board, turn Mark) {
+		code := bookCode(b, false)
+		if _, seen := bk.positions[code]; seen {
+			return
+		}
+		if over, _ := b.Winner(); over {
+			return
+		}
+		var scores [9]int8
+		for i := range scores {
+			scores[i] = bookOccupied
+		}
+		for _, m := range b.LegalMoves() {
+			scores[m] = int8(MiniMax(b, turn, m, turn, 0))
+		}
+		bk.positions[code] = scores
+		for _, m := range b.LegalMoves() {
+			visit(b.Play(m, turn), turn.Opponent())
+		}
+	}
+	visit(NewBoard(), X)
+	return bk
+}
+
+// Len returns the number of positions in the book.
+func (bk *Book) Len() int {
+	return len(bk.positions)
+}
+
+// Lookup returns the scores of player's legal moves on b if the book
+// contains the position.
+func (bk *Book) Lookup(b Board, player Mark) (map[Move]int, bool) {
+	if b.Size() != Standard || b.Len() != 9 || (player != X && player != O) {
+		return nil, false
+	}
+	var mine, theirs int
+	for _, m := range b.cells {
+		switch m {
+		case Empty:
+		case player:
+			mine++
+		case player.Opponent():
+			theirs++
+		default:
+			return nil, false
+		}
+	}
+	// With X moving first, X moves when the counts are level and O moves
+	// when it is one behind.
+	var swap bool
+	switch theirs - mine {
+	case 0:
+		swap = player == O
+	case 1:
+		swap = player == X
+	default:
+		return nil, false
+	}
+	stored, ok := bk.positions[bookCode(b, swap)]
+	if !ok {
+		return nil, false
+	}
+	scores := make(map[Move]int)
+	for i, v := range stored {
+		if v != bookOccupied {
+			scores[Move(i)] = int(v)
+		}
+	}
+	return scores, true
+}
+
+// bookCode encodes a standard board as a base 3 number, optionally with X
+// and O swapped.
+func bookCode(b Board, swap bool) uint16 {
+	var code uint16
+	for i := len(b.cells) - 1; i >= 0; i-- {
+		code *= 3
+		switch m := b.cells[i]; {
+		case m == X && !swap, m == O && swap:
+			code += 1
+		case m == O && !swap, m == X && swap:
+			code += 2
+		}
+	}
+	return code
+}
+
+// bookBoard decodes a position code.
+func bookBoard(code uint16) Board {
+	b := NewBoard()
+	for i := range b.cells {
+		switch code % 3 {
+		case 1:
+			b.cells[i] = X
+		case 2:
+			b.cells[i] = O
+		}
+		code /= 3
+	}
+	return b
+}
+
+// MarshalBinary encodes the book as a header followed by one fixed size
+// record per position, in ascending order of position code.
+func (bk *Book) MarshalBinary() ([]byte, error) {
+	if len(bk.positions) > math.MaxUint16 {
+		return nil, errors.New("engine: book has too many positions")
+	}
+	data := make([]byte, 0, len(bookMagic)+3+len(bk.positions)*bookRecordLen)
+	data = append(data, bookMagic...)
+	data = append(data, bookVersion)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(bk.positions)))
+	for code := 0; code <= math.MaxUint16; code++ {
+		scores, ok := bk.positions[uint16(code)]
+		if !ok {
+			continue
+		}
+		data = binary.LittleEndian.AppendUint16(data, uint16(code))
+		for _, v := range scores {
+			data = append(data, byte(v))
+		}
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes a book encoded by MarshalBinary.
+func (bk *Book) UnmarshalBinary(data []byte) error {
+	header := len(bookMagic) + 3
+	if len(data) < header || string(data[:len(bookMagic)]) != bookMagic {
+		return errors.New("engine: not a book")
+	}
+	if v := data[len(bookMagic)]; v != bookVersion {
+		return fmt.Errorf("engine: unsupported book version %d", v)
+	}
+	n := int(binary.LittleEndian.Uint16(data[len(bookMagic)+1:]))
+	records := data[header:]
+	if len(records) != n*bookRecordLen {
+		return fmt.Errorf("engine: book has %d bytes of records, expected %d", len(records), n*bookRecordLen)
+	}
+	bk.positions = make(map[uint16][9]int8, n)
+	for i := 0; i < n; i++ {
+		rec := records[i*bookRecordLen:]
+		var scores [9]int8
+		for j := range scores {
+			scores[j] = int8(rec[2+j])
+		}
+		bk.positions[binary.LittleEndian.Uint16(rec)] = scores
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStandardBookIsUpToDate(t *testing.T) {
+	want, err := GenerateBook().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(standardBookData, want) {
+		t.Fatal("embedded book.bin differs from GenerateBook(); run go generate ./engine")
+	}
+	if n := StandardBook().Len(); n != 4520 {
+		t.Errorf("StandardBook().Len() = %d, expected the 4520 unfinished reachable positions", n)
+	}
+}
+
+func TestBookAgreesWithSearch(t *testing.T) {
+	bk := StandardBook()
+	table := NewTable(0)
+	for code := range bk.positions {
+		b := bookBoard(code)
+		player := sideToMove(b)
+		got, ok := bk.Lookup(b, player)
+		if !ok {
+			t.Fatalf("Lookup() missed position %d\n%v", code, b)
+		}
+		want := Search(b, player, Options{Table: table}).Scores
+		if len(got) != len(want) {
+			t.Fatalf("Lookup() returned %d moves, search found %d\n%v", len(got), len(want), b)
+		}
+		for m, score := range want {
+			if got[m] != score {
+				t.Fatalf("Lookup() score for %d = %d, search scored %d\n%v", m, got[m], score, b)
+			}
+		}
+	}
+}
+
+func TestBookLookupWhenOMovesFirst(t *testing.T) {
+	// O opened in the corner and X replied in the centre.
+	b := FromStrings([]string{"O", "", "", "", "X", "", "", "", ""})
+	got, ok := StandardBook().Lookup(b, O)
+	if !ok {
+		t.Fatal("Lookup() missed a position from a game O started")
+	}
+	want := ScoreMoves(b, O)
+	for m, score := range want {
+		if got[m] != score {
+			t.Errorf("Lookup() score for %d = %d, expected %d", m, got[m], score)
+		}
+	}
+}
+
+func TestBookLookupRejects(t *testing.T) {
+	bk := StandardBook()
+	tests := []struct {
+		name   string
+		board  Board
+		player Mark
+	}{
+		{"Larger board", Size{Width: 4, Height: 4, K: 3}.NewBoard(), X},
+		{"Unknown mark", FromStrings([]string{"x", "", "", "", "", "", "", "", ""}), O},
+		{"Impossible counts", FromStrings([]string{"X", "X", "", "", "", "", "", "", ""}), O},
+		{"Finished game", FromStrings([]string{"X", "X", "X", "O", "O", "", "", "", ""}), O},
+		{"Unknown player", NewBoard(), Mark("Z")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := bk.Lookup(tt.board, tt.player); ok {
+				t.Errorf("Lookup() found a position it should not contain")
+			}
+		})
+	}
+}
+
+func TestBookMarshalRoundTrip(t *testing.T) {
+	data, err := StandardBook().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bk Book
+	if err := bk.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() = %v", err)
+	}
+	if bk.Len() != StandardBook().Len() {
+		t.Errorf("round trip Len() = %d, expected %d", bk.Len(), StandardBook().Len())
+	}
+
+	for _, bad := range [][]byte{nil, []byte("nope"), data[:len(data)-1], append([]byte("TTTB\x02"), data[5:]...)} {
+		if err := new(Book).UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary(%.8q) = nil, expected error", bad)
+		}
+	}
+}
+
+func TestSearchUsesBook(t *testing.T) {
+	b := FromStrings([]string{"X", "", "", "", "", "", "", "", ""})
+	res := Search(b, O, Options{Book: StandardBook()})
+	if !res.FromBook || !res.Complete {
+		t.Errorf("Search() FromBook = %v Complete = %v, expected a book answer", res.FromBook, res.Complete)
+	}
+	if res.Move != 4 {
+		t.Errorf("Search() = %d, expected the only drawing reply 4", res.Move)
+	}
+
+	larger := Size{Width: 4, Height: 4, K: 4}.FromStrings(make([]string, 16)).Play(0, X)
+	if res := Search(larger, O, Options{Book: StandardBook(), MaxDepth: 1}); res.FromBook {
+		t.Errorf("Search() answered a 4x4 board from the book")
+	}
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// Difficulty describes how far a Handicap strategy falls short of perfect
+// play.
+type Difficulty struct {
+	Name string
+	// BlunderRate is the probability of playing a random legal move
+	// instead of searching.
+	BlunderRate float64
+	// SuboptimalRate is the probability of playing the best move that
+	// scored worse than the search's choice, when there is one.
+	SuboptimalRate float64
+	// MaxDepth limits the search to this many plies. Zero searches to
+	// the end of the game.
+	MaxDepth int
+}
+
+// The built-in difficulty levels.
+var (
+	Easy    = Difficulty{Name: "easy", BlunderRate: 0.5, SuboptimalRate: 0.2, MaxDepth: 1}
+	Medium  = Difficulty{Name: "medium", BlunderRate: 0.2, SuboptimalRate: 0.2, MaxDepth: 2}
+	Hard    = Difficulty{Name: "hard", BlunderRate: 0.05, SuboptimalRate: 0.1, MaxDepth: 4}
+	Perfect = Difficulty{Name: "perfect"}
+)
+
+// Difficulties lists the built-in levels from weakest to strongest.
+var Difficulties = []Difficulty{Easy, Medium, Hard, Perfect}
+
+// ParseDifficulty returns the built-in level with the given name.
+func ParseDifficulty(name string) (Difficulty, error) {
+	for _, d := range Difficulties {
+		if d.Name == name {
+			return d, nil
+		}
+	}
+	return Difficulty{}, fmt.Errorf("engine: unknown difficulty %q", name)
+}
+
+// Handicap plays at a chosen Difficulty by mixing random moves, second
+// best moves and depth limited search.
+type Handicap struct {
+	level  Difficulty
+	search Options
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewHandicap returns a strategy playing at level, searching with opts and
+// making random choices determined by seed.
+func NewHandicap(level Difficulty, opts Options, seed int64) *Handicap {
+	opts.MaxDepth = level.MaxDepth
+	return &Handicap{level: level, search: opts, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Name returns the name of the strategy's difficulty level.
+func (s *Handicap) Name() string { return s.level.Name }
+
+func (s *Handicap) Choose(ctx context.Context, b Board, mark Mark) (Decision, error) {
+	moves, err := legalMoves(b)
+	if err != nil {
+		return Decision{}, err
+	}
+	s.mu.Lock()
+	blunder := s.rng.Float64() < s.level.BlunderRate
+	suboptimal := s.rng.Float64() < s.level.SuboptimalRate
+	random := moves[s.rng.Intn(len(moves))]
+	s.mu.Unlock()
+
+	if blunder {
+		return Decision{Move: random, Diagnostics: map[string]any{"play": "blunder"}}, nil
+	}
+	res := SearchContext(ctx, b, mark, s.search)
+	d := Decision{
+		Move:        res.Move,
+		Diagnostics: map[string]any{"play": "search", "score": res.Score, "depth": res.Depth},
+	}
+	if s.search.Analyze {
+		d.Analysis = res.Analysis(b)
+	}
+	if suboptimal {
+		if m, score, ok := secondBest(res.Scores, res.Score); ok {
+			d.Move = m
+			d.Diagnostics["play"] = "suboptimal"
+			d.Diagnostics["score"] = score
+		}
+	}
+	return d, nil
+}
+
+// secondBest returns the highest scoring move that scores below best.
+func secondBest(scores map[Move]int, best int) (Move, int, bool) {
+	worse := make(map[Move]int)
+	for m, score := range scores {
+		if score < best {
+			worse[m] = score
+		}
+	}
+	if len(worse) == 0 {
+		return 0, 0, false
+	}
+	m, score := pickBest(worse)
+	return m, score, true
+}
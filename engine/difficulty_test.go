@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"context"
+	"testing"
+)
+
+// playGame plays a standard game between x and o, X moving first, and
+// returns the winner, or Empty for a draw.
+func playGame(t *testing.T, x, o Strategy) Mark {
+	t.Helper()
+	b := NewBoard()
+	players := map[Mark]Strategy{X: x, O: o}
+	for turn := X; ; turn = turn.Opponent() {
+		if over, winner := b.Winner(); over {
+			return winner
+		}
+		d, err := players[turn].Choose(context.Background(), b, turn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.At(d.Move) != Empty {
+			t.Fatalf("%s played occupied square %d", players[turn].Name(), d.Move)
+		}
+		b = b.Play(d.Move, turn)
+	}
+}
+
+type matchResult struct {
+	wins, draws, losses int
+}
+
+func (r matchResult) winRate() float64 {
+	return float64(r.wins) / float64(r.wins+r.draws+r.losses)
+}
+
+// playMatch plays games between s and a seeded random player, alternating
+// who moves first.
+func playMatch(t *testing.T, s Strategy, games int) matchResult {
+	t.Helper()
+	random := NewRandom(2024)
+	var r matchResult
+	for i := 0; i < games; i++ {
+		mark, x, o := X, s, Strategy(random)
+		if i%2 == 1 {
+			mark, x, o = O, random, s
+		}
+		switch playGame(t, x, o) {
+		case mark:
+			r.wins++
+		case Empty:
+			r.draws++
+		default:
+			r.losses++
+		}
+	}
+	return r
+}
+
+func TestDifficultyWinRatesAgainstRandom(t *testing.T) {
+	const games = 1000
+	// Win rate bounds for each level against a random player. A random
+	// player wins about 44% of its games against itself.
+	bounds := map[string][2]float64{
+		"easy":    {0.40, 0.70},
+		"medium":  {0.55, 0.85},
+		"hard":    {0.70, 0.95},
+		"perfect": {0.85, 1.00},
+	}
+	var previous float64
+	for _, level := range Difficulties {
+		r := playMatch(t, NewHandicap(level, Options{Book: StandardBook()}, 5), games)
+		rate := r.winRate()
+		t.Logf("%s: %d wins, %d draws, %d losses (win rate %.2f)", level.Name, r.wins, r.draws, r.losses, rate)
+
+		if b := bounds[level.Name]; rate < b[0] || rate > b[1] {
+			t.Errorf("%s win rate %.2f outside [%.2f, %.2f]", level.Name, rate, b[0], b[1])
+		}
+		if rate < previous {
+			t.Errorf("%s win rate %.2f below the previous level's %.2f", level.Name, rate, previous)
+		}
+		if level == Perfect && r.losses > 0 {
+			t.Errorf("perfect lost %d games", r.losses)
+		}
+		previous = rate
+	}
+}
+
+func TestParseDifficulty(t *testing.T) {
+	for _, level := range Difficulties {
+		got, err := ParseDifficulty(level.Name)
+		if err != nil || got != level {
+			t.Errorf("ParseDifficulty(%q) = %v, %v", level.Name, got, err)
+		}
+		s, err := NewStrategy(level.Name, StrategyOptions{})
+		if err != nil || s.Name() != level.Name {
+			t.Errorf("NewStrategy(%q) = %v, %v", level.Name, s, err)
+		}
+	}
+	if _, err := ParseDifficulty("impossible"); err == nil {
+		t.Error("ParseDifficulty() accepted an unknown level")
+	}
+}
+
+func TestHandicapBlunders(t *testing.T) {
+	always := Difficulty{Name: "always", BlunderRate: 1}
+	s := NewHandicap(always, Options{}, 3)
+	d, err := s.Choose(context.Background(), FromStrings([]string{"X", "X", "", "O", "O", "", "", "", ""}), O)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Diagnostics["play"] != "blunder" {
+		t.Errorf("Choose() play = %v, expected a blunder", d.Diagnostics["play"])
+	}
+}
+
+func TestHandicapSuboptimal(t *testing.T) {
+	worse := Difficulty{Name: "worse", SuboptimalRate: 1}
+	s := NewHandicap(worse, Options{}, 3)
+	// O wins at 5; anything else scores lower.
+	d, err := s.Choose(context.Background(), FromStrings([]string{"X", "X", "", "O", "O", "", "X", "", ""}), O)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Move == 5 || d.Diagnostics["play"] != "suboptimal" {
+		t.Errorf("Choose() = %d (%v), expected a suboptimal move", d.Move, d.Diagnostics["play"])
+	}
+}
@@ -0,0 +1,401 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test Board.Winner
+func TestBoardWinner(t *testing.T) {
+	tests := []struct {
+		name      string
+		gameState []string
+		expected  bool
+		winner    string
+	}{
+		{
+			name:      "Empty board",
+			gameState: []string{"", "", "", "", "", "", "", "", ""},
+			expected:  false,
+			winner:    "",
+		},
+		{
+			name:      "X wins horizontally (top row)",
+			gameState: []string{"X", "X", "X", "", "", "", "", "", ""},
+			expected:  true,
+			winner:    "X",
+		},
+		{
+			name:      "O wins horizontally (middle row)",
+			gameState: []string{"", "", "", "O", "O", "O", "", "", ""},
+			expected:  true,
+			winner:    "O",
+		},
+		{
+			name:      "X wins vertically (left column)",
+			gameState: []string{"X", "", "", "X", "", "", "X", "", ""},
+			expected:  true,
+			winner:    "X",
+		},
+		{
+			name:      "O wins vertically (right column)",
+			gameState: []string{"", "", "O", "", "", "O", "", "", "O"},
+			expected:  true,
+			winner:    "O",
+		},
+		{
+			name:      "X wins diagonally (main diagonal)",
+			gameState: []string{"X", "", "", "", "X", "", "", "", "X"},
+			expected:  true,
+			winner:    "X",
+		},
+		{
+			name:      "O wins diagonally (anti-diagonal)",
+			gameState: []string{"", "", "O", "", "O", "", "O", "", ""},
+			expected:  true,
+			winner:    "O",
+		},
+		{
+			name:      "Draw game",
+			gameState: []string{"X", "O", "X", "O", "X", "O", "O", "X", "O"},
+			expected:  true,
+			winner:    "",
+		},
+		{
+			name:      "Game in progress",
+			gameState: []string{"X", "O", "", "X", "", "", "", "", ""},
+			expected:  false,
+			winner:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameOver, winner := FromStrings(tt.gameState).Winner()
+			if gameOver != tt.expected {
+				t.Errorf("Winner() gameOver = %v, expected %v", gameOver, tt.expected)
+			}
+			if winner != Mark(tt.winner) {
+				t.Errorf("Winner() winner = %v, expected %v", winner, tt.winner)
+			}
+		})
+	}
+}
+
+// Test MiniMax function
+func TestMiniMax(t *testing.T) {
+	tests := []struct {
+		name        string
+		stateOfGame []string
+		player      string
+		move        int
+		turn        string
+		level       int
+		expected    int
+	}{
+		{
+			name:        "X wins immediately",
+			stateOfGame: []string{"X", "X", "", "", "", "", "", "", ""},
+			player:      "X",
+			move:        2,
+			turn:        "X",
+			level:       0,
+			expected:    10, // 10 + level (0)
+		},
+		{
+			name:        "O blocks X from winning",
+			stateOfGame: []string{"X", "X", "", "", "", "", "", "", ""},
+			player:      "O",
+			move:        2,
+			turn:        "O",
+			level:       0,
+			expected:    -10, // -10 + level (0)
+		},
+		{
+			name:        "Draw game",
+			stateOfGame: []string{"X", "O", "X", "O", "X", "O", "", "", ""},
+			player:      "X",
+			move:        6,
+			turn:        "X",
+			level:       0,
+			expected:    0,
+		},
+		{
+			name:        "Game in progress",
+			stateOfGame: []string{"X", "", "", "", "", "", "", "", ""},
+			player:      "X",
+			move:        1,
+			turn:        "X",
+			level:       0,
+			expected:    0, // Will depend on the game tree
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := MiniMax(FromStrings(tt.stateOfGame), Mark(tt.player), Move(tt.move), Mark(tt.turn), tt.level)
+			// For complex game states, we just check that the result is reasonable
+			if result < -20 || result > 20 {
+				t.Errorf("MiniMax() result = %v, expected reasonable score", result)
+			}
+		})
+	}
+}
+
+// Test BestMove function
+func TestBestMove(t *testing.T) {
+	tests := []struct {
+		name       string
+		gameState  []string
+		player     string
+		validMoves []int // Valid positions that could be returned
+	}{
+		{
+			name:       "Empty board",
+			gameState:  []string{"", "", "", "", "", "", "", "", ""},
+			player:     "X",
+			validMoves: []int{0, 1, 2, 3, 4, 5, 6, 7, 8},
+		},
+		{
+			name:       "One move left",
+			gameState:  []string{"X", "O", "X", "O", "X", "O", "O", "X", ""},
+			player:     "X",
+			validMoves: []int{8},
+		},
+		{
+			name:       "X can win",
+			gameState:  []string{"X", "X", "", "", "", "", "", "", ""},
+			player:     "X",
+			validMoves: []int{2}, // Should choose winning move
+		},
+		{
+			name:       "O can win",
+			gameState:  []string{"O", "O", "", "", "", "", "", "", ""},
+			player:     "O",
+			validMoves: []int{2}, // Should choose winning move
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := int(BestMove(FromStrings(tt.gameState), Mark(tt.player)))
+
+			// Check if result is a valid move
+			valid := false
+			for _, validMove := range tt.validMoves {
+				if result == validMove {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				t.Errorf("BestMove() returned %v, expected one of %v", result, tt.validMoves)
+			}
+
+			// Check if the position is empty
+			if result >= 0 && result < len(tt.gameState) && tt.gameState[result] != "" {
+				t.Errorf("BestMove() returned position %v that is not empty", result)
+			}
+		})
+	}
+}
+
+func TestLegalMoves(t *testing.T) {
+	b := FromStrings([]string{"X", "", "O", "", "X", "", "", "", "O"})
+	got := b.LegalMoves()
+	want := []Move{1, 3, 5, 6, 7}
+	if len(got) != len(want) {
+		t.Fatalf("LegalMoves() = %v, expected %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("LegalMoves() = %v, expected %v", got, want)
+		}
+	}
+}
+
+func TestBoardPlay(t *testing.T) {
+	b := NewBoard()
+	next := b.Play(4, X)
+	if b.At(4) != Empty {
+		t.Errorf("Play() modified the original board")
+	}
+	if next.At(4) != X {
+		t.Errorf("Play() At(4) = %q, expected X", next.At(4))
+	}
+}
+
+func TestBoardString(t *testing.T) {
+	b := FromStrings([]string{"X", "O", "", "", "X", "", "", "", "O"})
+	expected := "X | O |   \n  | X |   \n  |   | O \n"
+	if b.String() != expected {
+		t.Errorf("String() = %q, expected %q", b.String(), expected)
+	}
+}
+
+func TestBoardWinnerGeneralized(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      Size
+		gameState []string
+		expected  bool
+		winner    Mark
+	}{
+		{
+			name: "4x4 three in a row is not enough for K=4",
+			size: Size{Width: 4, Height: 4, K: 4},
+			gameState: []string{
+				"X", "X", "X", "",
+				"O", "O", "O", "",
+				"", "", "", "",
+				"", "", "", "",
+			},
+			expected: false,
+		},
+		{
+			name: "4x4 anti-diagonal win",
+			size: Size{Width: 4, Height: 4, K: 4},
+			gameState: []string{
+				"X", "", "", "O",
+				"X", "", "O", "",
+				"", "O", "X", "",
+				"O", "", "", "X",
+			},
+			expected: true,
+			winner:   O,
+		},
+		{
+			name: "5x5 three in a row with K=3",
+			size: Size{Width: 5, Height: 5, K: 3},
+			gameState: []string{
+				"", "", "", "", "",
+				"", "", "", "", "",
+				"", "", "X", "", "",
+				"", "", "", "X", "",
+				"", "", "", "", "X",
+			},
+			expected: true,
+			winner:   X,
+		},
+		{
+			name: "Rows do not wrap around the board edge",
+			size: Size{Width: 4, Height: 3, K: 3},
+			gameState: []string{
+				"", "", "X", "X",
+				"X", "", "", "",
+				"", "", "", "",
+			},
+			expected: false,
+		},
+		{
+			name: "Full 4x3 board is a draw",
+			size: Size{Width: 4, Height: 3, K: 3},
+			gameState: []string{
+				"X", "X", "O", "O",
+				"O", "O", "X", "X",
+				"X", "X", "O", "O",
+			},
+			expected: true,
+			winner:   Empty,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameOver, winner := tt.size.FromStrings(tt.gameState).Winner()
+			if gameOver != tt.expected {
+				t.Errorf("Winner() gameOver = %v, expected %v", gameOver, tt.expected)
+			}
+			if winner != tt.winner {
+				t.Errorf("Winner() winner = %v, expected %v", winner, tt.winner)
+			}
+		})
+	}
+}
+
+func TestBoardWinningLine(t *testing.T) {
+	tests := []struct {
+		size      Size
+		gameState []string
+		line      []Move
+	}{
+		{Standard, []string{"", "", "", "", "", "", "", "", ""}, nil},
+		{Standard, []string{"O", "X", "O", "X", "X", "O", "", "X", ""}, []Move{1, 4, 7}},
+		{Standard, []string{"X", "X", "O", "X", "O", "", "O", "", ""}, []Move{2, 4, 6}},
+		{Size{Width: 4, Height: 4, K: 3}, []string{"", "", "", "", "", "", "", "X", "", "", "X", "", "", "X", "", ""}, []Move{7, 10, 13}},
+	}
+	for _, tt := range tests {
+		if line := tt.size.FromStrings(tt.gameState).WinningLine(); !reflect.DeepEqual(line, tt.line) {
+			t.Errorf("WinningLine() of %v = %v, expected %v", tt.gameState, line, tt.line)
+		}
+	}
+}
+
+func TestBoardHasLine(t *testing.T) {
+	b := FromStrings([]string{"X", "X", "X", "O", "O", "O", "", "", ""})
+	if !b.HasLine(X) || !b.HasLine(O) {
+		t.Errorf("HasLine() of %v = %v for X, %v for O, expected both", b, b.HasLine(X), b.HasLine(O))
+	}
+	b = FromStrings([]string{"X", "X", "", "O", "O", "", "", "", ""})
+	if b.HasLine(X) || b.HasLine(O) {
+		t.Errorf("HasLine() of %v found a line", b)
+	}
+}
+
+func TestSizeValid(t *testing.T) {
+	valid := []Size{Standard, {Width: 4, Height: 4, K: 4}, {Width: 7, Height: 6, K: 4}, {Width: 5, Height: 1, K: 3}}
+	for _, s := range valid {
+		if err := s.Valid(); err != nil {
+			t.Errorf("%v.Valid() = %v, expected nil", s, err)
+		}
+	}
+	invalid := []Size{{}, {Width: 3, Height: 3, K: 4}, {Width: 3, Height: 0, K: 3}, {Width: 3, Height: 3}}
+	for _, s := range invalid {
+		if err := s.Valid(); err == nil {
+			t.Errorf("%v.Valid() = nil, expected error", s)
+		}
+	}
+}
+
+func TestSizeParseStrings(t *testing.T) {
+	b, err := Standard.ParseStrings([]string{"X", "", "", "", "O", "", "", "", "X"})
+	if err != nil {
+		t.Fatalf("ParseStrings() = %v", err)
+	}
+	if b.Count(X) != 2 || b.Count(O) != 1 || b.Count(Empty) != 6 {
+		t.Errorf("ParseStrings() counts X=%d O=%d empty=%d", b.Count(X), b.Count(O), b.Count(Empty))
+	}
+
+	invalid := []struct {
+		size Size
+		gs   []string
+	}{
+		{Standard, []string{"X", "", ""}},
+		{Standard, make([]string, 10)},
+		{Standard, []string{"x", "", "", "", "", "", "", "", ""}},
+		{Standard, []string{"", "", "", "", " ", "", "", "", ""}},
+		{Size{Width: 3, Height: 3, K: 4}, make([]string, 9)},
+	}
+	for _, tt := range invalid {
+		if _, err := tt.size.ParseStrings(tt.gs); err == nil {
+			t.Errorf("%v.ParseStrings(%q) = nil, expected error", tt.size, tt.gs)
+		}
+	}
+}
+
+func TestBestMoveGeneralized(t *testing.T) {
+	size := Size{Width: 4, Height: 4, K: 3}
+	b := size.FromStrings([]string{
+		"X", "O", "X", "O",
+		"O", "X", "O", "O",
+		"O", "X", "", "",
+		"X", "O", "", "",
+	})
+	// X completes the 0-5-10 diagonal.
+	if got := BestMove(b, X); got != 10 {
+		t.Errorf("BestMove() = %v, expected 10", got)
+	}
+	if score := MiniMax(b, X, 10, X, 0); score != 17 {
+		t.Errorf("MiniMax() = %v, expected 17 on a 16 square board", score)
+	}
+}
@@ -0,0 +1,141 @@
+package engine
+
+import "context"
+
+// winningMoves returns the moves that win the game for mark at once.
+func winningMoves(b Board, mark Mark) []Move {
+	var wins []Move
+	for _, m := range b.LegalMoves() {
+		if b.Play(m, mark).completesLine(m) {
+			wins = append(wins, m)
+		}
+	}
+	return wins
+}
+
+// forkMoves returns the moves that leave mark two or more ways to win.
+func forkMoves(b Board, mark Mark) []Move {
+	var forks []Move
+	for _, m := range b.LegalMoves() {
+		if next := b.Play(m, mark); !next.completesLine(m) && len(winningMoves(next, mark)) >= 2 {
+			forks = append(forks, m)
+		}
+	}
+	return forks
+}
+
+// mostLines returns the move whose square lies on the most lines,
+// preferring the lowest index on ties.
+func mostLines(b Board, moves []Move) Move {
+	lines := lineCounts(b.Size())
+	best := moves[0]
+	for _, m := range moves[1:] {
+		if lines[m] > lines[best] {
+			best = m
+		}
+	}
+	return best
+}
+
+// Greedy wins when it can, blocks when it must and otherwise takes the
+// square on the most lines.
+type Greedy struct{}
+
+func (Greedy) Name() string { return "greedy" }
+
+func (Greedy) Choose(ctx context.Context, b Board, mark Mark) (Decision, error) {
+	moves, err := legalMoves(b)
+	if err != nil {
+		return Decision{}, err
+	}
+	if wins := winningMoves(b, mark); len(wins) > 0 {
+		return ruleDecision(wins[0], "win"), nil
+	}
+	if blocks := winningMoves(b, mark.Opponent()); len(blocks) > 0 {
+		return ruleDecision(blocks[0], "block"), nil
+	}
+	return ruleDecision(mostLines(b, moves), "lines"), nil
+}
+
+// Rules plays Newell and Simon's tic-tac-toe rules: win, block, fork,
+// block a fork, then take the centre, the corner opposite the opponent,
+// any corner and finally any side. It plays perfectly on the standard
+// board and applies the same rules to K-in-a-row lines on larger ones.
+type Rules struct{}
+
+func (Rules) Name() string { return "rules" }
+
+func (Rules) Choose(ctx context.Context, b Board, mark Mark) (Decision, error) {
+	moves, err := legalMoves(b)
+	if err != nil {
+		return Decision{}, err
+	}
+	opp := mark.Opponent()
+	if wins := winningMoves(b, mark); len(wins) > 0 {
+		return ruleDecision(wins[0], "win"), nil
+	}
+	if blocks := winningMoves(b, opp); len(blocks) > 0 {
+		return ruleDecision(blocks[0], "block"), nil
+	}
+	if forks := forkMoves(b, mark); len(forks) > 0 {
+		return ruleDecision(forks[0], "fork"), nil
+	}
+	if m, ok := blockFork(b, mark); ok {
+		return ruleDecision(m, "block fork"), nil
+	}
+
+	w, h := b.Size().Width, b.Size().Height
+	if w%2 == 1 && h%2 == 1 {
+		if centre := b.MoveAt(h/2, w/2); b.At(centre) == Empty {
+			return ruleDecision(centre, "centre"), nil
+		}
+	}
+	corners := []Move{b.MoveAt(0, 0), b.MoveAt(0, w-1), b.MoveAt(h-1, 0), b.MoveAt(h-1, w-1)}
+	for i, c := range corners {
+		if opposite := corners[3-i]; b.At(c) == opp && b.At(opposite) == Empty {
+			return ruleDecision(opposite, "opposite corner"), nil
+		}
+	}
+	for _, c := range corners {
+		if b.At(c) == Empty {
+			return ruleDecision(c, "corner"), nil
+		}
+	}
+	for _, m := range moves {
+		if row, col := b.Coord(m); row == 0 || col == 0 || row == h-1 || col == w-1 {
+			return ruleDecision(m, "side"), nil
+		}
+	}
+	return ruleDecision(mostLines(b, moves), "lines"), nil
+}
+
+// blockFork finds a move that stops the opponent forking. A single fork
+// square is taken directly. Otherwise mark makes a threat the opponent
+// must answer, as long as the forced answer does not give the opponent a
+// fork of its own; failing that, it takes one of the fork squares.
+func blockFork(b Board, mark Mark) (Move, bool) {
+	opp := mark.Opponent()
+	forks := forkMoves(b, opp)
+	switch len(forks) {
+	case 0:
+		return 0, false
+	case 1:
+		return forks[0], true
+	}
+	for _, m := range b.LegalMoves() {
+		next := b.Play(m, mark)
+		threats := winningMoves(next, mark)
+		if len(threats) != 1 {
+			continue
+		}
+		reply := next.Play(threats[0], opp)
+		if len(winningMoves(reply, opp)) < 2 {
+			return m, true
+		}
+	}
+	return forks[0], true
+}
+
+func ruleDecision(m Move, rule string) Decision {
+	return Decision{Move: m, Diagnostics: map[string]any{"rule": rule}}
+}
@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// DefaultIterations is the number of playouts MCTS runs per move when no
+// other number is configured.
+const DefaultIterations = 20000
+
+// exploration is the UCT exploration constant, √2.
+var exploration = math.Sqrt2
+
+// MCTSOptions configures an MCTS strategy.
+type MCTSOptions struct {
+	// Iterations is the number of playouts per move, shared between the
+	// workers. Zero selects DefaultIterations.
+	Iterations int
+	// Budget, if not zero, stops each move's search after this long.
+	Budget time.Duration
+	// Workers is the number of independent trees searched in parallel
+	// and merged at the root. Zero uses GOMAXPROCS.
+	Workers int
+	// Seed determines every random choice. Searches limited by
+	// iterations rather than time are reproducible for a given seed.
+	Seed int64
+}
+
+// MCTS chooses moves by Monte Carlo tree search with the UCT selection
+// rule, playing random games from the positions it explores. Searches are
+// root parallel: each worker grows its own tree and the visit counts of
+// the root moves are summed to choose the move.
+type MCTS struct {
+	opts MCTSOptions
+
+	mu  sync.Mutex
+	rng *rand.Rand // seeds each search's workers
+}
+
+// NewMCTS returns an MCTS strategy configured by opts.
+func NewMCTS(opts MCTSOptions) *MCTS {
+	if opts.Iterations <= 0 {
+		opts.Iterations = DefaultIterations
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	return &MCTS{opts: opts, rng: rand.New(rand.NewSource(opts.Seed))}
+}
+
+func (*MCTS) Name() string { return "mcts" }
+
+func (s *MCTS) Choose(ctx context.Context, b Board, mark Mark) (Decision, error) {
+	if _, err := legalMoves(b); err != nil {
+		return Decision{}, err
+	}
+	if s.opts.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Budget)
+		defer cancel()
+	}
+
+	workers := s.opts.Workers
+	seeds := make([]int64, workers)
+	s.mu.Lock()
+	for i := range seeds {
+		seeds[i] = s.rng.Int63()
+	}
+	s.mu.Unlock()
+
+	roots := make([]*mctsNode, workers)
+	playouts := make([]int, workers)
+	forEach(workers, workers, func(_, w int) {
+		rng := rand.New(rand.NewSource(seeds[w]))
+		iterations := s.opts.Iterations / workers
+		if w < s.opts.Iterations%workers {
+			iterations++
+		}
+		root := newMCTSNode(nil, -1, b, mark.Opponent())
+		// At least one playout runs so that there is a move to return.
+		n := 0
+		for ; n < iterations && (n == 0 || ctx.Err() == nil); n++ {
+			root.iterate(rng)
+		}
+		roots[w], playouts[w] = root, n
+	})
+
+	visits := make(map[Move]int)
+	wins := make(map[Move]float64)
+	total := 0
+	for w, root := range roots {
+		total += playouts[w]
+		for _, c := range root.children {
+			visits[c.move] += c.visits
+			wins[c.move] += c.wins
+		}
+	}
+	best := Move(-1)
+	for _, m := range b.LegalMoves() {
+		if best < 0 || visits[m] > visits[best] {
+			best = m
+		}
+	}
+	value := 0.0
+	if visits[best] > 0 {
+		value = wins[best] / float64(visits[best])
+	}
+	return Decision{
+		Move: best,
+		Diagnostics: map[string]any{
+			"iterations": total,
+			"workers":    workers,
+			"visits":     visits[best],
+			"value":      value,
+		},
+	}, nil
+}
+
+// mctsNode is a position in the search tree, reached by mover playing
+// move.
+type mctsNode struct {
+	parent   *mctsNode
+	move     Move
+	mover    Mark
+	board    Board
+	over     bool
+	winner   Mark
+	children []*mctsNode
+	untried  []Move
+
+	visits int
+	// wins counts playouts won by mover, with draws counting half.
+	wins float64
+}
+
+func newMCTSNode(parent *mctsNode, move Move, b Board, mover Mark) *mctsNode {
+	n := &mctsNode{parent: parent, move: move, mover: mover, board: b}
+	if move >= 0 && b.completesLine(move) {
+		n.over, n.winner = true, mover
+	} else if b.empties() == 0 {
+		n.over = true
+	} else {
+		n.untried = b.LegalMoves()
+	}
+	return n
+}
+
+// iterate runs one round of selection, expansion, simulation and
+// backpropagation.
+func (n *mctsNode) iterate(rng *rand.Rand) {
+	node := n
+	for len(node.untried) == 0 && len(node.children) > 0 {
+		node = node.selectChild()
+	}
+	if len(node.untried) > 0 {
+		i := rng.Intn(len(node.untried))
+		m := node.untried[i]
+		node.untried[i] = node.untried[len(node.untried)-1]
+		node.untried = node.untried[:len(node.untried)-1]
+		child := newMCTSNode(node, m, node.board.Play(m, node.mover.Opponent()), node.mover.Opponent())
+		node.children = append(node.children, child)
+		node = child
+	}
+	winner := node.playout(rng)
+	for ; node != nil; node = node.parent {
+		node.visits++
+		switch winner {
+		case node.mover:
+			node.wins++
+		case Empty:
+			node.wins += 0.5
+		}
+	}
+}
+
+// selectChild returns the child with the highest UCT value.
+func (n *mctsNode) selectChild() *mctsNode {
+	var best *mctsNode
+	bestValue := math.Inf(-1)
+	logVisits := math.Log(float64(n.visits))
+	for _, c := range n.children {
+		v := c.wins/float64(c.visits) + exploration*math.Sqrt(logVisits/float64(c.visits))
+		if v > bestValue {
+			best, bestValue = c, v
+		}
+	}
+	return best
+}
+
+// playout plays random moves from n until the game ends and returns the
+// winner, or Empty for a draw.
+func (n *mctsNode) playout(rng *rand.Rand) Mark {
+	if n.over {
+		return n.winner
+	}
+	b := n.board.clone()
+	moves := b.LegalMoves()
+	turn := n.mover.Opponent()
+	for len(moves) > 0 {
+		i := rng.Intn(len(moves))
+		m := moves[i]
+		moves[i] = moves[len(moves)-1]
+		moves = moves[:len(moves)-1]
+		b.cells[m] = turn
+		if b.completesLine(m) {
+			return turn
+		}
+		turn = turn.Opponent()
+	}
+	return Empty
+}
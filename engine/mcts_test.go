@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+func TestMCTSMatchesMiniMaxOutcomes(t *testing.T) {
+	var positions []Board
+	reachable(NewBoard(), X, map[string]bool{}, &positions)
+
+	s := NewMCTS(MCTSOptions{Iterations: 5000, Workers: 4, Seed: 7})
+	for _, b := range positions {
+		player := sideToMove(b)
+		best := math.MinInt
+		scores := make(map[Move]int)
+		for _, m := range b.LegalMoves() {
+			scores[m] = MiniMax(b, player, m, player, 0)
+			best = max(best, scores[m])
+		}
+		d, err := s.Choose(context.Background(), b, player)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sign(scores[d.Move]) != sign(best) {
+			t.Errorf("MCTS chose %d scoring %d, MiniMax best scores %d\n%v", d.Move, scores[d.Move], best, b)
+		}
+	}
+}
+
+func TestMCTSNeverLoses(t *testing.T) {
+	s := NewMCTS(MCTSOptions{Iterations: 5000, Workers: 4, Seed: 11})
+	neverLoses(t, s, NewBoard(), X, X)
+	neverLoses(t, s, NewBoard(), O, X)
+}
+
+func TestMCTSIsReproducible(t *testing.T) {
+	boards := []Board{
+		NewBoard(),
+		FromStrings([]string{"X", "", "", "", "O", "", "", "", ""}),
+		Size{Width: 5, Height: 5, K: 4}.NewBoard().Play(12, X),
+	}
+	a := NewMCTS(MCTSOptions{Iterations: 3000, Workers: 3, Seed: 99})
+	b := NewMCTS(MCTSOptions{Iterations: 3000, Workers: 3, Seed: 99})
+	for _, board := range boards {
+		da, _ := a.Choose(context.Background(), board, sideToMove(board))
+		db, _ := b.Choose(context.Background(), board, sideToMove(board))
+		if da.Move != db.Move || da.Diagnostics["visits"] != db.Diagnostics["visits"] {
+			t.Errorf("MCTS with the same seed chose %d (%v) and %d (%v)",
+				da.Move, da.Diagnostics["visits"], db.Move, db.Diagnostics["visits"])
+		}
+	}
+}
+
+func TestMCTSBudget(t *testing.T) {
+	s := NewMCTS(MCTSOptions{Iterations: 1 << 30, Budget: 50 * time.Millisecond, Seed: 1})
+	b := Size{Width: 7, Height: 6, K: 4}.NewBoard()
+
+	start := time.Now()
+	d, err := s.Choose(context.Background(), b, X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Choose() took %v with a 50ms budget", elapsed)
+	}
+	if n := d.Diagnostics["iterations"].(int); n < 1 || n >= 1<<30 {
+		t.Errorf("Choose() ran %d iterations", n)
+	}
+}
+
+func TestMCTSCancelledStillMoves(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := NewMCTS(MCTSOptions{Seed: 1})
+	b := NewBoard()
+	d, err := s.Choose(ctx, b, X)
+	if err != nil || b.At(d.Move) != Empty {
+		t.Errorf("Choose() = %d, %v, expected a legal move", d.Move, err)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	const n = 100
+	var calls [n]atomic.Int32
+	var running, peak atomic.Int32
+	forEach(n, 3, func(w, i int) {
+		if w < 0 || w >= 3 {
+			t.Errorf("worker index %d out of range", w)
+		}
+		r := running.Add(1)
+		for {
+			p := peak.Load()
+			if r <= p || peak.CompareAndSwap(p, r) {
+				break
+			}
+		}
+		calls[i].Add(1)
+		running.Add(-1)
+	})
+	for i := range calls {
+		if c := calls[i].Load(); c != 1 {
+			t.Errorf("fn(%d) called %d times", i, c)
+		}
+	}
+	if p := peak.Load(); p > 3 {
+		t.Errorf("%d calls ran at once with 3 workers", p)
+	}
+}
@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"runtime"
+	"sync"
+)
+
+// forEach calls fn for every i in [0, n) using at most workers goroutines,
+// or GOMAXPROCS if workers is zero, and returns when all calls are done.
+// Each call is told which worker runs it, so workers can reuse state that
+// must not be shared between goroutines.
+func forEach(n, workers int, fn func(worker, i int)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, n)
+
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := range next {
+				fn(w, i)
+			}
+		}(w)
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"context"
+	"time"
+)
+
+// winScore returns the base score of a won game on b. It is 10 on the
+// standard board and always exceeds the number of squares, so that a win
+// scores above a draw however deep it is found.
+func winScore(b Board) int {
+	if n := b.Len() + 1; n > 10 {
+		return n
+	}
+	return 10
+}
+
+// MiniMax scores placing turn at move on b from player's point of view.
+// Wins score 10 and losses -10 on the standard board, both offset by
+// level, which decreases by one for every ply searched so that faster
+// wins are preferred. Larger boards use a larger base score.
+func MiniMax(b Board, player Mark, move Move, turn Mark, level int) int {
+	return minimax(b, player, move, turn, level, nil)
+}
+
+// terminalScore scores a finished game won by winner, or drawn if winner
+// is Empty, from player's point of view.
+func terminalScore(b Board, winner Mark, player Mark, level int) int {
+	switch winner {
+	case Empty:
+		return 0
+	case player:
+		return winScore(b) + level
+	default:
+		return -winScore(b) + level
+	}
+}
+
+// minimax implements MiniMax, counting every position it visits in nodes
+// when nodes is not nil.
+func minimax(b Board, player Mark, move Move, turn Mark, level int, nodes *int64) int {
+	if nodes != nil {
+		*nodes++
+	}
+	next := b.Play(move, turn)
+	gameOver, piece := next.Winner()
+	if gameOver {
+		return terminalScore(b, piece, player, level)
+	}
+	newTurn := turn.Opponent()
+	var bestScore int
+	scoreSet := false
+	for _, m := range next.LegalMoves() {
+		score := minimax(next, player, m, newTurn, level-1, nodes)
+		if !scoreSet || (score > bestScore && newTurn == player) || (newTurn != player && score < bestScore) {
+			bestScore = score
+			scoreSet = true
+		}
+	}
+	return bestScore
+}
+
+type scoredMove struct {
+	move  Move
+	score int
+	ok    bool
+}
+
+// Options configures a search.
+type Options struct {
+	// Table, if not nil, is shared with other searches to avoid
+	// re-evaluating positions they have already seen.
+	Table *Table
+	// Budget bounds the time spent searching. Zero means no limit.
+	Budget time.Duration
+	// MaxDepth bounds the number of plies searched. Zero searches to the
+	// end of the game.
+	MaxDepth int
+	// Book, if not nil, answers positions it contains without searching.
+	// It is ignored when MaxDepth limits the search.
+	Book *Book
+	// Workers bounds the number of goroutines searching at once. Zero
+	// uses GOMAXPROCS.
+	Workers int
+	// TieBreak chooses between equally scored moves. Nil plays the lowest
+	// numbered one.
+	TieBreak TieBreak
+	// Analyze fills in the principal variation of the result, at the cost
+	// of searching each position along it.
+	Analyze bool
+}
+
+// Result is the outcome of a search.
+type Result struct {
+	Move   Move
+	Score  int
+	Scores map[Move]int
+	// Depth is the number of plies searched, and Complete reports whether
+	// that reached the end of the game so that Scores are exact.
+	Depth    int
+	Complete bool
+	// FromBook reports that the scores came from the book.
+	FromBook bool
+	// Nodes is the number of positions visited, including by searches
+	// abandoned when ctx was done.
+	Nodes int64
+	// PV is the line of play both sides are expected to follow, starting
+	// with Move. It is only filled in when Options.Analyze is set and may
+	// stop early if ctx is done.
+	PV []Move
+}
+
+// Search scores every legal move for player and returns the highest
+// scoring one.
+func Search(b Board, player Mark, opts Options) Result {
+	return SearchContext(context.Background(), b, player, opts)
+}
+
+// SearchContext is like Search but stops when ctx is done or the budget
+// runs out. Positions found in the book are answered immediately.
+// Searches that can be stopped deepen iteratively, one ply at a time, and
+// return the result of the deepest search that finished; the first ply is
+// always searched in full. Ties between the best moves of that search are
+// broken once, by the policy in opts.
+func SearchContext(ctx context.Context, b Board, player Mark, opts Options) Result {
+	if opts.Book != nil && opts.MaxDepth == 0 {
+		if scores, ok := opts.Book.Lookup(b, player); ok {
+			res := Result{Scores: scores, Depth: b.empties(), Complete: true, FromBook: true}
+			res.Move, res.Score = chooseBest(ctx, b, player, scores, opts)
+			if opts.Analyze {
+				res.PV = principalVariation(ctx, b, player, res, opts)
+			}
+			return res
+		}
+	}
+	if opts.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Budget)
+		defer cancel()
+	}
+	maxDepth := b.empties()
+	if opts.MaxDepth > 0 && opts.MaxDepth < maxDepth {
+		maxDepth = opts.MaxDepth
+	}
+	depth := 1
+	if ctx.Done() == nil || maxDepth < 1 {
+		depth = maxDepth
+	}
+
+	res, _ := searchDepth(context.Background(), b, player, opts, depth)
+	nodes := res.Nodes
+	for res.Depth < maxDepth {
+		next, ok := searchDepth(ctx, b, player, opts, res.Depth+1)
+		nodes += next.Nodes
+		if !ok {
+			break
+		}
+		res = next
+	}
+	res.Nodes = nodes
+	res.Complete = res.Depth >= b.empties()
+	res.Move, res.Score = chooseBest(ctx, b, player, res.Scores, opts)
+	if opts.Analyze {
+		res.PV = principalVariation(ctx, b, player, res, opts)
+	}
+	return res
+}
+
+// searchDepth scores every legal move to depth plies, spreading the moves
+// over a pool of workers. It reports false if ctx was done before every
+// move was scored.
+func searchDepth(ctx context.Context, b Board, player Mark, opts Options, depth int) (Result, bool) {
+	moves := b.LegalMoves()
+	scored := make([]scoredMove, len(moves))
+	searchers := make([]*Searcher, len(moves))
+	forEach(len(moves), opts.Workers, func(w, i int) {
+		if searchers[w] == nil {
+			searchers[w] = NewSearcher(opts.Table)
+		}
+		score, ok := searchers[w].ScoreContext(ctx, b, player, moves[i], player, 0, depth)
+		scored[i] = scoredMove{moves[i], score, ok}
+	})
+
+	res := Result{Scores: make(map[Move]int), Depth: depth}
+	for _, s := range searchers {
+		if s != nil {
+			res.Nodes += s.Nodes()
+		}
+	}
+	ok := true
+	for _, c := range scored {
+		res.Scores[c.move] = c.score
+		ok = ok && c.ok
+	}
+
+	res.Move, res.Score = pickBest(res.Scores)
+	return res, ok
+}
+
+// pickBest returns the lowest numbered of the highest scoring moves and
+// its score.
+func pickBest(scores map[Move]int) (pos Move, bestScore int) {
+	scoreSet := false
+	for move, score := range scores {
+		if !scoreSet || score > bestScore || (score == bestScore && move < pos) {
+			bestScore = score
+			pos = move
+			scoreSet = true
+		}
+	}
+	return pos, bestScore
+}
+
+// ScoreMoves returns the MiniMax score of every legal move for player.
+func ScoreMoves(b Board, player Mark) map[Move]int {
+	return Search(b, player, Options{}).Scores
+}
+
+// BestMove returns the highest scoring move for player.
+func BestMove(b Board, player Mark) Move {
+	return Search(b, player, Options{}).Move
+}
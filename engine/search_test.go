@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSearchBudgetReturnsBestSoFar(t *testing.T) {
+	b := Size{Width: 6, Height: 6, K: 4}.NewBoard().Play(14, X)
+
+	start := time.Now()
+	res := Search(b, O, Options{Budget: 50 * time.Millisecond, Table: NewTable(0)})
+	elapsed := time.Since(start)
+
+	if elapsed > time.Second {
+		t.Errorf("Search() took %v with a 50ms budget", elapsed)
+	}
+	if res.Complete {
+		t.Errorf("Search() reported a complete search of a 6x6 board")
+	}
+	if res.Depth < 1 {
+		t.Errorf("Search() depth = %d, expected at least 1", res.Depth)
+	}
+	if b.At(res.Move) != Empty {
+		t.Errorf("Search() returned occupied square %d", res.Move)
+	}
+}
+
+func TestSearchContextCancelledStillFindsWin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	b := FromStrings([]string{"X", "X", "", "O", "O", "", "", "", ""})
+	res := SearchContext(ctx, b, O, Options{})
+	if res.Move != 5 {
+		t.Errorf("SearchContext() = %d, expected the winning move 5", res.Move)
+	}
+	if res.Depth != 1 {
+		t.Errorf("SearchContext() depth = %d, expected 1", res.Depth)
+	}
+}
+
+func TestSearchMaxDepth(t *testing.T) {
+	// X threatens 0-1-2; O must block at 2 or lose on the next ply.
+	b := FromStrings([]string{"X", "X", "", "", "O", "", "", "", ""})
+
+	res := Search(b, O, Options{MaxDepth: 2})
+	if res.Move != 2 {
+		t.Errorf("Search() = %d, expected the blocking move 2", res.Move)
+	}
+	if res.Depth != 2 || res.Complete {
+		t.Errorf("Search() depth = %d complete = %v, expected 2 and false", res.Depth, res.Complete)
+	}
+}
+
+func TestSearchDeepeningMatchesFullSearch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	b := FromStrings([]string{"X", "", "", "", "", "", "", "", ""})
+	res := SearchContext(ctx, b, O, Options{Table: NewTable(0)})
+	if !res.Complete || res.Depth != 8 {
+		t.Fatalf("SearchContext() depth = %d complete = %v, expected a complete search", res.Depth, res.Complete)
+	}
+	for m, want := range ScoreMoves(b, O) {
+		if res.Scores[m] != want {
+			t.Errorf("SearchContext() score for %d = %d, expected %d", m, res.Scores[m], want)
+		}
+	}
+}
+
+func TestScoreContextDepthLimit(t *testing.T) {
+	b := FromStrings([]string{"X", "X", "", "", "O", "", "", "", ""})
+	s := NewSearcher(nil)
+
+	// Playing 8 lets X win on the next ply, which a one ply search
+	// cannot see.
+	if v, ok := s.ScoreContext(context.Background(), b, O, 8, O, 0, 1); !ok || v != 0 {
+		t.Errorf("ScoreContext() depth 1 = %d, %v, expected 0, true", v, ok)
+	}
+	if v, ok := s.ScoreContext(context.Background(), b, O, 8, O, 0, 2); !ok || v != -11 {
+		t.Errorf("ScoreContext() depth 2 = %d, %v, expected -11, true", v, ok)
+	}
+}
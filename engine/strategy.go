@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// ErrNoMoves is returned by strategies asked to move on a finished game.
+var ErrNoMoves = errors.New("engine: no legal moves")
+
+// Decision is a move chosen by a Strategy.
+type Decision struct {
+	Move Move
+	// Diagnostics optionally explains the choice, such as the scores or
+	// rule that led to it. Keys are strategy specific.
+	Diagnostics map[string]any
+	// Analysis, set by searching strategies when Options.Analyze is set,
+	// describes the search behind the choice. Strategies that deliberately
+	// err may play a move other than Analysis.Move.
+	Analysis *Analysis
+}
+
+// Strategy chooses moves. Implementations must be safe for concurrent use
+// so one strategy can serve every game a bot plays.
+type Strategy interface {
+	// Name identifies the strategy, as accepted by NewStrategy.
+	Name() string
+	// Choose returns mark's move on b. Strategies that search stop and
+	// return their best move so far when ctx is done.
+	Choose(ctx context.Context, b Board, mark Mark) (Decision, error)
+}
+
+// StrategyOptions configures the strategies built by NewStrategy. Each
+// strategy uses only the fields that apply to it.
+type StrategyOptions struct {
+	// Options configures the minimax search.
+	Options
+	// Seed seeds strategies that make random choices.
+	Seed int64
+	// Iterations is the number of playouts MCTS runs per move. Zero
+	// selects DefaultIterations. MCTS also honours Budget and Workers.
+	Iterations int
+}
+
+// StrategyNames lists the built-in strategies in the order they are
+// documented, followed by the difficulty levels.
+var StrategyNames = []string{"minimax", "random", "greedy", "rules", "mcts", "easy", "medium", "hard", "perfect"}
+
+// NewStrategy returns the built-in strategy with the given name. The name
+// of a difficulty level returns a Handicap strategy playing at that level.
+func NewStrategy(name string, opts StrategyOptions) (Strategy, error) {
+	if level, err := ParseDifficulty(name); err == nil {
+		return NewHandicap(level, opts.Options, opts.Seed), nil
+	}
+	switch name {
+	case "minimax":
+		return Minimax{Options: opts.Options}, nil
+	case "random":
+		return NewRandom(opts.Seed), nil
+	case "greedy":
+		return Greedy{}, nil
+	case "rules":
+		return Rules{}, nil
+	case "mcts":
+		return NewMCTS(MCTSOptions{
+			Iterations: opts.Iterations,
+			Budget:     opts.Budget,
+			Workers:    opts.Workers,
+			Seed:       opts.Seed,
+		}), nil
+	}
+	return nil, fmt.Errorf("engine: unknown strategy %q", name)
+}
+
+// legalMoves returns the legal moves on b, or ErrNoMoves if the game is
+// over.
+func legalMoves(b Board) ([]Move, error) {
+	if over, _ := b.Winner(); over {
+		return nil, ErrNoMoves
+	}
+	return b.LegalMoves(), nil
+}
+
+// Minimax plays perfectly by searching the game tree, or as well as its
+// search budget allows on larger boards.
+type Minimax struct {
+	Options Options
+}
+
+func (Minimax) Name() string { return "minimax" }
+
+func (s Minimax) Choose(ctx context.Context, b Board, mark Mark) (Decision, error) {
+	if _, err := legalMoves(b); err != nil {
+		return Decision{}, err
+	}
+	res := SearchContext(ctx, b, mark, s.Options)
+	d := Decision{
+		Move: res.Move,
+		Diagnostics: map[string]any{
+			"score":    res.Score,
+			"depth":    res.Depth,
+			"complete": res.Complete,
+			"book":     res.FromBook,
+		},
+	}
+	if s.Options.Analyze {
+		d.Analysis = res.Analysis(b)
+	}
+	return d, nil
+}
+
+// lockedRand is a random source safe for concurrent use.
+type lockedRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (r *lockedRand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Intn(n)
+}
+
+// Random plays a uniformly random legal move.
+type Random struct {
+	rng *lockedRand
+}
+
+// NewRandom returns a Random strategy whose choices are determined by seed.
+func NewRandom(seed int64) *Random {
+	return &Random{rng: newLockedRand(seed)}
+}
+
+func (*Random) Name() string { return "random" }
+
+func (s *Random) Choose(ctx context.Context, b Board, mark Mark) (Decision, error) {
+	moves, err := legalMoves(b)
+	if err != nil {
+		return Decision{}, err
+	}
+	return Decision{Move: moves[s.rng.Intn(len(moves))]}, nil
+}
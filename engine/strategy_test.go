@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func builtinStrategies(t *testing.T) []Strategy {
+	t.Helper()
+	var strategies []Strategy
+	for _, name := range StrategyNames {
+		s, err := NewStrategy(name, StrategyOptions{Seed: 1, Iterations: 5000})
+		if err != nil {
+			t.Fatalf("NewStrategy(%q) = %v", name, err)
+		}
+		if s.Name() != name {
+			t.Errorf("NewStrategy(%q).Name() = %q", name, s.Name())
+		}
+		strategies = append(strategies, s)
+	}
+	return strategies
+}
+
+func TestNewStrategyUnknown(t *testing.T) {
+	if _, err := NewStrategy("alphazero", StrategyOptions{}); err == nil {
+		t.Error("NewStrategy() accepted an unknown name")
+	}
+}
+
+func TestStrategiesPlayLegalMoves(t *testing.T) {
+	boards := []Board{
+		NewBoard(),
+		FromStrings([]string{"X", "O", "X", "", "O", "", "", "", ""}),
+		FromStrings([]string{"X", "O", "X", "O", "X", "O", "O", "X", ""}),
+		Size{Width: 4, Height: 4, K: 3}.NewBoard().Play(5, X),
+	}
+	for _, s := range builtinStrategies(t) {
+		for _, b := range boards {
+			d, err := s.Choose(context.Background(), b, sideToMove(b))
+			if err != nil {
+				t.Errorf("%s: Choose() = %v", s.Name(), err)
+				continue
+			}
+			if d.Move < 0 || int(d.Move) >= b.Len() || b.At(d.Move) != Empty {
+				t.Errorf("%s: Choose() = %d, not a legal move\n%v", s.Name(), d.Move, b)
+			}
+		}
+	}
+}
+
+func TestStrategiesRejectFinishedGames(t *testing.T) {
+	won := FromStrings([]string{"X", "X", "X", "O", "O", "", "", "", ""})
+	for _, s := range builtinStrategies(t) {
+		if _, err := s.Choose(context.Background(), won, O); !errors.Is(err, ErrNoMoves) {
+			t.Errorf("%s: Choose() on a won game = %v, expected ErrNoMoves", s.Name(), err)
+		}
+	}
+}
+
+func TestStrategiesWinAndBlock(t *testing.T) {
+	tests := []struct {
+		name      string
+		gameState []string
+		mark      Mark
+		expected  Move
+	}{
+		{"Win", []string{"O", "O", "", "X", "X", "", "X", "", ""}, O, 2},
+		{"Block", []string{"X", "X", "", "", "O", "", "", "", ""}, O, 2},
+	}
+	for _, s := range builtinStrategies(t) {
+		if s.Name() == "random" {
+			continue
+		}
+		for _, tt := range tests {
+			d, err := s.Choose(context.Background(), FromStrings(tt.gameState), tt.mark)
+			if err != nil || d.Move != tt.expected {
+				t.Errorf("%s: %s Choose() = %d, %v, expected %d", s.Name(), tt.name, d.Move, err, tt.expected)
+			}
+		}
+	}
+}
+
+// neverLoses checks that s never loses against any sequence of opponent
+// moves, with s playing mark and turn to move on b.
+func neverLoses(t *testing.T, s Strategy, b Board, mark, turn Mark) {
+	t.Helper()
+	if over, winner := b.Winner(); over {
+		if winner == mark.Opponent() {
+			t.Fatalf("%s lost as %s:\n%v", s.Name(), mark, b)
+		}
+		return
+	}
+	if turn == mark {
+		d, err := s.Choose(context.Background(), b, mark)
+		if err != nil {
+			t.Fatal(err)
+		}
+		neverLoses(t, s, b.Play(d.Move, mark), mark, turn.Opponent())
+		return
+	}
+	for _, m := range b.LegalMoves() {
+		neverLoses(t, s, b.Play(m, turn), mark, turn.Opponent())
+	}
+}
+
+func TestPerfectStrategiesNeverLose(t *testing.T) {
+	for _, s := range []Strategy{Rules{}, Minimax{Options: Options{Book: StandardBook()}}} {
+		neverLoses(t, s, NewBoard(), X, X)
+		neverLoses(t, s, NewBoard(), O, X)
+	}
+}
+
+func TestRulesDiagnostics(t *testing.T) {
+	// X threatens two forks through corners; O must force play on a side.
+	b := FromStrings([]string{"X", "", "", "", "O", "", "", "", "X"})
+	d, err := Rules{}.Choose(context.Background(), b, O)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Diagnostics["rule"] != "block fork" {
+		t.Errorf("Choose() rule = %v, expected block fork", d.Diagnostics["rule"])
+	}
+	if d.Move != 1 && d.Move != 3 && d.Move != 5 && d.Move != 7 {
+		t.Errorf("Choose() = %d, expected a side square", d.Move)
+	}
+}
+
+func TestRandomIsReproducible(t *testing.T) {
+	a, b := NewRandom(42), NewRandom(42)
+	board := NewBoard()
+	for i := 0; i < 20; i++ {
+		da, _ := a.Choose(context.Background(), board, X)
+		db, _ := b.Choose(context.Background(), board, X)
+		if da.Move != db.Move {
+			t.Fatalf("Random strategies with the same seed diverged at choice %d", i)
+		}
+	}
+}
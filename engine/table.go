@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Bound says how a stored score relates to the true value of a position.
+type Bound uint8
+
+const (
+	// Exact scores are the true value of the position.
+	Exact Bound = iota + 1
+	// Lower scores are a lower bound: the search failed high.
+	Lower
+	// Upper scores are an upper bound: the search failed low.
+	Upper
+)
+
+const (
+	// DefaultTableEntries is the capacity of a table created with
+	// NewTable(0). It comfortably holds every standard position.
+	DefaultTableEntries = 1 << 16
+
+	tableLocks = 64
+)
+
+// Table is a transposition table shared by searches. Positions are keyed
+// by Zobrist hashes canonicalized over the board's symmetries, so rotated
+// and reflected positions share an entry. A Table is safe for concurrent
+// use and may be shared by every search the bot runs.
+type Table struct {
+	entries []tableEntry
+	mask    uint64
+	locks   [tableLocks]sync.Mutex
+
+	hits, misses, stores atomic.Int64
+}
+
+type tableEntry struct {
+	key   uint64
+	score int32
+	depth int16
+	bound Bound
+	move  int16 // in canonical coordinates, -1 if unknown
+}
+
+// TableStats reports table usage since the table was created.
+type TableStats struct {
+	Hits    int64
+	Misses  int64
+	Stores  int64
+	Entries int
+}
+
+// NewTable returns a table holding up to entries positions, rounded up to
+// a power of two. Zero selects DefaultTableEntries.
+func NewTable(entries int) *Table {
+	if entries <= 0 {
+		entries = DefaultTableEntries
+	}
+	n := 1
+	for n < entries {
+		n <<= 1
+	}
+	return &Table{entries: make([]tableEntry, n), mask: uint64(n - 1)}
+}
+
+// Stats returns a snapshot of the table's hit and miss counters.
+func (t *Table) Stats() TableStats {
+	entries := 0
+	for i := range t.entries {
+		lock := &t.locks[i%tableLocks]
+		lock.Lock()
+		if t.entries[i].bound != 0 {
+			entries++
+		}
+		lock.Unlock()
+	}
+	return TableStats{
+		Hits:    t.hits.Load(),
+		Misses:  t.misses.Load(),
+		Stores:  t.stores.Load(),
+		Entries: entries,
+	}
+}
+
+// probe looks up key, counting a hit or miss.
+func (t *Table) probe(key uint64) (tableEntry, bool) {
+	i := key & t.mask
+	lock := &t.locks[i%tableLocks]
+	lock.Lock()
+	e := t.entries[i]
+	lock.Unlock()
+	if e.bound == 0 || e.key != key {
+		t.misses.Add(1)
+		return tableEntry{}, false
+	}
+	t.hits.Add(1)
+	return e, true
+}
+
+// store saves an entry, replacing whatever occupied its slot unless that
+// is a deeper search of the same position.
+func (t *Table) store(e tableEntry) {
+	i := e.key & t.mask
+	lock := &t.locks[i%tableLocks]
+	lock.Lock()
+	if old := t.entries[i]; old.key != e.key || old.depth <= e.depth {
+		t.entries[i] = e
+	}
+	lock.Unlock()
+	t.stores.Add(1)
+}
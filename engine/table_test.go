@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSearcherWithTableMatchesSearcher(t *testing.T) {
+	var positions []Board
+	reachable(NewBoard(), X, map[string]bool{}, &positions)
+
+	// TestSearcherMatchesMiniMax checks the plain Searcher against MiniMax.
+	table := NewTable(0)
+	plain := NewSearcher(nil)
+	for _, b := range positions {
+		turn := sideToMove(b)
+		s := NewSearcher(table)
+		for _, m := range b.LegalMoves() {
+			for _, player := range []Mark{turn, turn.Opponent()} {
+				want := plain.Score(b, player, m, turn, 0)
+				if got := s.Score(b, player, m, turn, 0); got != want {
+					t.Fatalf("Score() for %s at %d = %d, expected %d\n%v", player, m, got, want, b)
+				}
+			}
+		}
+	}
+	if stats := table.Stats(); stats.Hits == 0 || stats.Entries == 0 {
+		t.Errorf("Stats() = %+v, expected hits and entries", stats)
+	}
+}
+
+func TestCanonicalHashSymmetry(t *testing.T) {
+	// The same position under a rotation, a reflection and a transpose.
+	variants := [][]string{
+		{"X", "O", "", "", "", "", "", "", ""},
+		{"", "", "X", "", "", "O", "", "", ""},
+		{"", "O", "X", "", "", "", "", "", ""},
+		{"X", "", "", "O", "", "", "", "", ""},
+	}
+	want, _ := canonicalHash(FromStrings(variants[0]), X, X)
+	for _, v := range variants[1:] {
+		if got, _ := canonicalHash(FromStrings(v), X, X); got != want {
+			t.Errorf("canonicalHash(%v) = %x, expected %x", v, got, want)
+		}
+	}
+
+	different := FromStrings([]string{"X", "", "", "", "O", "", "", "", ""})
+	if got, _ := canonicalHash(different, X, X); got == want {
+		t.Errorf("canonicalHash() collided for distinct positions")
+	}
+	if got, _ := canonicalHash(FromStrings(variants[0]), O, X); got == want {
+		t.Errorf("canonicalHash() ignored the side to move")
+	}
+	if got, _ := canonicalHash(FromStrings(variants[0]), X, O); got == want {
+		t.Errorf("canonicalHash() ignored the player")
+	}
+}
+
+func TestSymmetriesRectangular(t *testing.T) {
+	size := Size{Width: 4, Height: 3, K: 3}
+	syms := symmetries(size)
+	if len(syms) != 4 {
+		t.Fatalf("symmetries(%v) returned %d permutations, expected 4", size, len(syms))
+	}
+	// A half turn maps the top left corner to the bottom right one.
+	if syms[1][0] != 11 {
+		t.Errorf("half turn maps 0 to %d, expected 11", syms[1][0])
+	}
+	if got := len(symmetries(Standard)); got != 8 {
+		t.Errorf("symmetries(Standard) returned %d permutations, expected 8", got)
+	}
+}
+
+func TestTableReusedAcrossSearches(t *testing.T) {
+	table := NewTable(0)
+	b := FromStrings([]string{"X", "", "", "", "", "", "", "", ""})
+
+	first := NewSearcher(table)
+	for _, m := range b.LegalMoves() {
+		first.Score(b, O, m, O, 0)
+	}
+	before := table.Stats()
+
+	second := NewSearcher(table)
+	for _, m := range b.LegalMoves() {
+		second.Score(b, O, m, O, 0)
+	}
+	after := table.Stats()
+
+	if after.Hits <= before.Hits {
+		t.Errorf("second search recorded no table hits: before %+v, after %+v", before, after)
+	}
+	if second.Nodes() >= first.Nodes() {
+		t.Errorf("second search visited %d nodes, expected fewer than %d", second.Nodes(), first.Nodes())
+	}
+}
+
+func TestTableConcurrentSearches(t *testing.T) {
+	table := NewTable(1024)
+	boards := [][]string{
+		{"", "", "", "", "", "", "", "", ""},
+		{"X", "", "", "", "", "", "", "", ""},
+		{"", "", "", "", "X", "", "", "", ""},
+		{"X", "O", "", "", "", "", "", "", ""},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for _, gs := range boards {
+			wg.Add(1)
+			go func(b Board) {
+				defer wg.Done()
+				player := sideToMove(b)
+				want := ScoreMoves(b, player)
+				got := Search(b, player, Options{Table: table}).Scores
+				for m, score := range want {
+					if got[m] != score {
+						t.Errorf("Search() score for %d = %d, expected %d", m, got[m], score)
+					}
+				}
+			}(FromStrings(gs))
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// TieBreak chooses between moves that a search scored equally. Searches
+// use LowestIndex unless their Options name another policy.
+type TieBreak interface {
+	// Name identifies the policy, as accepted by NewTieBreak.
+	Name() string
+	// Break returns one of tied, the moves sharing player's best score on
+	// b, which holds at least two moves in ascending order. opts are the
+	// options of the search that produced the tie.
+	Break(ctx context.Context, b Board, player Mark, tied []Move, opts Options) Move
+}
+
+// TieBreakNames lists the built-in tie-breaking policies.
+var TieBreakNames = []string{"lowest", "random", "traps"}
+
+// NewTieBreak returns the built-in policy with the given name. Random
+// choices are determined by seed.
+func NewTieBreak(name string, seed int64) (TieBreak, error) {
+	switch name {
+	case "lowest":
+		return LowestIndex{}, nil
+	case "random":
+		return NewSeededRandom(seed), nil
+	case "traps":
+		return MostTraps{}, nil
+	}
+	return nil, fmt.Errorf("engine: unknown tie-break policy %q", name)
+}
+
+// LowestIndex always plays the lowest numbered of the tied moves, so that
+// searches are deterministic.
+type LowestIndex struct{}
+
+func (LowestIndex) Name() string { return "lowest" }
+
+func (LowestIndex) Break(ctx context.Context, b Board, player Mark, tied []Move, opts Options) Move {
+	return tied[0]
+}
+
+// SeededRandom plays a random tied move, so that opponents cannot learn
+// which of several equal moves the bot prefers. Its choices are determined
+// by its seed and the order in which it is asked.
+type SeededRandom struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewSeededRandom returns a SeededRandom policy whose choices are
+// determined by seed.
+func NewSeededRandom(seed int64) *SeededRandom {
+	return &SeededRandom{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (*SeededRandom) Name() string { return "random" }
+
+func (r *SeededRandom) Break(ctx context.Context, b Board, player Mark, tied []Move, opts Options) Move {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return tied[r.rng.Intn(len(tied))]
+}
+
+// MostTraps plays the tied move that leaves the opponent the most replies
+// that change the result of the game in player's favour, preferring the
+// lowest numbered move when that too is tied. Replies are scored with the
+// same options as the search that produced the tie, within what remains
+// of ctx.
+type MostTraps struct{}
+
+func (MostTraps) Name() string { return "traps" }
+
+func (MostTraps) Break(ctx context.Context, b Board, player Mark, tied []Move, opts Options) Move {
+	replyOpts := opts
+	replyOpts.TieBreak = LowestIndex{}
+	replyOpts.Budget = 0
+	if replyOpts.MaxDepth > 0 {
+		replyOpts.MaxDepth = max(1, replyOpts.MaxDepth-1)
+	}
+
+	best, bestTraps := tied[0], -1
+	for _, m := range tied {
+		next := b.Play(m, player)
+		if over, _ := next.Winner(); over {
+			return m
+		}
+		replies := SearchContext(ctx, next, player.Opponent(), replyOpts).Scores
+		bestReply := -infinity
+		for _, v := range replies {
+			bestReply = max(bestReply, v)
+		}
+		traps := 0
+		for _, v := range replies {
+			if outcome(v) < outcome(bestReply) {
+				traps++
+			}
+		}
+		if traps > bestTraps {
+			best, bestTraps = m, traps
+		}
+	}
+	return best
+}
+
+// outcome classifies a score as a win (1), draw (0) or loss (-1).
+func outcome(score int) int {
+	switch {
+	case score > 0:
+		return 1
+	case score < 0:
+		return -1
+	}
+	return 0
+}
+
+// chooseBest returns player's highest scoring move and its score, breaking
+// ties with the policy in opts.
+func chooseBest(ctx context.Context, b Board, player Mark, scores map[Move]int, opts Options) (Move, int) {
+	_, bestScore := pickBest(scores)
+	var tied []Move
+	for m, score := range scores {
+		if score == bestScore {
+			tied = append(tied, m)
+		}
+	}
+	sort.Slice(tied, func(i, j int) bool { return tied[i] < tied[j] })
+	switch {
+	case len(tied) == 0:
+		return 0, bestScore
+	case len(tied) == 1:
+		return tied[0], bestScore
+	case opts.TieBreak == nil:
+		return LowestIndex{}.Break(ctx, b, player, tied, opts), bestScore
+	}
+	return opts.TieBreak.Break(ctx, b, player, tied, opts), bestScore
+}
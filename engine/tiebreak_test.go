@@ -0,0 +1,92 @@
+package engine
+
+import "testing"
+
+func TestNewTieBreak(t *testing.T) {
+	for _, name := range TieBreakNames {
+		tb, err := NewTieBreak(name, 1)
+		if err != nil {
+			t.Fatalf("NewTieBreak(%q) error: %v", name, err)
+		}
+		if tb.Name() != name {
+			t.Errorf("NewTieBreak(%q).Name() = %q", name, tb.Name())
+		}
+	}
+	if _, err := NewTieBreak("coin", 1); err == nil {
+		t.Errorf("NewTieBreak(\"coin\") expected an error")
+	}
+}
+
+func TestTieBreaksPlayBestMoves(t *testing.T) {
+	var positions []Board
+	reachable(NewBoard(), X, map[string]bool{}, &positions)
+	for _, name := range TieBreakNames {
+		tb, _ := NewTieBreak(name, 3)
+		for _, b := range positions {
+			player := sideToMove(b)
+			res := Search(b, player, Options{Book: StandardBook(), TieBreak: tb})
+			if res.Scores[res.Move] != res.Score || res.Score != maxScore(res.Scores) {
+				t.Fatalf("%s: Search(%v) played %d scoring %d, best is %d",
+					name, b.Strings(), res.Move, res.Scores[res.Move], maxScore(res.Scores))
+			}
+		}
+	}
+}
+
+func maxScore(scores map[Move]int) int {
+	_, v := pickBest(scores)
+	return v
+}
+
+func TestLowestIndexIsDeterministic(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		if m := Search(NewBoard(), X, Options{TieBreak: LowestIndex{}}).Move; m != 0 {
+			t.Fatalf("Search() = %d, expected the lowest tied move 0", m)
+		}
+	}
+	if m := Search(NewBoard(), X, Options{}).Move; m != 0 {
+		t.Errorf("Search() without a policy = %d, expected 0", m)
+	}
+}
+
+func TestSeededRandomIsReproducible(t *testing.T) {
+	play := func(seed int64) []Move {
+		tb := NewSeededRandom(seed)
+		var moves []Move
+		for i := 0; i < 20; i++ {
+			moves = append(moves, Search(NewBoard(), X, Options{Book: StandardBook(), TieBreak: tb}).Move)
+		}
+		return moves
+	}
+
+	a, b := play(5), play(5)
+	seen := map[Move]bool{}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("seed 5 played %v then %v", a, b)
+		}
+		seen[a[i]] = true
+	}
+	// Every opening move draws, so twenty choices should cover several.
+	if len(seen) < 3 {
+		t.Errorf("seed 5 only played %v", a)
+	}
+}
+
+func TestMostTrapsPrefersTraps(t *testing.T) {
+	// X holds the centre and O a corner. Every move draws, but the top edge
+	// threatens a line O must block, leaving O more ways to go wrong than
+	// the opposite corner does.
+	b := FromStrings([]string{"", "", "", "", "X", "", "", "", "O"})
+	lowest := Search(b, X, Options{})
+	traps := Search(b, X, Options{TieBreak: MostTraps{}})
+	if lowest.Move != 0 {
+		t.Fatalf("Search() = %d, expected 0", lowest.Move)
+	}
+	if traps.Move != 1 {
+		t.Errorf("Search() with MostTraps = %d, expected 1", traps.Move)
+	}
+	if traps.Score != lowest.Score {
+		t.Errorf("MostTraps changed the score from %d to %d", lowest.Score, traps.Score)
+	}
+}
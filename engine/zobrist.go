@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// zobrist holds the random keys used to hash boards of one size, together
+// with the square permutations of the board's symmetries.
+type zobrist struct {
+	squares [][2]uint64 // per square, keys for X and O
+	side    uint64      // mixed in when O is to move
+	player  uint64      // mixed in when scores are from O's point of view
+	size    uint64      // distinguishes boards of different sizes
+
+	// syms[t][i] is the square that square i maps to under symmetry t,
+	// and inverse[t] undoes syms[t].
+	syms    [][]Move
+	inverse [][]Move
+}
+
+var zobristCache sync.Map
+
+// zobristFor returns the hashing keys for boards of the given size. Keys
+// are derived from a fixed seed so hashes are stable between runs.
+func zobristFor(size Size) *zobrist {
+	if z, ok := zobristCache.Load(size); ok {
+		return z.(*zobrist)
+	}
+	seed := int64(size.Width)<<32 | int64(size.Height)<<16 | int64(size.K)
+	rng := rand.New(rand.NewSource(seed))
+	z := &zobrist{
+		squares: make([][2]uint64, size.Cells()),
+		side:    rng.Uint64(),
+		player:  rng.Uint64(),
+		size:    rng.Uint64(),
+	}
+	for i := range z.squares {
+		z.squares[i] = [2]uint64{rng.Uint64(), rng.Uint64()}
+	}
+	z.syms = symmetries(size)
+	z.inverse = make([][]Move, len(z.syms))
+	for t, perm := range z.syms {
+		z.inverse[t] = make([]Move, len(perm))
+		for i, j := range perm {
+			z.inverse[t][j] = Move(i)
+		}
+	}
+	actual, _ := zobristCache.LoadOrStore(size, z)
+	return actual.(*zobrist)
+}
+
+// symmetries returns the square permutations that map a board of the given
+// size onto itself while preserving its lines: the four rotations and four
+// reflections of a square board, or the identity, half turn and two axis
+// reflections of a rectangular one. The identity is always first.
+func symmetries(size Size) [][]Move {
+	w, h := size.Width, size.Height
+	transforms := []func(r, c int) (int, int){
+		func(r, c int) (int, int) { return r, c },
+		func(r, c int) (int, int) { return h - 1 - r, w - 1 - c },
+		func(r, c int) (int, int) { return r, w - 1 - c },
+		func(r, c int) (int, int) { return h - 1 - r, c },
+	}
+	if w == h {
+		transforms = append(transforms,
+			func(r, c int) (int, int) { return c, w - 1 - r },
+			func(r, c int) (int, int) { return w - 1 - c, r },
+			func(r, c int) (int, int) { return c, r },
+			func(r, c int) (int, int) { return w - 1 - c, w - 1 - r },
+		)
+	}
+	syms := make([][]Move, len(transforms))
+	for t, f := range transforms {
+		syms[t] = make([]Move, size.Cells())
+		for i := range syms[t] {
+			r, c := f(i/w, i%w)
+			syms[t][i] = Move(r*w + c)
+		}
+	}
+	return syms
+}
+
+// canonicalHash returns the smallest hash of b over all of its symmetries,
+// with side to move and player mixed in, along with the index of the
+// symmetry that produced it.
+func canonicalHash(b Board, side, player Mark) (hash uint64, sym int) {
+	z := zobristFor(b.size)
+	hashes := make([]uint64, len(z.syms))
+	for i, m := range b.cells {
+		var k int
+		switch m {
+		case X:
+			k = 0
+		case O:
+			k = 1
+		default:
+			continue
+		}
+		for t, perm := range z.syms {
+			hashes[t] ^= z.squares[perm[i]][k]
+		}
+	}
+	for t, h := range hashes {
+		if t == 0 || h < hash {
+			hash, sym = h, t
+		}
+	}
+	hash ^= z.size
+	if side == O {
+		hash ^= z.side
+	}
+	if player == O {
+		hash ^= z.player
+	}
+	return hash, sym
+}
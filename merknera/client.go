@@ -0,0 +1,216 @@
+// Package merknera is a client for the Merknera game server's JSON-RPC
+// API, which bots call to register themselves.
+//
+// Calls time out per attempt and are retried with exponential backoff and
+// jitter when they fail in a way that may pass, such as a refused
+// connection or a 503. Failures are reported as a *TransportError,
+// *StatusError, *RPCError or *DecodeError.
+package merknera
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/models"
+)
+
+// maxResponseBytes bounds how much of a response is read.
+const maxResponseBytes = 1 << 20
+
+// maxErrorBody bounds how much of a response body errors keep.
+const maxErrorBody = 512
+
+// Defaults used for zero Options fields.
+const (
+	DefaultTimeout     = 10 * time.Second
+	DefaultMaxAttempts = 5
+)
+
+// Backoff computes the delay before each retry: Initial, growing by
+// Multiplier per attempt up to Max, of which a random fraction up to
+// Jitter is taken off so that many clients do not retry in step.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+// DefaultBackoff is used when Options.Backoff is zero.
+var DefaultBackoff = Backoff{Initial: 250 * time.Millisecond, Max: 10 * time.Second, Multiplier: 2, Jitter: 0.5}
+
+// Delay returns the delay before retry number retry, counting from zero,
+// given a random number r in [0, 1).
+func (b Backoff) Delay(retry int, r float64) time.Duration {
+	d := float64(b.Initial) * math.Pow(b.Multiplier, float64(retry))
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	return time.Duration(d * (1 - b.Jitter*r))
+}
+
+// Options configures a Client. The zero value selects the defaults.
+type Options struct {
+	// Transport sends the HTTP requests. Nil selects
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+	// Timeout bounds each attempt. Zero selects DefaultTimeout.
+	Timeout time.Duration
+	// MaxAttempts bounds the attempts per call, the first included. Zero
+	// or less selects DefaultMaxAttempts.
+	MaxAttempts int
+	// Backoff spaces out retries. The zero value selects DefaultBackoff.
+	Backoff Backoff
+	// Logger receives a warning for every retry. Nil selects
+	// slog.Default.
+	Logger *slog.Logger
+}
+
+// Client calls the JSON-RPC methods of a Merknera server. It is safe for
+// concurrent use.
+type Client struct {
+	url    string
+	http   *http.Client
+	opts   Options
+	lastId atomic.Int64
+}
+
+// NewClient returns a client calling the server at url.
+func NewClient(url string, opts Options) *Client {
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.Backoff == (Backoff{}) {
+		opts.Backoff = DefaultBackoff
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Client{url: url, http: &http.Client{Transport: opts.Transport}, opts: opts}
+}
+
+// URL returns the server's address.
+func (c *Client) URL() string {
+	return c.url
+}
+
+// Register registers a bot for a game.
+func (c *Client) Register(ctx context.Context, params models.RegistrationParams) (models.RegistrationResponse, error) {
+	var resp models.RegistrationResponse
+	err := c.Call(ctx, "RegistrationService.Register", params, &resp)
+	return resp, err
+}
+
+// DefaultDeregisterMethod is the method Deregister calls.
+const DefaultDeregisterMethod = "RegistrationService.Deregister"
+
+// Deregister tells the server a bot is going offline, by calling method,
+// or DefaultDeregisterMethod if method is empty.
+func (c *Client) Deregister(ctx context.Context, method string, params models.DeregistrationParams) error {
+	if method == "" {
+		method = DefaultDeregisterMethod
+	}
+	return c.Call(ctx, method, params, nil)
+}
+
+// Call calls method with params and decodes its result into result,
+// unless result is nil. Transient failures are retried until the call
+// succeeds, MaxAttempts is reached or ctx is done; the last error is
+// returned.
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	body, err := json.Marshal(models.ClientRpcRequest{Method: method, Params: params, Id: int(c.lastId.Add(1))})
+	if err != nil {
+		return fmt.Errorf("merknera: %s: encoding params: %w", method, err)
+	}
+	for attempt := 1; ; attempt++ {
+		err = c.attempt(ctx, method, body, result)
+		if err == nil || !Transient(err) || attempt >= c.opts.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		delay := c.opts.Backoff.Delay(attempt-1, rand.Float64())
+		c.opts.Logger.WarnContext(ctx, "retrying merknera call",
+			"method", method, "attempt", attempt, "delay", delay, "error", err)
+		if sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+// attempt makes a single call.
+func (c *Client) attempt(ctx context.Context, method string, body []byte, result interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("merknera: %s: %w", method, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return &TransportError{Method: method, URL: c.url, Err: err}
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return &TransportError{Method: method, URL: c.url, Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Method: method, StatusCode: resp.StatusCode, Status: resp.Status, Body: truncate(respBody)}
+	}
+
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &reply); err != nil {
+		return &DecodeError{Method: method, Body: truncate(respBody), Err: err}
+	}
+	if rpcErr := decodeError(method, reply.Error); rpcErr != nil {
+		return rpcErr
+	}
+	if result == nil || len(reply.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(reply.Result, result); err != nil {
+		return &DecodeError{Method: method, Body: truncate(respBody), Err: err}
+	}
+	return nil
+}
+
+// decodeError returns the error in a reply, sent either as a Merknera
+// string or a JSON-RPC 2.0 error object, or nil if there is none.
+func decodeError(method string, raw json.RawMessage) error {
+	if len(raw) == 0 || string(raw) == "null" || string(raw) == `""` {
+		return nil
+	}
+	var message string
+	if json.Unmarshal(raw, &message) == nil {
+		return &RPCError{Method: method, Message: message}
+	}
+	var obj models.RpcError
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return &DecodeError{Method: method, Body: truncate(raw), Err: err}
+	}
+	return &RPCError{Method: method, Code: obj.Code, Message: obj.Message, Data: obj.Data}
+}
+
+func truncate(body []byte) []byte {
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	return append([]byte(nil), body...)
+}
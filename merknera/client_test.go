@@ -0,0 +1,221 @@
+package merknera
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/models"
+)
+
+// roundTripFunc is an http.RoundTripper answering with a function.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// reply returns a response with the given status and body.
+func reply(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Header:     make(http.Header),
+	}
+}
+
+// script answers the nth request with responses[n], counting the calls.
+func script(calls *int, responses ...func(req *http.Request) (*http.Response, error)) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		n := *calls
+		*calls++
+		if n >= len(responses) {
+			n = len(responses) - 1
+		}
+		return responses[n](req)
+	}
+}
+
+func respond(status int, body string) func(*http.Request) (*http.Response, error) {
+	return func(*http.Request) (*http.Response, error) { return reply(status, body), nil }
+}
+
+func fail(err error) func(*http.Request) (*http.Response, error) {
+	return func(*http.Request) (*http.Response, error) { return nil, err }
+}
+
+func testClient(rt http.RoundTripper) *Client {
+	return NewClient("http://merknera.test/rpc", Options{
+		Transport:   rt,
+		Timeout:     time.Second,
+		MaxAttempts: 3,
+		Backoff:     Backoff{Initial: time.Millisecond, Max: 2 * time.Millisecond, Multiplier: 2},
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+}
+
+func TestClientRegister(t *testing.T) {
+	var sent models.ClientRpcRequest
+	var params models.RegistrationParams
+	c := testClient(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request = %s with Content-Type %q", req.Method, req.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &sent)
+		raw, _ := json.Marshal(sent.Params)
+		json.Unmarshal(raw, &params)
+		return reply(http.StatusOK, `{"result":{"message":"welcome"},"id":1}`), nil
+	}))
+
+	resp, err := c.Register(context.Background(), models.RegistrationParams{Token: "t", BotName: "bot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "welcome" {
+		t.Errorf("Register() = %+v", resp)
+	}
+	if sent.Method != "RegistrationService.Register" || params.BotName != "bot" || sent.Id == 0 {
+		t.Errorf("sent %+v with params %+v", sent, params)
+	}
+}
+
+func TestClientRetriesTransientFailures(t *testing.T) {
+	calls := 0
+	c := testClient(script(&calls,
+		fail(errors.New("connection refused")),
+		respond(http.StatusServiceUnavailable, "down for maintenance"),
+		respond(http.StatusOK, `{"result":{"message":"ok"}}`),
+	))
+	resp, err := c.Register(context.Background(), models.RegistrationParams{})
+	if err != nil || resp.Message != "ok" {
+		t.Fatalf("Register() = %+v, %v", resp, err)
+	}
+	if calls != 3 {
+		t.Errorf("made %d attempts, expected 3", calls)
+	}
+}
+
+func TestClientNegativeMaxAttempts(t *testing.T) {
+	calls := 0
+	c := testClient(script(&calls, fail(errors.New("connection refused"))))
+	c.opts.MaxAttempts = -1
+	if err := c.Call(context.Background(), "Status.Ping", nil, nil); err == nil {
+		t.Fatal("Call() to a server that is down succeeded")
+	}
+	if calls != 1 {
+		t.Errorf("made %d attempts with MaxAttempts -1, expected 1", calls)
+	}
+	c = NewClient("http://merknera.test/rpc", Options{MaxAttempts: -1})
+	if c.opts.MaxAttempts != DefaultMaxAttempts {
+		t.Errorf("NewClient() with MaxAttempts -1 allows %d attempts, expected %d", c.opts.MaxAttempts, DefaultMaxAttempts)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response func(*http.Request) (*http.Response, error)
+		attempts int
+		check    func(err error) bool
+	}{
+		{"transport", fail(errors.New("no route to host")), 3, func(err error) bool {
+			var e *TransportError
+			return errors.As(err, &e) && strings.Contains(e.Error(), "no route to host")
+		}},
+		{"server error status", respond(http.StatusBadGateway, "bad gateway"), 3, func(err error) bool {
+			var e *StatusError
+			return errors.As(err, &e) && e.StatusCode == http.StatusBadGateway && string(e.Body) == "bad gateway"
+		}},
+		{"client error status", respond(http.StatusNotFound, ""), 1, func(err error) bool {
+			var e *StatusError
+			return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+		}},
+		{"merknera error", respond(http.StatusOK, `{"result":null,"error":"invalid token","id":1}`), 1, func(err error) bool {
+			var e *RPCError
+			return errors.As(err, &e) && e.Message == "invalid token" && e.Code == 0
+		}},
+		{"JSON-RPC 2.0 error", respond(http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params"},"id":1}`), 1, func(err error) bool {
+			var e *RPCError
+			return errors.As(err, &e) && e.Code == models.InvalidParams && e.Message == "Invalid params"
+		}},
+		{"invalid JSON", respond(http.StatusOK, `<html>`), 1, func(err error) bool {
+			var e *DecodeError
+			return errors.As(err, &e) && string(e.Body) == "<html>"
+		}},
+		{"wrong result type", respond(http.StatusOK, `{"result":[1,2]}`), 1, func(err error) bool {
+			var e *DecodeError
+			return errors.As(err, &e)
+		}},
+	}
+	for _, tt := range tests {
+		calls := 0
+		_, err := testClient(script(&calls, tt.response)).Register(context.Background(), models.RegistrationParams{})
+		if !tt.check(err) {
+			t.Errorf("%s: Register() error = %#v", tt.name, err)
+		}
+		if calls != tt.attempts {
+			t.Errorf("%s: made %d attempts, expected %d", tt.name, calls, tt.attempts)
+		}
+		if Transient(err) != (tt.attempts > 1) {
+			t.Errorf("%s: Transient(%v) = %v", tt.name, err, Transient(err))
+		}
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	calls := 0
+	hang := func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	c := testClient(script(&calls, hang))
+	c.opts.Timeout = 10 * time.Millisecond
+
+	start := time.Now()
+	err := c.Call(context.Background(), "Status.Ping", nil, nil)
+	var e *TransportError
+	if !errors.As(err, &e) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Call() error = %v, expected a timeout", err)
+	}
+	if calls != 3 || time.Since(start) > time.Second {
+		t.Errorf("made %d attempts in %v, expected 3 short ones", calls, time.Since(start))
+	}
+
+	// A cancelled call is not retried.
+	calls = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Call(ctx, "Status.Ping", nil, nil); !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("Call() with a cancelled context = %v after %d attempts", err, calls)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2, Jitter: 0.5}
+	tests := []struct {
+		retry int
+		r     float64
+		want  time.Duration
+	}{
+		{0, 0, 100 * time.Millisecond},
+		{1, 0, 200 * time.Millisecond},
+		{3, 0, 800 * time.Millisecond},
+		{4, 0, time.Second},
+		{20, 0, time.Second},
+		{1, 0.5, 150 * time.Millisecond},
+		{4, 0.75, 625 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := b.Delay(tt.retry, tt.r); got != tt.want {
+			t.Errorf("Delay(%d, %v) = %v, expected %v", tt.retry, tt.r, got, tt.want)
+		}
+	}
+}
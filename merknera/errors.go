@@ -0,0 +1,84 @@
+package merknera
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// TransportError reports a call that got no HTTP response, for example
+// because the server could not be reached or the attempt timed out.
+type TransportError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("merknera: %s: %s: %v", e.Method, e.URL, e.Err)
+}
+
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// StatusError reports an HTTP response with a status other than 200 OK.
+type StatusError struct {
+	Method     string
+	StatusCode int
+	Status     string
+	// Body is the start of the response body.
+	Body []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("merknera: %s: unexpected HTTP status %s", e.Method, e.Status)
+}
+
+// RPCError reports an error returned by the called method. Merknera sends
+// errors as plain strings, which have no code; JSON-RPC 2.0 servers send
+// a code, message and optional data.
+type RPCError struct {
+	Method  string
+	Code    int
+	Message string
+	Data    interface{}
+}
+
+func (e *RPCError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("merknera: %s: %s (code %d)", e.Method, e.Message, e.Code)
+	}
+	return fmt.Sprintf("merknera: %s: %s", e.Method, e.Message)
+}
+
+// DecodeError reports a response that is not a valid JSON-RPC reply, or
+// whose result does not fit the value it was decoded into.
+type DecodeError struct {
+	Method string
+	// Body is the start of the response body.
+	Body []byte
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("merknera: %s: invalid response: %v", e.Method, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// Transient reports whether err is worth retrying: a transport failure or
+// a status suggesting the server is briefly unavailable or overloaded.
+func Transient(err error) bool {
+	var transport *TransportError
+	if errors.As(err, &transport) {
+		return true
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		switch status.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return status.StatusCode >= 500
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package merknera
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/models"
+)
+
+// Registration states reported by Registrar.Status.
+const (
+	StateUnregistered = "unregistered"
+	StateWaiting      = "waiting for endpoint"
+	StateRegistering  = "registering"
+	StateRegistered   = "registered"
+	StateDeregistered = "deregistered"
+)
+
+// unknownBot matches messages in which the game server says it does not
+// know the bot.
+var unknownBot = regexp.MustCompile(`(?i)unknown bot|bot .*(not found|not registered|unknown)|not registered`)
+
+// alreadyRegistered matches registration errors that mean the server
+// already knows the bot.
+var alreadyRegistered = regexp.MustCompile(`(?i)already (registered|exists)`)
+
+// UnknownBot reports whether a message from the game server, for example
+// in a TicTacToe.Error call, says that it does not know the bot.
+func UnknownBot(message string) bool {
+	return unknownBot.MatchString(message)
+}
+
+// RegistrarOptions configures a Registrar.
+type RegistrarOptions struct {
+	// Params is sent with every registration.
+	Params models.RegistrationParams
+	// Retry spaces out failed registrations. The zero value selects a
+	// backoff from one second to a minute.
+	Retry Backoff
+	// Probe, if set, calls the bot's own endpoint, which must answer
+	// Status.Ping before the bot registers.
+	Probe *Client
+	// ProbeInterval spaces out probes. Zero selects one second.
+	ProbeInterval time.Duration
+	// QuietPeriod, if set, is how long the game server may go without
+	// contacting the bot, as reported by Seen, before the bot assumes it
+	// was forgotten and registers again.
+	QuietPeriod time.Duration
+	// DeregisterMethod is the method Deregister calls. Empty selects
+	// DefaultDeregisterMethod.
+	DeregisterMethod string
+	// Logger receives progress reports. Nil selects slog.Default.
+	Logger *slog.Logger
+}
+
+// Status describes a Registrar's progress.
+type Status struct {
+	State string `json:"state"`
+	// Message is the server's reply to the last successful registration.
+	Message string `json:"message,omitempty"`
+	// Attempts counts registrations tried since the last success.
+	Attempts     int       `json:"attempts"`
+	LastError    string    `json:"lasterror,omitempty"`
+	RegisteredAt time.Time `json:"registeredat"`
+	// Registrations counts successful registrations.
+	Registrations int `json:"registrations"`
+}
+
+// Registrar keeps a bot registered with the game server: it waits for the
+// bot's endpoint, retries registration until the server accepts it and
+// registers again when the server appears to have forgotten the bot.
+type Registrar struct {
+	client *Client
+	opts   RegistrarOptions
+	again  chan struct{}
+
+	mu       sync.Mutex
+	status   Status
+	lastSeen time.Time
+}
+
+// NewRegistrar returns a registrar registering through client.
+func NewRegistrar(client *Client, opts RegistrarOptions) *Registrar {
+	if opts.Retry == (Backoff{}) {
+		opts.Retry = Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2, Jitter: 0.2}
+	}
+	if opts.ProbeInterval == 0 {
+		opts.ProbeInterval = time.Second
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Registrar{
+		client: client,
+		opts:   opts,
+		again:  make(chan struct{}, 1),
+		status: Status{State: StateUnregistered},
+	}
+}
+
+// Status returns the registrar's progress.
+func (r *Registrar) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Registered reports whether the bot is currently registered.
+func (r *Registrar) Registered() bool {
+	return r.Status().State == StateRegistered
+}
+
+// Seen records that the game server contacted the bot.
+func (r *Registrar) Seen() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastSeen = time.Now()
+}
+
+// Reregister asks Run to register the bot again, for example because the
+// game server reported it unknown.
+func (r *Registrar) Reregister(reason string) {
+	r.opts.Logger.Warn("registering again", "reason", reason)
+	select {
+	case r.again <- struct{}{}:
+	default:
+	}
+}
+
+// Register waits for the bot's endpoint if there is a probe, then
+// registers, retrying until the server accepts or ctx is done. A server
+// saying the bot is already registered counts as accepting.
+func (r *Registrar) Register(ctx context.Context) (models.RegistrationResponse, error) {
+	if r.opts.Probe != nil {
+		if err := r.waitForEndpoint(ctx); err != nil {
+			return models.RegistrationResponse{}, err
+		}
+	}
+	r.setState(StateRegistering)
+	for retry := 0; ; retry++ {
+		resp, err := r.client.Register(ctx, r.opts.Params)
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) && alreadyRegistered.MatchString(rpcErr.Message) {
+			resp, err = models.RegistrationResponse{Message: rpcErr.Message}, nil
+		}
+		r.mu.Lock()
+		if err == nil {
+			r.status = Status{
+				State:         StateRegistered,
+				Message:       resp.Message,
+				RegisteredAt:  time.Now(),
+				Registrations: r.status.Registrations + 1,
+			}
+			r.lastSeen = time.Now()
+		} else {
+			r.status.Attempts++
+			r.status.LastError = err.Error()
+		}
+		r.mu.Unlock()
+		if err == nil {
+			r.opts.Logger.InfoContext(ctx, "registered", "message", resp.Message)
+			return resp, nil
+		}
+
+		delay := r.opts.Retry.Delay(retry, rand.Float64())
+		r.opts.Logger.WarnContext(ctx, "registration failed", "error", err, "retryin", delay)
+		if err := sleep(ctx, delay); err != nil {
+			r.setState(StateUnregistered)
+			return models.RegistrationResponse{}, err
+		}
+	}
+}
+
+// Run registers the bot, then registers it again whenever Reregister is
+// called or the game server stays quiet for longer than QuietPeriod. It
+// returns when ctx is done.
+func (r *Registrar) Run(ctx context.Context) error {
+	var tick <-chan time.Time
+	if r.opts.QuietPeriod > 0 {
+		t := time.NewTicker(r.opts.QuietPeriod / 2)
+		defer t.Stop()
+		tick = t.C
+	}
+	if _, err := r.Register(ctx); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.again:
+		case <-tick:
+			r.mu.Lock()
+			quiet := time.Since(r.lastSeen)
+			r.mu.Unlock()
+			if quiet < r.opts.QuietPeriod {
+				continue
+			}
+			r.opts.Logger.WarnContext(ctx, "game server has gone quiet, registering again", "quiet", quiet)
+		}
+		if _, err := r.Register(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// Deregister tells the server the bot is going offline. Call it after Run
+// has returned, or Run may register the bot again.
+func (r *Registrar) Deregister(ctx context.Context) error {
+	err := r.client.Deregister(ctx, r.opts.DeregisterMethod, models.DeregistrationParams{
+		Token:      r.opts.Params.Token,
+		BotName:    r.opts.Params.BotName,
+		BotVersion: r.opts.Params.BotVersion,
+	})
+	if err != nil {
+		r.opts.Logger.WarnContext(ctx, "deregistration failed", "error", err)
+		return err
+	}
+	r.setState(StateDeregistered)
+	r.opts.Logger.InfoContext(ctx, "deregistered")
+	return nil
+}
+
+// waitForEndpoint pings the bot's own endpoint until it answers.
+func (r *Registrar) waitForEndpoint(ctx context.Context) error {
+	r.setState(StateWaiting)
+	for {
+		err := r.opts.Probe.Call(ctx, "Status.Ping", nil, nil)
+		if err == nil {
+			return nil
+		}
+		r.opts.Logger.DebugContext(ctx, "endpoint not reachable yet", "url", r.opts.Probe.URL(), "error", err)
+		if err := sleep(ctx, r.opts.ProbeInterval); err != nil {
+			r.setState(StateUnregistered)
+			return err
+		}
+	}
+}
+
+func (r *Registrar) setState(state string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.State = state
+}
+
+// sleep waits for d or until ctx is done, returning ctx's error.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,158 @@
+package merknera
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetry keeps registration retries short in tests.
+var fastRetry = Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1}
+
+func testRegistrar(client *Client, opts RegistrarOptions) *Registrar {
+	opts.Retry = fastRetry
+	opts.ProbeInterval = time.Millisecond
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewRegistrar(client, opts)
+}
+
+func TestRegistrarRetriesUntilAccepted(t *testing.T) {
+	calls := 0
+	client := testClient(script(&calls,
+		respond(http.StatusOK, `{"error":"registration is closed"}`),
+		fail(errors.New("connection refused")),
+		respond(http.StatusOK, `{"result":{"message":"welcome"}}`),
+	))
+	r := testRegistrar(client, RegistrarOptions{})
+	if r.Registered() || r.Status().State != StateUnregistered {
+		t.Fatalf("new registrar status = %+v", r.Status())
+	}
+
+	resp, err := r.Register(context.Background())
+	if err != nil || resp.Message != "welcome" {
+		t.Fatalf("Register() = %+v, %v", resp, err)
+	}
+	status := r.Status()
+	if !r.Registered() || status.Message != "welcome" || status.Registrations != 1 || status.RegisteredAt.IsZero() {
+		t.Errorf("Status() = %+v", status)
+	}
+	if calls != 3 {
+		t.Errorf("made %d calls, expected 3", calls)
+	}
+}
+
+func TestRegistrarAlreadyRegistered(t *testing.T) {
+	calls := 0
+	r := testRegistrar(testClient(script(&calls, respond(http.StatusOK, `{"error":"bot version already exists"}`))), RegistrarOptions{})
+	if _, err := r.Register(context.Background()); err != nil || !r.Registered() {
+		t.Errorf("Register() = %v with status %+v", err, r.Status())
+	}
+}
+
+func TestRegistrarGivesUpWhenCancelled(t *testing.T) {
+	calls := 0
+	r := testRegistrar(testClient(script(&calls, respond(http.StatusOK, `{"error":"invalid token"}`))), RegistrarOptions{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := r.Register(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Register() = %v, expected the deadline", err)
+	}
+	status := r.Status()
+	if status.State != StateUnregistered || status.Attempts == 0 || status.LastError == "" {
+		t.Errorf("Status() = %+v", status)
+	}
+}
+
+func TestRegistrarWaitsForEndpoint(t *testing.T) {
+	var up atomic.Bool
+	var probes, calls int
+	probe := testClient(script(&probes, func(*http.Request) (*http.Response, error) {
+		if !up.Load() {
+			return nil, errors.New("connection refused")
+		}
+		return reply(http.StatusOK, `{"result":{"ping":"OK"}}`), nil
+	}))
+	probe.opts.MaxAttempts = 1
+	client := testClient(script(&calls, func(*http.Request) (*http.Response, error) {
+		if !up.Load() {
+			t.Error("registered before the endpoint was up")
+		}
+		return reply(http.StatusOK, `{"result":{"message":"welcome"}}`), nil
+	}))
+	r := testRegistrar(client, RegistrarOptions{Probe: probe})
+
+	done := make(chan error)
+	go func() {
+		_, err := r.Register(context.Background())
+		done <- err
+	}()
+	for r.Status().State != StateWaiting {
+		time.Sleep(time.Millisecond)
+	}
+	up.Store(true)
+	if err := <-done; err != nil || !r.Registered() {
+		t.Errorf("Register() = %v with status %+v", err, r.Status())
+	}
+}
+
+func TestRegistrarRunRegistersAgain(t *testing.T) {
+	client := testClient(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return reply(http.StatusOK, `{"result":{"message":"welcome"}}`), nil
+	}))
+	r := testRegistrar(client, RegistrarOptions{QuietPeriod: 50 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	waitFor := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for r.Status().Registrations < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("Registrations = %d, expected %d", r.Status().Registrations, n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor(1)
+
+	// While the game server keeps in touch, nothing happens until it
+	// reports the bot unknown.
+	for i := 0; i < 10; i++ {
+		r.Seen()
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := r.Status().Registrations; n != 1 {
+		t.Errorf("Registrations = %d while the server was in touch, expected 1", n)
+	}
+	r.Reregister("unknown bot")
+	waitFor(2)
+
+	// Silence for the quiet period triggers registration too.
+	waitFor(3)
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() = %v, expected it to stop when cancelled", err)
+	}
+}
+
+func TestUnknownBot(t *testing.T) {
+	for message, want := range map[string]bool{
+		"unknown bot":                    true,
+		"Bot 'tictac' is not registered": true,
+		"bot not found":                  true,
+		"invalid move":                   false,
+		"game 4 timed out":               false,
+	} {
+		if got := UnknownBot(message); got != want {
+			t.Errorf("UnknownBot(%q) = %v, expected %v", message, got, want)
+		}
+	}
+}
@@ -1,6 +1,76 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the JSON-RPC protocol version spoken by Request and Response.
+const Version = "2.0"
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+)
+
+// BoardAnomaly is the server error code for a NextMove board that is not
+// a legal successor of the game's previous board.
+const BoardAnomaly = -32001
+
+// Request is a JSON-RPC 2.0 request. Id is nil for notifications, which
+// have no id member, and holds the JSON null for requests with a null id.
+type Request struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Id      json.RawMessage `json:"id,omitempty"`
+}
+
+// Response is a JSON-RPC 2.0 response. Exactly one of Result and Error is
+// sent; Id is null when the request's id could not be determined.
+type Response struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *RpcError       `json:"error,omitempty"`
+	Id      json.RawMessage `json:"id"`
+}
+
+// MarshalJSON leaves out the result member of error responses, as the
+// specification requires.
+func (r Response) MarshalJSON() ([]byte, error) {
+	if r.Error != nil {
+		return json.Marshal(struct {
+			JsonRpc string          `json:"jsonrpc"`
+			Error   *RpcError       `json:"error"`
+			Id      json.RawMessage `json:"id"`
+		}{r.JsonRpc, r.Error, r.Id})
+	}
+	type response Response
+	return json.Marshal(response(r))
+}
+
+// RpcError is a JSON-RPC 2.0 error object.
+type RpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *RpcError) Error() string {
+	switch data := e.Data.(type) {
+	case string:
+		if data != "" {
+			return e.Message + ": " + data
+		}
+	case fmt.Stringer:
+		return e.Message + ": " + data.String()
+	}
+	return e.Message
+}
 
 type ClientRpcRequest struct {
 	Method string      `json:"method"`
@@ -41,6 +111,14 @@ type RegistrationResponse struct {
 	Message string `json:"message"`
 }
 
+// DeregistrationParams take a bot offline, identifying it as it was
+// registered.
+type DeregistrationParams struct {
+	Token      string `json:"token"`
+	BotName    string `json:"botname"`
+	BotVersion string `json:"botversion"`
+}
+
 type StatusPingResponse struct {
 	Ping string `json:"ping"`
 }
@@ -56,14 +134,46 @@ type StatusResponseParams struct {
 }
 
 // Custom Models for TicTacToe only from here on
+// Width, Height and WinLength describe m,n,k variants and default to the
+// standard 3x3 three-in-a-row game when absent. Deadline, if set, is the
+// time in Unix milliseconds by which the server expects a reply.
+// Difficulty, if set, overrides the bot's strategy for this game with one
+// of the levels "easy", "medium", "hard" or "perfect".
 type NextMoveParams struct {
-	GameId    int      `json:"gameid"`
-	Mark      string   `json:"mark"`
-	GameState []string `json:"gamestate"`
+	GameId     int      `json:"gameid"`
+	Mark       string   `json:"mark"`
+	GameState  []string `json:"gamestate"`
+	Width      int      `json:"width,omitempty"`
+	Height     int      `json:"height,omitempty"`
+	WinLength  int      `json:"winlength,omitempty"`
+	Deadline   int64    `json:"deadline,omitempty"`
+	Difficulty string   `json:"difficulty,omitempty"`
 }
 
+// Analysis is only sent when the bot is configured to explain its moves.
 type NextMoveResponseParams struct {
-	Position int `json:"position"`
+	Position int       `json:"position"`
+	Analysis *Analysis `json:"analysis,omitempty"`
+}
+
+// Analysis explains how a move was chosen. Outcome reads like "win in 3
+// plies", "draw", "loss in 2 plies" or "unknown" when the search was cut
+// short. PV is the line of play the bot expects, starting with its move.
+type Analysis struct {
+	Score    int         `json:"score"`
+	Outcome  string      `json:"outcome"`
+	Moves    []MoveScore `json:"moves"`
+	PV       []int       `json:"pv"`
+	Nodes    int64       `json:"nodes"`
+	Depth    int         `json:"depth"`
+	Complete bool        `json:"complete"`
+	Book     bool        `json:"book,omitempty"`
+}
+
+type MoveScore struct {
+	Position int    `json:"position"`
+	Score    int    `json:"score"`
+	Outcome  string `json:"outcome"`
 }
 
 type Complete struct {
@@ -71,4 +181,7 @@ type Complete struct {
 	Mark      string   `json:"mark"`
 	Winner    bool     `json:"winner"`
 	GameState []string `json:"gamestate"`
+	Width     int      `json:"width,omitempty"`
+	Height    int      `json:"height,omitempty"`
+	WinLength int      `json:"winlength,omitempty"`
 }
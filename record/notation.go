@@ -0,0 +1,56 @@
+package record
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/purnet/TicTacToeBot/engine"
+)
+
+// Notation chooses how squares are written.
+type Notation int
+
+const (
+	// Algebraic writes a column letter and a row number, a1 being the
+	// bottom-left square. Boards wider than 26 columns are written with
+	// Index instead.
+	Algebraic Notation = iota
+	// Index writes the square's index, 0 being the top-left square.
+	Index
+)
+
+// Square returns m on r's board in algebraic notation, or as an index if
+// the board is too wide for letters.
+func (r Record) Square(m engine.Move) string {
+	return square(r.Size, m, Algebraic)
+}
+
+func square(s engine.Size, m engine.Move, n Notation) string {
+	if n == Index || s.Width < 1 || s.Width > 26 || m < 0 || int(m) >= s.Width*s.Height {
+		return strconv.Itoa(int(m))
+	}
+	row, col := int(m)/s.Width, int(m)%s.Width
+	return fmt.Sprintf("%c%d", 'a'+col, s.Height-row)
+}
+
+// ParseSquare parses a square in either notation for a board of the given
+// size.
+func ParseSquare(s string, size engine.Size) (engine.Move, error) {
+	if s == "" {
+		return 0, fmt.Errorf("record: empty square")
+	}
+	if c := s[0]; c >= 'a' && c <= 'z' {
+		row, err := strconv.Atoi(s[1:])
+		col := int(c - 'a')
+		if err != nil || strings.HasPrefix(s[1:], "+") || row < 1 || row > size.Height || col >= size.Width {
+			return 0, fmt.Errorf("record: %q is not a square on a %dx%d board", s, size.Width, size.Height)
+		}
+		return engine.Move((size.Height-row)*size.Width + col), nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil || s[0] == '+' || s[0] == '-' || i >= size.Cells() {
+		return 0, fmt.Errorf("record: %q is not a square on a %dx%d board", s, size.Width, size.Height)
+	}
+	return engine.Move(i), nil
+}
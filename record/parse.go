@@ -0,0 +1,332 @@
+package record
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/engine"
+)
+
+// Parse reads a record from s, which must hold exactly one.
+func Parse(s string) (Record, error) {
+	recs, err := ParseAll(strings.NewReader(s))
+	if err != nil {
+		return Record{}, err
+	}
+	if len(recs) != 1 {
+		return Record{}, fmt.Errorf("record: found %d games, expected 1", len(recs))
+	}
+	return recs[0], nil
+}
+
+// ParseAll reads every record from r. Squares may be in either notation,
+// and text from ';' to the end of a line is ignored. Each record is
+// checked as by Record.Check.
+func ParseAll(r io.Reader) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("record: %w", err)
+	}
+	p := &parser{src: string(data), line: 1}
+	var recs []Record
+	for {
+		p.skip()
+		if p.eof() {
+			return recs, nil
+		}
+		rec, err := p.record()
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+}
+
+type parser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	return p.src[p.pos]
+}
+
+func (p *parser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("record: line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// skip skips white space and ';' comments.
+func (p *parser) skip() {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ';':
+			for !p.eof() && p.peek() != '\n' {
+				p.next()
+			}
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.next()
+		default:
+			return
+		}
+	}
+}
+
+// record parses the headers and moves of one game.
+func (p *parser) record() (Record, error) {
+	rec := Record{Size: engine.Standard}
+	headers := make(map[string]string)
+	for p.skip(); !p.eof() && p.peek() == '['; p.skip() {
+		line := p.line
+		tag, value, err := p.header()
+		if err != nil {
+			return Record{}, err
+		}
+		if _, dup := headers[tag]; dup {
+			return Record{}, fmt.Errorf("record: line %d: duplicate header %s", line, tag)
+		}
+		headers[tag] = value
+		if err := rec.set(tag, value); err != nil {
+			return Record{}, fmt.Errorf("record: line %d: %w", line, err)
+		}
+	}
+	if d, ok := headers["Date"]; ok {
+		t, err := parseDate(d, headers["Time"])
+		if err != nil {
+			return Record{}, fmt.Errorf("record: %w", err)
+		}
+		rec.Date = t
+	}
+
+	for {
+		p.skip()
+		if p.eof() {
+			return Record{}, p.errorf("moves end without a result")
+		}
+		switch p.peek() {
+		case '[':
+			return Record{}, p.errorf("header among the moves; is a result missing?")
+		case '{':
+			if len(rec.Moves) == 0 {
+				return Record{}, p.errorf("comment before the first move")
+			}
+			comment, err := p.comment()
+			if err != nil {
+				return Record{}, err
+			}
+			m := &rec.Moves[len(rec.Moves)-1]
+			if m.Score != nil || m.Comment != "" {
+				return Record{}, p.errorf("second comment on move %d", len(rec.Moves))
+			}
+			if err := m.setComment(comment); err != nil {
+				return Record{}, p.errorf("%v", err)
+			}
+			continue
+		}
+		word := p.word()
+		switch {
+		case word == XWins || word == OWins || word == Draw || word == Unfinished:
+			if h, ok := headers["Result"]; ok && h != word {
+				return Record{}, p.errorf("moves end with %s but the Result header is %s", word, h)
+			}
+			rec.Result = word
+			if err := rec.Check(); err != nil {
+				return Record{}, p.errorf("%v", strings.TrimPrefix(err.Error(), "record: "))
+			}
+			return rec, nil
+		case moveNumber(word):
+			continue
+		}
+		sq, err := ParseSquare(word, rec.Size)
+		if err != nil {
+			return Record{}, p.errorf("%v", strings.TrimPrefix(err.Error(), "record: "))
+		}
+		rec.Moves = append(rec.Moves, Move{Square: sq})
+	}
+}
+
+// header parses `[Tag "value"]`.
+func (p *parser) header() (tag, value string, err error) {
+	p.next()
+	start := p.pos
+	for !p.eof() && p.peek() != ' ' && p.peek() != '"' && p.peek() != ']' {
+		p.next()
+	}
+	tag = p.src[start:p.pos]
+	if !validTag(tag) {
+		return "", "", p.errorf("invalid header tag %q", tag)
+	}
+	p.skip()
+	if p.eof() || p.next() != '"' {
+		return "", "", p.errorf("header %s has no quoted value", tag)
+	}
+	var sb strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", "", p.errorf("header %s: unterminated value", tag)
+		}
+		c := p.next()
+		if c == '"' {
+			break
+		}
+		if c == '\\' && !p.eof() && (p.peek() == '"' || p.peek() == '\\') {
+			c = p.next()
+		}
+		sb.WriteByte(c)
+	}
+	p.skip()
+	if p.eof() || p.next() != ']' {
+		return "", "", p.errorf("header %s: missing ']'", tag)
+	}
+	return tag, sb.String(), nil
+}
+
+// comment parses `{text}`.
+func (p *parser) comment() (string, error) {
+	line := p.line
+	p.next()
+	start := p.pos
+	for !p.eof() && p.peek() != '}' {
+		p.next()
+	}
+	if p.eof() {
+		return "", fmt.Errorf("record: line %d: unterminated comment", line)
+	}
+	text := p.src[start:p.pos]
+	p.next()
+	return strings.TrimSpace(text), nil
+}
+
+// word returns the text up to the next space, comment or header.
+func (p *parser) word() string {
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\r\n{[;", rune(p.peek())) {
+		p.next()
+	}
+	return p.src[start:p.pos]
+}
+
+// moveNumber reports whether word numbers a move, as in "3." or "3...".
+func moveNumber(word string) bool {
+	digits := strings.TrimRight(word, ".")
+	if digits == word || digits == "" {
+		return false
+	}
+	_, err := strconv.Atoi(digits)
+	return err == nil && digits[0] != '+' && digits[0] != '-'
+}
+
+// set applies a header to rec.
+func (rec *Record) set(tag, value string) error {
+	switch tag {
+	case "GameId":
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("GameId %q is not a number", value)
+		}
+		rec.GameId = id
+	case "Date", "Time":
+		// Read together once every header is known.
+	case "X", "O":
+		if value == "?" {
+			value = ""
+		}
+		if tag == "X" {
+			rec.X = value
+		} else {
+			rec.O = value
+		}
+	case "Mark", "First":
+		m := engine.Mark(value)
+		if m != engine.X && m != engine.O {
+			return fmt.Errorf("%s %q must be X or O", tag, value)
+		}
+		if tag == "Mark" {
+			rec.Mark = m
+		} else {
+			rec.First = m
+		}
+	case "Size":
+		w, h, ok := strings.Cut(value, "x")
+		width, werr := strconv.Atoi(w)
+		height, herr := strconv.Atoi(h)
+		if !ok || werr != nil || herr != nil || width < 1 || height < 1 {
+			return fmt.Errorf("Size %q must be WIDTHxHEIGHT", value)
+		}
+		rec.Size.Width, rec.Size.Height = width, height
+	case "WinLength":
+		k, err := strconv.Atoi(value)
+		if err != nil || k < 1 {
+			return fmt.Errorf("WinLength %q must be a positive number", value)
+		}
+		rec.Size.K = k
+	case "Setup":
+		rec.Setup = parseSetup(value)
+	case "Result":
+		// Checked against the result ending the moves.
+	default:
+		if rec.Tags == nil {
+			rec.Tags = make(map[string]string)
+		}
+		rec.Tags[tag] = value
+	}
+	return nil
+}
+
+// setComment sets a move's comment, taking a leading "[%eval N]" as its
+// score.
+func (m *Move) setComment(text string) error {
+	if rest, ok := strings.CutPrefix(text, "[%eval "); ok {
+		num, rest, ok := strings.Cut(rest, "]")
+		score, err := strconv.Atoi(strings.TrimSpace(num))
+		if !ok || err != nil {
+			return fmt.Errorf("invalid score in comment %q", text)
+		}
+		m.Score = &score
+		text = strings.TrimSpace(rest)
+	}
+	m.Comment = text
+	return nil
+}
+
+// parseSetup reads a board written by formatSetup. Its size is checked
+// with the moves.
+func parseSetup(s string) []string {
+	var board []string
+	for _, c := range strings.ReplaceAll(s, "/", "") {
+		if c == '-' {
+			board = append(board, "")
+		} else {
+			board = append(board, string(c))
+		}
+	}
+	return board
+}
+
+// parseDate reads the Date and optional Time headers, in UTC.
+func parseDate(date, clock string) (time.Time, error) {
+	if clock == "" {
+		clock = "00:00:00"
+	}
+	t, err := time.Parse(dateLayout+" "+timeLayout, date+" "+clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid Date %q or Time %q", date, clock)
+	}
+	return t, nil
+}
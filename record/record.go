@@ -0,0 +1,142 @@
+// Package record reads and writes game records, transcripts of whole games
+// in a compact text format modelled on chess PGN:
+//
+//	[GameId "42"]
+//	[Date "2024.03.01"]
+//	[Time "12:00:00"]
+//	[X "TicTacToeBot"]
+//	[O "?"]
+//	[Mark "X"]
+//	[Size "3x3"]
+//	[WinLength "3"]
+//	[Result "1-0"]
+//
+//	1. b2 {[%eval 8] win in 5 plies} a1 2. c1 a3 3. a2 c3 4. c2 1-0
+//
+// Headers are followed by the moves, numbered in pairs and ending with the
+// result. Squares are written in algebraic notation, a column letter and
+// a row number with a1 the bottom-left square, or as the square's index
+// as used on the wire, 0 being the top-left square. Comments in braces
+// follow the move they are about and may start with an engine score.
+package record
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/engine"
+)
+
+// Results, as written in the Result header and after the moves.
+const (
+	XWins      = "1-0"
+	OWins      = "0-1"
+	Draw       = "1/2-1/2"
+	Unfinished = "*"
+)
+
+// Record is a game.
+type Record struct {
+	GameId int
+	// Date is when the game started, or zero if not known. It is written
+	// in UTC to the second.
+	Date time.Time
+	// X and O name the players, empty if not known.
+	X, O string
+	// Mark is the mark of the player that made the record, if known.
+	Mark engine.Mark
+	Size engine.Size
+	// Setup is the board the moves start from, nil for an empty board.
+	Setup []string
+	// First is the mark making the first move; X if empty.
+	First  engine.Mark
+	Result string
+	// Tags holds any other headers.
+	Tags  map[string]string
+	Moves []Move
+}
+
+// Move is a move and what was said about it.
+type Move struct {
+	Square engine.Move
+	// Score is the engine's score of the move for the player making it,
+	// as found by engine.MiniMax, if known.
+	Score   *int
+	Comment string
+}
+
+// MarkOf returns the mark making move i.
+func (r Record) MarkOf(i int) engine.Mark {
+	first := r.First
+	if first == engine.Empty {
+		first = engine.X
+	}
+	if i%2 == 1 {
+		return first.Opponent()
+	}
+	return first
+}
+
+// Board returns the position after the first n moves, checking that each
+// is legal.
+func (r Record) Board(n int) (engine.Board, error) {
+	if err := r.Size.Valid(); err != nil {
+		return engine.Board{}, fmt.Errorf("record: %w", err)
+	}
+	b := r.Size.NewBoard()
+	if r.Setup != nil {
+		var err error
+		if b, err = r.Size.ParseStrings(r.Setup); err != nil {
+			return engine.Board{}, fmt.Errorf("record: setup: %w", err)
+		}
+	}
+	for i, m := range r.Moves[:n] {
+		if over, _ := b.Winner(); over {
+			return engine.Board{}, fmt.Errorf("record: move %d, %s, is played after the game is over", i+1, r.Square(m.Square))
+		}
+		if m.Square < 0 || int(m.Square) >= b.Len() {
+			return engine.Board{}, fmt.Errorf("record: move %d, %d, is off the %s board", i+1, m.Square, r.Size)
+		}
+		if b.At(m.Square) != engine.Empty {
+			return engine.Board{}, fmt.Errorf("record: move %d, %s, is on an occupied square", i+1, r.Square(m.Square))
+		}
+		b = b.Play(m.Square, r.MarkOf(i))
+	}
+	return b, nil
+}
+
+// Final returns the position after every move.
+func (r Record) Final() (engine.Board, error) {
+	return r.Board(len(r.Moves))
+}
+
+// Check reports whether the moves are legal and the result agrees with
+// the final position. Games may end before the board does, by forfeit or
+// abandonment, so only a finished board constrains the result.
+func (r Record) Check() error {
+	b, err := r.Final()
+	if err != nil {
+		return err
+	}
+	switch r.Result {
+	case XWins, OWins, Draw, Unfinished:
+	default:
+		return fmt.Errorf("record: unknown result %q", r.Result)
+	}
+	if over, winner := b.Winner(); over && r.Result != Unfinished && r.Result != ResultOf(winner) {
+		return fmt.Errorf("record: result %s does not match the final position", r.Result)
+	}
+	return nil
+}
+
+// ResultOf returns the result of a game won by winner, or Draw if winner
+// is empty.
+func ResultOf(winner engine.Mark) string {
+	switch winner {
+	case engine.X:
+		return XWins
+	case engine.O:
+		return OWins
+	}
+	return Draw
+}
@@ -0,0 +1,287 @@
+package record
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/engine"
+	"github.com/purnet/TicTacToeBot/session"
+)
+
+func score(n int) *int {
+	return &n
+}
+
+// sample is a game X wins down the left column.
+func sample() Record {
+	return Record{
+		GameId: 42,
+		Date:   time.Date(2024, 3, 1, 12, 30, 5, 0, time.UTC),
+		X:      "TicTacToeBot",
+		Mark:   engine.X,
+		Size:   engine.Standard,
+		Result: XWins,
+		Tags:   map[string]string{"Event": `Merknera "ladder"`},
+		Moves: []Move{
+			{Square: 4, Score: score(0), Comment: "draw"},
+			{Square: 1},
+			{Square: 0, Score: score(8), Comment: "win in 5 plies"},
+			{Square: 8},
+			{Square: 6, Score: score(10)},
+			{Square: 2},
+			{Square: 3, Comment: "forced"},
+		},
+	}
+}
+
+const sampleText = `[GameId "42"]
+[Date "2024.03.01"]
+[Time "12:30:05"]
+[X "TicTacToeBot"]
+[O "?"]
+[Mark "X"]
+[Size "3x3"]
+[WinLength "3"]
+[Result "1-0"]
+[Event "Merknera \"ladder\""]
+
+1. b2 {[%eval 0] draw} b3 2. a3 {[%eval 8] win in 5 plies} c1 3. a1
+{[%eval 10]} c3 4. a2 {forced} 1-0
+`
+
+func TestWrite(t *testing.T) {
+	if got := sample().String(); got != sampleText {
+		t.Errorf("String() =\n%s\nexpected\n%s", got, sampleText)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	big := Record{
+		GameId: 7,
+		Size:   engine.Size{Width: 30, Height: 2, K: 3},
+		Setup:  make([]string, 60),
+		First:  engine.O,
+		Result: Unfinished,
+		Moves:  []Move{{Square: 59}, {Square: 0, Score: score(-3)}},
+	}
+	big.Setup[31] = "X"
+	big.Setup[32] = "O"
+	odd := Record{
+		X:      `C:\bots\"ttt"`,
+		O:      "Ångström ✓",
+		Size:   engine.Standard,
+		Result: Unfinished,
+		Tags:   map[string]string{"Note": `\`},
+		Moves: []Move{
+			{Square: 4, Score: score(1), Comment: "[%eval 2] was the old score"},
+			{Square: 0, Comment: "spaced  out   inside"},
+		},
+	}
+	records := []Record{sample(), big, odd, {Size: engine.Standard, Result: Draw}}
+
+	for _, rec := range records {
+		for _, n := range []Notation{Algebraic, Index} {
+			var sb strings.Builder
+			if err := rec.Write(&sb, n); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Parse(sb.String())
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", sb.String(), err)
+			}
+			if rec.First == engine.Empty {
+				rec.First = engine.X
+			}
+			if got.First == engine.Empty {
+				got.First = engine.X
+			}
+			if !reflect.DeepEqual(got, rec) {
+				t.Errorf("notation %d: round trip of\n%s\ngave %+v, expected %+v", n, sb.String(), got, rec)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	text := `; games from the ladder
+[Size "4x4"] [WinLength "3"]
+[First "O"]
+[Setup "----/-X--/----/----"]
+
+1. 0 a1 {[%eval -2]} ; the rest is lost
+2. d4 1/2-1/2
+
+[GameId "9"] [Result "0-1"]
+1. a1 b2 2. c1 b1 3. a3 b3 0-1
+`
+	recs, err := ParseAll(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("ParseAll() = %d games, expected 2", len(recs))
+	}
+	first := recs[0]
+	if first.Size != (engine.Size{Width: 4, Height: 4, K: 3}) || first.Result != Draw || first.MarkOf(0) != engine.O {
+		t.Errorf("first game = %+v", first)
+	}
+	if got := []engine.Move{first.Moves[0].Square, first.Moves[1].Square, first.Moves[2].Square}; !reflect.DeepEqual(got, []engine.Move{0, 12, 3}) {
+		t.Errorf("first game moves = %v", got)
+	}
+	if s := first.Moves[1].Score; s == nil || *s != -2 || first.Moves[1].Comment != "" {
+		t.Errorf("first game comment = %+v", first.Moves[1])
+	}
+	b, _ := first.Final()
+	if b.At(5) != engine.X || b.At(12) != engine.X || b.At(0) != engine.O {
+		t.Errorf("first game final board =\n%s", b)
+	}
+	second := recs[1]
+	if second.GameId != 9 || second.Size != engine.Standard || len(second.Moves) != 6 || second.Result != OWins {
+		t.Errorf("second game = %+v", second)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`1. b2 a1`, "line 1: moves end without a result"},
+		{`[Result "1-0"] 1. b2 0-1`, "but the Result header is 1-0"},
+		{"[Size \"3x3\"]\n1. d4 *", `line 2: "d4" is not a square on a 3x3 board`},
+		{`1. b2 b2 *`, "move 2, b2, is on an occupied square"},
+		{`1. a1 b1 2. a2 b2 3. a3 b3 *`, "move 6, b3, is played after the game is over"},
+		{`1. a1 b1 2. a2 b2 3. a3 0-1`, "result 0-1 does not match"},
+		{`{hello} 1. b2 *`, "comment before the first move"},
+		{`1. b2 {[%eval x]} *`, "invalid score"},
+		{`1. b2 {ok} {again} *`, "second comment"},
+		{`1. b2 {open *`, "unterminated comment"},
+		{`[Size "3"] *`, `Size "3" must be WIDTHxHEIGHT`},
+		{`[First "Z"] *`, `First "Z" must be X or O`},
+		{`[GameId "1"] [GameId "2"] *`, "duplicate header GameId"},
+		{"[X \"unterminated]\n*", "unterminated value"},
+		{`[Date "yesterday"] *`, `invalid Date "yesterday"`},
+		{`[Setup "XX-/---"] *`, "setup"},
+		{`1. b2 [X "late"] *`, "header among the moves"},
+		{`* *`, "found 2 games, expected 1"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.text)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error = %v, expected %q", tt.text, err, tt.want)
+		}
+	}
+}
+
+func TestSquares(t *testing.T) {
+	size := engine.Size{Width: 4, Height: 3, K: 3}
+	for _, tt := range []struct {
+		move engine.Move
+		alg  string
+	}{{0, "a3"}, {3, "d3"}, {4, "a2"}, {11, "d1"}} {
+		if got := square(size, tt.move, Algebraic); got != tt.alg {
+			t.Errorf("square(%d) = %q, expected %q", tt.move, got, tt.alg)
+		}
+		for _, s := range []string{tt.alg, square(size, tt.move, Index)} {
+			if got, err := ParseSquare(s, size); err != nil || got != tt.move {
+				t.Errorf("ParseSquare(%q) = %d, %v, expected %d", s, got, err, tt.move)
+			}
+		}
+	}
+	for _, s := range []string{"e1", "a0", "a4", "a+1", "12", "-1", "+3", "A1"} {
+		if _, err := ParseSquare(s, size); err == nil {
+			t.Errorf("ParseSquare(%q) succeeded", s)
+		}
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	rec := sample()
+	rec.Moves[1].Comment = "a } b"
+	if err := rec.Write(&strings.Builder{}, Algebraic); err == nil {
+		t.Error("Write() of a comment with '}' succeeded")
+	}
+	rec = sample()
+	rec.Tags = map[string]string{"Result": "0-1"}
+	if err := rec.Write(&strings.Builder{}, Algebraic); err == nil {
+		t.Error("Write() of a tag shadowing a header succeeded")
+	}
+	rec = sample()
+	rec.Result = OWins
+	if err := rec.Write(&strings.Builder{}, Algebraic); err == nil {
+		t.Error("Write() of a wrong result succeeded")
+	}
+
+	// Values that would not read back as written.
+	tests := []struct {
+		name   string
+		change func(r *Record)
+	}{
+		{"player with a newline", func(r *Record) { r.X = "line\n[Result \"0-1\"]" }},
+		{"tag with a tab", func(r *Record) { r.Tags = map[string]string{"Event": "a\tb"} }},
+		{"comment with a newline", func(r *Record) { r.Moves[3].Comment = "a\nb" }},
+		{"comment with DEL", func(r *Record) { r.Moves[3].Comment = "a\x7fb" }},
+		{"comment with a leading space", func(r *Record) { r.Moves[3].Comment = " forced" }},
+		{"comment with a trailing space", func(r *Record) { r.Moves[0].Comment = "draw " }},
+		{"blank comment", func(r *Record) { r.Moves[3].Comment = " " }},
+		{"unscored comment like a score", func(r *Record) { r.Moves[3].Comment = "[%eval 3] maybe" }},
+	}
+	for _, tt := range tests {
+		rec := sample()
+		tt.change(&rec)
+		if err := rec.Write(&strings.Builder{}, Algebraic); err == nil {
+			t.Errorf("Write() of a %s succeeded", tt.name)
+		}
+	}
+}
+
+// board parses a 3x3 board written as "X-O/-X-/--O".
+func board(s string) []string {
+	return parseSetup(s)
+}
+
+func TestFromSession(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	// We play O: X opened in the centre before our first turn and won
+	// after our third.
+	s := session.Session{
+		GameId: 5, Mark: "O", Width: 3, Height: 3, WinLength: 3, Started: start,
+		Turns: []session.Turn{
+			{Board: board("---/-X-/---"), Opponent: -1, Move: 0, Score: score(0), Outcome: "draw"},
+			{Board: board("O--/-X-/--X"), Opponent: 8, Move: 2},
+			{Board: board("O-O/-X-/X-X"), Opponent: 6, Move: 3},
+		},
+		Result:     session.Loss,
+		FinalBoard: board("O-O/OX-/XXX"),
+	}
+	rec, err := FromSession(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1. b2 a3 {[%eval 0] draw} 2. c1 c3 3. a1 a2 4. b1 1-0\n"
+	if got := rec.String(); !strings.HasSuffix(got, want) || !strings.Contains(got, `[Mark "O"]`) || !strings.Contains(got, `[Date "2024.03.01"]`) {
+		t.Errorf("FromSession() =\n%s\nexpected moves %s", got, want)
+	}
+
+	// Without a final board the opponent's last move is missing.
+	s.FinalBoard = nil
+	s.Result = session.Expired
+	if rec, err := FromSession(s); err != nil || len(rec.Moves) != 6 || rec.Result != Unfinished || rec.Tags["Termination"] != "abandoned" {
+		t.Errorf("FromSession() of an expired game = %+v, %v", rec, err)
+	}
+
+	// Joining late gives a setup.
+	late := session.Session{GameId: 6, Mark: "X", Width: 3, Height: 3, WinLength: 3,
+		Turns: []session.Turn{{Board: board("XO-/-O-/---"), Opponent: -1, Move: 7}}}
+	if rec, err := FromSession(late); err != nil || rec.Setup == nil || rec.MarkOf(0) != engine.X || len(rec.Moves) != 1 {
+		t.Errorf("FromSession() of a late join = %+v, %v", rec, err)
+	}
+
+	// A turn with an unknown opponent move cannot be recorded.
+	s.Turns[1].Opponent = -1
+	if _, err := FromSession(s); err == nil || !strings.Contains(err.Error(), "before turn 2") {
+		t.Errorf("FromSession() with a gap: %v", err)
+	}
+}
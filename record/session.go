@@ -0,0 +1,111 @@
+package record
+
+import (
+	"fmt"
+
+	"github.com/purnet/TicTacToeBot/engine"
+	"github.com/purnet/TicTacToeBot/session"
+)
+
+// FromSession returns the record of a game session, live or closed, made
+// by the player of the session's mark. Players are left unnamed. Our
+// moves carry the engine's score and outcome where the session has them.
+//
+// The moves must be known: a first board holding more than one of the
+// opponent's marks becomes the record's Setup, but an opponent move that
+// could not be told between turns is an error.
+func FromSession(s session.Session) (Record, error) {
+	mark := engine.Mark(s.Mark)
+	if mark != engine.X && mark != engine.O {
+		return Record{}, fmt.Errorf("record: game %d: session has no mark", s.GameId)
+	}
+	rec := Record{
+		GameId: s.GameId,
+		Date:   s.Started,
+		Mark:   mark,
+		Size:   engine.Size{Width: s.Width, Height: s.Height, K: s.WinLength},
+		First:  mark,
+		Result: Unfinished,
+	}
+	if s.Width == 0 {
+		// Sessions of games completed before any move have no size.
+		rec.Size = engine.Standard
+	}
+	switch s.Result {
+	case session.Win:
+		rec.Result = ResultOf(mark)
+	case session.Loss:
+		rec.Result = ResultOf(mark.Opponent())
+	case session.Draw:
+		rec.Result = Draw
+	case session.Expired:
+		rec.Tags = map[string]string{"Termination": "abandoned"}
+	}
+	if len(s.Turns) == 0 {
+		return rec, rec.Check()
+	}
+
+	// We may join after the opponent's first move, or later still.
+	first := s.Turns[0].Board
+	var opponent []int
+	for i, m := range first {
+		if m == string(mark.Opponent()) {
+			opponent = append(opponent, i)
+		}
+	}
+	switch n := countMarks(first); {
+	case n == 1 && len(opponent) == 1:
+		rec.First = mark.Opponent()
+		rec.Moves = append(rec.Moves, Move{Square: engine.Move(opponent[0])})
+	case n > 0:
+		rec.Setup = append([]string(nil), first...)
+	}
+
+	for i, t := range s.Turns {
+		if i > 0 {
+			if t.Opponent < 0 || len(t.Anomalies) > 0 {
+				return Record{}, fmt.Errorf("record: game %d: the opponent's move before turn %d is not known", s.GameId, i+1)
+			}
+			rec.Moves = append(rec.Moves, Move{Square: engine.Move(t.Opponent)})
+		}
+		if t.Move < 0 {
+			if i < len(s.Turns)-1 {
+				return Record{}, fmt.Errorf("record: game %d: turn %d has no move", s.GameId, i+1)
+			}
+			break
+		}
+		rec.Moves = append(rec.Moves, Move{Square: engine.Move(t.Move), Score: t.Score, Comment: t.Outcome})
+	}
+
+	// The opponent's last move shows only on the final board.
+	b, err := rec.Final()
+	if err != nil {
+		return Record{}, fmt.Errorf("record: game %d: %w", s.GameId, err)
+	}
+	if len(s.FinalBoard) == b.Len() {
+		var last []int
+		for i, m := range s.FinalBoard {
+			if m == string(mark.Opponent()) && b.At(engine.Move(i)) == engine.Empty {
+				last = append(last, i)
+			}
+		}
+		if len(last) > 1 {
+			return Record{}, fmt.Errorf("record: game %d: the final board has %d new moves", s.GameId, len(last))
+		}
+		if len(last) == 1 && rec.MarkOf(len(rec.Moves)) == mark.Opponent() {
+			rec.Moves = append(rec.Moves, Move{Square: engine.Move(last[0])})
+		}
+	}
+	return rec, rec.Check()
+}
+
+// countMarks counts the occupied squares of a board.
+func countMarks(board []string) int {
+	n := 0
+	for _, m := range board {
+		if m != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,184 @@
+package record
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/purnet/TicTacToeBot/engine"
+)
+
+// lineWidth is where the move text is wrapped.
+const lineWidth = 79
+
+// Write writes r to w, with squares in the given notation. The record is
+// checked first. Header values and comments may not contain control
+// characters, comments may not contain '}' or begin or end with a space,
+// and a comment without a score may not begin with "[%eval", as each
+// would read back differently.
+func (r Record) Write(w io.Writer, n Notation) error {
+	if err := r.Check(); err != nil {
+		return err
+	}
+	for tag, value := range map[string]string{"X": r.X, "O": r.O} {
+		if !printable(value) {
+			return fmt.Errorf("record: %s contains a control character", tag)
+		}
+	}
+	var sb strings.Builder
+	header := func(tag, value string) {
+		fmt.Fprintf(&sb, "[%s %s]\n", tag, quote(value))
+	}
+	header("GameId", strconv.Itoa(r.GameId))
+	if !r.Date.IsZero() {
+		utc := r.Date.UTC()
+		header("Date", utc.Format(dateLayout))
+		header("Time", utc.Format(timeLayout))
+	}
+	header("X", player(r.X))
+	header("O", player(r.O))
+	if r.Mark != engine.Empty {
+		header("Mark", string(r.Mark))
+	}
+	header("Size", fmt.Sprintf("%dx%d", r.Size.Width, r.Size.Height))
+	header("WinLength", strconv.Itoa(r.Size.K))
+	if r.Setup != nil {
+		header("Setup", formatSetup(r.Setup, r.Size.Width))
+	}
+	if r.MarkOf(0) != engine.X {
+		header("First", string(r.MarkOf(0)))
+	}
+	header("Result", r.Result)
+	tags := make([]string, 0, len(r.Tags))
+	for tag := range r.Tags {
+		if _, ok := known[tag]; ok || !validTag(tag) {
+			return fmt.Errorf("record: invalid tag %q", tag)
+		}
+		if !printable(r.Tags[tag]) {
+			return fmt.Errorf("record: tag %s contains a control character", tag)
+		}
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		header(tag, r.Tags[tag])
+	}
+	sb.WriteString("\n")
+
+	col := 0
+	word := func(s string) {
+		if col > 0 && col+1+len(s) > lineWidth {
+			sb.WriteString("\n")
+			col = 0
+		} else if col > 0 {
+			sb.WriteString(" ")
+			col++
+		}
+		sb.WriteString(s)
+		col += len(s)
+	}
+	for i, m := range r.Moves {
+		if i%2 == 0 {
+			word(strconv.Itoa(i/2+1) + ".")
+		}
+		word(square(r.Size, m.Square, n))
+		if m.Score == nil && m.Comment == "" {
+			continue
+		}
+		if strings.ContainsRune(m.Comment, '}') {
+			return fmt.Errorf("record: move %d: comment contains '}'", i+1)
+		}
+		if strings.TrimSpace(m.Comment) != m.Comment {
+			return fmt.Errorf("record: move %d: comment begins or ends with a space", i+1)
+		}
+		if !printable(m.Comment) {
+			return fmt.Errorf("record: move %d: comment contains a control character", i+1)
+		}
+		if m.Score == nil && strings.HasPrefix(m.Comment, "[%eval") {
+			return fmt.Errorf("record: move %d: comment without a score begins with [%%eval", i+1)
+		}
+		var parts []string
+		if m.Score != nil {
+			parts = append(parts, fmt.Sprintf("[%%eval %d]", *m.Score))
+		}
+		if m.Comment != "" {
+			parts = append(parts, m.Comment)
+		}
+		word("{" + strings.Join(parts, " ") + "}")
+	}
+	word(r.Result)
+	sb.WriteString("\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// String returns r in algebraic notation, or an error message if r cannot
+// be written.
+func (r Record) String() string {
+	var sb strings.Builder
+	if err := r.Write(&sb, Algebraic); err != nil {
+		return err.Error()
+	}
+	return sb.String()
+}
+
+const (
+	dateLayout = "2006.01.02"
+	timeLayout = "15:04:05"
+)
+
+// known lists the headers with Record fields of their own.
+var known = map[string]struct{}{
+	"GameId": {}, "Date": {}, "Time": {}, "X": {}, "O": {}, "Mark": {},
+	"Size": {}, "WinLength": {}, "Setup": {}, "First": {}, "Result": {},
+}
+
+func validTag(tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for _, c := range tag {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// printable reports whether s has no control characters.
+func printable(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) < 0
+}
+
+// player returns the name written for a player, "?" if not known.
+func player(name string) string {
+	if name == "" {
+		return "?"
+	}
+	return name
+}
+
+// quote quotes a header value, escaping backslashes and quotes.
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// formatSetup writes a board one row at a time, rows separated by '/'
+// and empty squares as '-'.
+func formatSetup(board []string, width int) string {
+	var sb strings.Builder
+	for i, m := range board {
+		if i > 0 && width > 0 && i%width == 0 {
+			sb.WriteByte('/')
+		}
+		if m == "" {
+			m = "-"
+		}
+		sb.WriteString(m)
+	}
+	return sb.String()
+}
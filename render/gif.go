@@ -0,0 +1,63 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+
+	"github.com/purnet/TicTacToeBot/record"
+)
+
+// GIF writes an animation of rec to w: a frame for the starting position
+// and one after each move, highlighting the move just played. Scores are
+// not drawn; the winning line, if asked for, shows on the last frame.
+func GIF(w io.Writer, rec record.Record, opts Options) error {
+	delay := opts.Delay
+	if delay <= 0 {
+		delay = DefaultDelay
+	}
+	hundredths := max(1, int(delay.Milliseconds()/10))
+
+	anim := &gif.GIF{}
+	for i := 0; i <= len(rec.Moves); i++ {
+		b, err := rec.Board(i)
+		if err != nil {
+			return err
+		}
+		frame := opts
+		frame.Scores = nil
+		frame.LastMove = nil
+		if i > 0 {
+			last := rec.Moves[i-1].Square
+			frame.LastMove = &last
+		}
+		anim.Image = append(anim.Image, paletted(Image(b, frame)))
+		anim.Delay = append(anim.Delay, hundredths)
+	}
+	anim.Delay[len(anim.Delay)-1] *= 4
+	return gif.EncodeAll(w, anim)
+}
+
+// framePalette holds the drawing's colours and their blends with the
+// background, which antialiased edges are made of.
+var framePalette = func() color.Palette {
+	p := color.Palette{background}
+	win := winColour
+	win.A = 255
+	win = mix(background, win, float64(winColour.A)/255)
+	for _, c := range []color.RGBA{gridColour, xColour, oColour, lastColour, win} {
+		for i := 1; i < 16; i++ {
+			p = append(p, mix(background, c, float64(i)/15))
+		}
+	}
+	return p
+}()
+
+// paletted converts img to framePalette.
+func paletted(img image.Image) *image.Paletted {
+	p := image.NewPaletted(img.Bounds(), framePalette)
+	draw.Draw(p, p.Rect, img, img.Bounds().Min, draw.Src)
+	return p
+}
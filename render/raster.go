@@ -0,0 +1,129 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/purnet/TicTacToeBot/engine"
+)
+
+// Image draws b. Scores are shown by colour alone, as the standard
+// library has no fonts.
+func Image(b engine.Board, opts Options) *image.RGBA {
+	l := newLayout(b.Size(), opts)
+	width, height := l.dimensions()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fill(img, 0, 0, float64(width), float64(height), background)
+
+	for m, c := range heat(opts.Scores, b.Len()) {
+		x, y := l.corner(m)
+		fill(img, x, y, x+l.cell, y+l.cell, c)
+	}
+	if last := opts.LastMove; last != nil && int(*last) >= 0 && int(*last) < b.Len() {
+		x, y := l.corner(*last)
+		inset := l.cell * gridWidth
+		fill(img, x+inset, y+inset, x+l.cell-inset, y+3*inset, lastColour)
+		fill(img, x+inset, y+l.cell-3*inset, x+l.cell-inset, y+l.cell-inset, lastColour)
+		fill(img, x+inset, y+inset, x+3*inset, y+l.cell-inset, lastColour)
+		fill(img, x+l.cell-3*inset, y+inset, x+l.cell-inset, y+l.cell-inset, lastColour)
+	}
+
+	size := b.Size()
+	grid := l.cell * gridWidth
+	for col := 1; col < size.Width; col++ {
+		x := l.pad + float64(col)*l.cell
+		line(img, x, l.pad, x, l.pad+float64(size.Height)*l.cell, grid, gridColour)
+	}
+	for row := 1; row < size.Height; row++ {
+		y := l.pad + float64(row)*l.cell
+		line(img, l.pad, y, l.pad+float64(size.Width)*l.cell, y, grid, gridColour)
+	}
+
+	stroke := l.cell * markWidth
+	for i := 0; i < b.Len(); i++ {
+		m := engine.Move(i)
+		x, y := l.corner(m)
+		lo, hi := l.cell*markInset, l.cell*(1-markInset)
+		switch b.At(m) {
+		case engine.X:
+			line(img, x+lo, y+lo, x+hi, y+hi, stroke, xColour)
+			line(img, x+hi, y+lo, x+lo, y+hi, stroke, xColour)
+		case engine.O:
+			cx, cy := l.centre(m)
+			ring(img, cx, cy, (hi-lo)/2, stroke, oColour)
+		}
+	}
+
+	if x0, y0, x1, y1, ok := winningEnds(b, l, opts); ok {
+		line(img, x0, y0, x1, y1, l.cell*winWidth, winColour)
+	}
+	return img
+}
+
+// PNG writes b to w as a PNG image.
+func PNG(w io.Writer, b engine.Board, opts Options) error {
+	return png.Encode(w, Image(b, opts))
+}
+
+// paint blends c into every pixel of img within the box x0..x1, y0..y1
+// by the coverage cover returns for the pixel's centre, from 0 to 1.
+func paint(img *image.RGBA, x0, y0, x1, y1 float64, c color.RGBA, cover func(x, y float64) float64) {
+	r := image.Rect(int(math.Floor(x0)), int(math.Floor(y0)), int(math.Ceil(x1)), int(math.Ceil(y1))).Intersect(img.Bounds())
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			a := cover(float64(px)+0.5, float64(py)+0.5) * float64(c.A) / 255
+			if a <= 0 {
+				continue
+			}
+			a = math.Min(a, 1)
+			i := img.PixOffset(px, py)
+			p := img.Pix[i : i+4 : i+4]
+			p[0] = blend(p[0], c.R, a)
+			p[1] = blend(p[1], c.G, a)
+			p[2] = blend(p[2], c.B, a)
+			p[3] = 255
+		}
+	}
+}
+
+func blend(dst, src uint8, a float64) uint8 {
+	return uint8(float64(dst)*(1-a) + float64(src)*a + 0.5)
+}
+
+// edge turns the distance of a pixel centre inside a shape's edge into
+// coverage, smoothing the edge over one pixel.
+func edge(inside float64) float64 {
+	return math.Max(0, math.Min(1, inside+0.5))
+}
+
+// fill paints the box x0..x1, y0..y1.
+func fill(img *image.RGBA, x0, y0, x1, y1 float64, c color.RGBA) {
+	paint(img, x0, y0, x1, y1, c, func(x, y float64) float64 {
+		return edge(math.Min(math.Min(x-x0, x1-x), math.Min(y-y0, y1-y)))
+	})
+}
+
+// line paints a segment with round ends.
+func line(img *image.RGBA, x0, y0, x1, y1, width float64, c color.RGBA) {
+	h := width / 2
+	dx, dy := x1-x0, y1-y0
+	length2 := dx*dx + dy*dy
+	paint(img, math.Min(x0, x1)-h-1, math.Min(y0, y1)-h-1, math.Max(x0, x1)+h+1, math.Max(y0, y1)+h+1, c, func(x, y float64) float64 {
+		t := 0.0
+		if length2 > 0 {
+			t = math.Max(0, math.Min(1, ((x-x0)*dx+(y-y0)*dy)/length2))
+		}
+		return edge(h - math.Hypot(x-(x0+t*dx), y-(y0+t*dy)))
+	})
+}
+
+// ring paints a circle's outline.
+func ring(img *image.RGBA, cx, cy, r, width float64, c color.RGBA) {
+	h := width / 2
+	paint(img, cx-r-h-1, cy-r-h-1, cx+r+h+1, cy+r+h+1, c, func(x, y float64) float64 {
+		return edge(h - math.Abs(math.Hypot(x-cx, y-cy)-r))
+	})
+}
@@ -0,0 +1,139 @@
+// Package render draws boards as SVG, PNG and animated GIF images for
+// dashboards and bug reports, using only the standard library.
+//
+// A board may be drawn with its last move highlighted, a line through
+// the winning squares and the engine's scores as a heatmap, green for the
+// best squares and red for the worst.
+package render
+
+import (
+	"image/color"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/engine"
+)
+
+// Defaults for zero Options fields.
+const (
+	DefaultCellSize = 64
+	DefaultDelay    = 800 * time.Millisecond
+)
+
+// Options chooses what is drawn. The zero value draws the bare board.
+type Options struct {
+	// CellSize is the width of a square in pixels.
+	CellSize int
+	// LastMove, if set, is highlighted.
+	LastMove *engine.Move
+	// WinningLine draws a line through the winning squares, if any.
+	WinningLine bool
+	// Scores colours squares from red, for the lowest score, to green,
+	// for the highest, scores being engine.MiniMax scores as found in an
+	// engine.Analysis. The SVG also writes them out.
+	Scores map[engine.Move]int
+	// Delay is how long each frame of a GIF shows. The last frame shows
+	// four times as long.
+	Delay time.Duration
+}
+
+// Colours of the drawing.
+var (
+	background = color.RGBA{255, 255, 255, 255}
+	gridColour = color.RGBA{60, 60, 60, 255}
+	xColour    = color.RGBA{33, 102, 172, 255}
+	oColour    = color.RGBA{203, 24, 29, 255}
+	lastColour = color.RGBA{255, 230, 120, 255}
+	winColour  = color.RGBA{30, 30, 30, 190}
+	textColour = color.RGBA{50, 50, 50, 255}
+	neutral    = color.RGBA{255, 255, 191, 255}
+	best       = color.RGBA{26, 152, 80, 255}
+	worst      = color.RGBA{215, 48, 39, 255}
+)
+
+// layout places a board's squares in an image.
+type layout struct {
+	size engine.Size
+	cell float64
+	pad  float64
+}
+
+func newLayout(size engine.Size, opts Options) layout {
+	cell := opts.CellSize
+	if cell <= 0 {
+		cell = DefaultCellSize
+	}
+	return layout{size: size, cell: float64(cell), pad: float64(cell) / 8}
+}
+
+// dimensions returns the width and height of the image.
+func (l layout) dimensions() (w, h int) {
+	return int(2*l.pad + float64(l.size.Width)*l.cell), int(2*l.pad + float64(l.size.Height)*l.cell)
+}
+
+// corner returns the top-left corner of square m.
+func (l layout) corner(m engine.Move) (x, y float64) {
+	row, col := int(m)/l.size.Width, int(m)%l.size.Width
+	return l.pad + float64(col)*l.cell, l.pad + float64(row)*l.cell
+}
+
+// centre returns the centre of square m.
+func (l layout) centre(m engine.Move) (x, y float64) {
+	x, y = l.corner(m)
+	return x + l.cell/2, y + l.cell/2
+}
+
+// Stroke widths, as fractions of a square.
+const (
+	gridWidth = 1.0 / 24
+	markWidth = 1.0 / 10
+	winWidth  = 1.0 / 8
+	markInset = 0.22
+)
+
+// winningEnds returns the centres of the first and last winning squares,
+// if opts asks for the line and b has one.
+func winningEnds(b engine.Board, l layout, opts Options) (x0, y0, x1, y1 float64, ok bool) {
+	if !opts.WinningLine {
+		return 0, 0, 0, 0, false
+	}
+	line := b.WinningLine()
+	if line == nil {
+		return 0, 0, 0, 0, false
+	}
+	x0, y0 = l.centre(line[0])
+	x1, y1 = l.centre(line[len(line)-1])
+	return x0, y0, x1, y1, true
+}
+
+// heat returns the heatmap colour of each scored square of a board with
+// the given number of squares.
+func heat(scores map[engine.Move]int, cells int) map[engine.Move]color.RGBA {
+	if len(scores) == 0 {
+		return nil
+	}
+	lo, hi := 0, 0
+	for _, s := range scores {
+		lo, hi = min(lo, s), max(hi, s)
+	}
+	colours := make(map[engine.Move]color.RGBA, len(scores))
+	for m, s := range scores {
+		if m < 0 || int(m) >= cells {
+			continue
+		}
+		switch {
+		case s > 0:
+			colours[m] = mix(neutral, best, float64(s)/float64(hi))
+		case s < 0:
+			colours[m] = mix(neutral, worst, float64(s)/float64(lo))
+		default:
+			colours[m] = neutral
+		}
+	}
+	return colours
+}
+
+// mix returns the colour t of the way from a to b.
+func mix(a, b color.RGBA, t float64) color.RGBA {
+	lerp := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*t + 0.5) }
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), lerp(a.A, b.A)}
+}
@@ -0,0 +1,160 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/engine"
+	"github.com/purnet/TicTacToeBot/record"
+)
+
+// won is X winning down the diagonal, O to the right of the centre.
+var won = engine.FromStrings([]string{"X", "O", "", "", "X", "O", "", "", "X"})
+
+func move(m engine.Move) *engine.Move {
+	return &m
+}
+
+func TestSVG(t *testing.T) {
+	var buf bytes.Buffer
+	opts := Options{LastMove: move(8), WinningLine: true, Scores: map[engine.Move]int{2: -3, 6: 5, 7: 0, 40: 1}}
+	if err := SVG(&buf, won, opts); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	count := map[string]int{}
+	dec := xml.NewDecoder(strings.NewReader(out))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("SVG is not well formed: %v\n%s", err, out)
+		}
+		if el, ok := tok.(xml.StartElement); ok {
+			count[el.Name.Local]++
+		}
+	}
+	// Background, three heatmap squares and the last move.
+	if count["rect"] != 5 || count["path"] != 3 || count["circle"] != 2 || count["text"] != 3 || count["line"] != 5 {
+		t.Errorf("SVG elements = %v\n%s", count, out)
+	}
+	for _, want := range []string{`width="208" height="208"`, ">-3</text>", ">5</text>", hex(best), hex(worst)} {
+		if !strings.Contains(out, want) {
+			t.Errorf("SVG lacks %s\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	SVG(&buf, won, Options{CellSize: 32})
+	if out := buf.String(); strings.Contains(out, "<text") || !strings.Contains(out, `width="104"`) || strings.Count(out, "<line") != 4 {
+		t.Errorf("bare SVG =\n%s", out)
+	}
+}
+
+// near reports whether c is within a few levels of want in every channel.
+func near(c color.Color, want color.RGBA) bool {
+	r, g, b, _ := c.RGBA()
+	d := func(x uint32, y uint8) bool { return int(x>>8)-int(y) <= 8 && int(y)-int(x>>8) <= 8 }
+	return d(r, want.R) && d(g, want.G) && d(b, want.B)
+}
+
+func TestImage(t *testing.T) {
+	opts := Options{LastMove: move(5), Scores: map[engine.Move]int{2: -3, 6: 5, 3: 0}}
+	img := Image(won, opts)
+	l := newLayout(won.Size(), opts)
+	if w, h := l.dimensions(); img.Bounds().Dx() != w || img.Bounds().Dy() != h || w != 208 {
+		t.Fatalf("image bounds = %v", img.Bounds())
+	}
+	at := func(m engine.Move, fx, fy float64) color.Color {
+		x, y := l.corner(m)
+		return img.At(int(x+fx*l.cell), int(y+fy*l.cell))
+	}
+	tests := []struct {
+		name string
+		got  color.Color
+		want color.RGBA
+	}{
+		{"X at its centre", at(0, 0.5, 0.5), xColour},
+		{"O on its ring", at(1, 0.5, markInset), oColour},
+		{"O's hole", at(1, 0.5, 0.5), background},
+		{"empty square", at(7, 0.5, 0.5), background},
+		{"worst square", at(2, 0.5, 0.5), worst},
+		{"best square", at(6, 0.5, 0.5), best},
+		{"neutral square", at(3, 0.5, 0.5), neutral},
+		{"last move frame", at(5, 0.5, 0.06), lastColour},
+		{"grid", at(0, 1, 0.5), gridColour},
+		{"margin", img.At(1, 1), background},
+	}
+	for _, tt := range tests {
+		if !near(tt.got, tt.want) {
+			t.Errorf("%s = %v, expected %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// The winning line crosses the corners of the squares it joins.
+	line := Image(won, Options{WinningLine: true})
+	x, y := l.corner(4)
+	x, y = x+l.cell/10, y+l.cell/10
+	if c := line.At(int(x), int(y)); near(c, background) {
+		t.Errorf("winning line missing: %v", c)
+	}
+	if c := Image(won, Options{}).At(int(x), int(y)); !near(c, background) {
+		t.Errorf("winning line drawn unasked: %v", c)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	var buf bytes.Buffer
+	size := engine.Size{Width: 5, Height: 4, K: 4}
+	if err := PNG(&buf, size.NewBoard().Play(7, engine.O), Options{CellSize: 16}); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 84 || b.Dy() != 68 {
+		t.Errorf("PNG bounds = %v", b)
+	}
+}
+
+func TestGIF(t *testing.T) {
+	rec, err := record.Parse(`1. b2 b3 2. a3 c1 3. a1 c3 4. a2 1-0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := GIF(&buf, rec, Options{WinningLine: true, Delay: 500 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 8 || anim.Delay[0] != 50 || anim.Delay[7] != 200 {
+		t.Errorf("GIF has %d frames, delays %v", len(anim.Image), anim.Delay)
+	}
+	// The first frame is the empty board; the last has every mark.
+	centre := anim.Image[0].At(anim.Image[0].Bounds().Dx()/2, anim.Image[0].Bounds().Dy()/2)
+	if !near(centre, background) {
+		t.Errorf("first frame centre = %v", centre)
+	}
+	if centre := anim.Image[7].At(anim.Image[7].Bounds().Dx()/2, anim.Image[7].Bounds().Dy()/2); !near(centre, xColour) {
+		t.Errorf("last frame centre = %v", centre)
+	}
+
+	rec.Moves = append(rec.Moves, record.Move{Square: 4})
+	if err := GIF(io.Discard, rec, Options{}); err == nil {
+		t.Error("GIF() of an illegal game succeeded")
+	}
+}
@@ -0,0 +1,92 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/purnet/TicTacToeBot/engine"
+)
+
+// SVG writes b to w as an SVG image.
+func SVG(w io.Writer, b engine.Board, opts Options) error {
+	l := newLayout(b.Size(), opts)
+	width, height := l.dimensions()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="%s"/>`+"\n", width, height, hex(background))
+
+	colours := heat(opts.Scores, b.Len())
+	for _, m := range sortedMoves(colours) {
+		x, y := l.corner(m)
+		fmt.Fprintf(&sb, `<rect x="%g" y="%g" width="%g" height="%g" fill="%s"/>`+"\n", x, y, l.cell, l.cell, hex(colours[m]))
+	}
+	if opts.LastMove != nil && int(*opts.LastMove) >= 0 && int(*opts.LastMove) < b.Len() {
+		x, y := l.corner(*opts.LastMove)
+		inset := l.cell * gridWidth
+		fmt.Fprintf(&sb, `<rect x="%g" y="%g" width="%g" height="%g" fill="none" stroke="%s" stroke-width="%g"/>`+"\n",
+			x+inset, y+inset, l.cell-2*inset, l.cell-2*inset, hex(lastColour), 2*inset)
+	}
+
+	size := b.Size()
+	fmt.Fprintf(&sb, `<g stroke="%s" stroke-width="%g" stroke-linecap="round">`+"\n", hex(gridColour), l.cell*gridWidth)
+	for col := 1; col < size.Width; col++ {
+		x := l.pad + float64(col)*l.cell
+		fmt.Fprintf(&sb, `<line x1="%g" y1="%g" x2="%g" y2="%g"/>`+"\n", x, l.pad, x, l.pad+float64(size.Height)*l.cell)
+	}
+	for row := 1; row < size.Height; row++ {
+		y := l.pad + float64(row)*l.cell
+		fmt.Fprintf(&sb, `<line x1="%g" y1="%g" x2="%g" y2="%g"/>`+"\n", l.pad, y, l.pad+float64(size.Width)*l.cell, y)
+	}
+	sb.WriteString("</g>\n")
+
+	stroke := l.cell * markWidth
+	for i := 0; i < b.Len(); i++ {
+		m := engine.Move(i)
+		x, y := l.corner(m)
+		lo, hi := l.cell*markInset, l.cell*(1-markInset)
+		switch b.At(m) {
+		case engine.X:
+			fmt.Fprintf(&sb, `<path d="M%g %gL%g %gM%g %gL%g %g" stroke="%s" stroke-width="%g" stroke-linecap="round"/>`+"\n",
+				x+lo, y+lo, x+hi, y+hi, x+hi, y+lo, x+lo, y+hi, hex(xColour), stroke)
+		case engine.O:
+			cx, cy := l.centre(m)
+			fmt.Fprintf(&sb, `<circle cx="%g" cy="%g" r="%g" fill="none" stroke="%s" stroke-width="%g"/>`+"\n",
+				cx, cy, (hi-lo)/2, hex(oColour), stroke)
+		}
+	}
+
+	if len(colours) > 0 {
+		fmt.Fprintf(&sb, `<g font-family="sans-serif" font-size="%g" fill="%s">`+"\n", l.cell/5, hex(textColour))
+		for _, m := range sortedMoves(colours) {
+			x, y := l.corner(m)
+			fmt.Fprintf(&sb, `<text x="%g" y="%g">%d</text>`+"\n", x+l.cell/16, y+l.cell/4, opts.Scores[m])
+		}
+		sb.WriteString("</g>\n")
+	}
+
+	if x0, y0, x1, y1, ok := winningEnds(b, l, opts); ok {
+		fmt.Fprintf(&sb, `<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="%s" stroke-opacity="%.2f" stroke-width="%g" stroke-linecap="round"/>`+"\n",
+			x0, y0, x1, y1, hex(winColour), float64(winColour.A)/255, l.cell*winWidth)
+	}
+	sb.WriteString("</svg>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// hex formats the colour of c, ignoring its alpha.
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// sortedMoves returns the keys of m in order, for stable output.
+func sortedMoves(m map[engine.Move]color.RGBA) []engine.Move {
+	moves := make([]engine.Move, 0, len(m))
+	for mv := range m {
+		moves = append(moves, mv)
+	}
+	sort.Slice(moves, func(i, j int) bool { return moves[i] < moves[j] })
+	return moves
+}
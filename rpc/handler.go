@@ -0,0 +1,36 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Handle registers fn to answer calls to method. Params are decoded into a
+// P before fn is called; absent or null params leave it the zero value,
+// and params that do not decode are reported as invalid params without
+// calling fn.
+func Handle[P, R any](s *Server, method string, fn func(ctx context.Context, params P) (R, error)) {
+	s.Register(method, func(ctx context.Context, req *Request) (interface{}, error) {
+		var params P
+		if err := DecodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		result, err := fn(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
+}
+
+// DecodeParams decodes request params into v, leaving v unchanged when
+// there are none. Errors are JSON-RPC invalid params errors.
+func DecodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return InvalidParams(err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package rpc
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns a context carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger carried by ctx, or the default logger.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// IdString formats the request's id for logs: the Merknera id, the JSON
+// text of a JSON-RPC 2.0 id, or "" for notifications.
+func (r *Request) IdString() string {
+	if r.Legacy {
+		return strconv.Itoa(r.LegacyId)
+	}
+	return string(r.Id)
+}
+
+// Logging returns middleware that hands every handler a logger, available
+// from Logger, annotated with the method and rpc id of its request, and
+// logs each call's duration and any error at debug level.
+func Logging(logger *slog.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req *Request) (interface{}, error) {
+			l := logger.With("method", req.Method, "rpcid", req.IdString())
+			start := time.Now()
+			result, err := next(WithLogger(ctx, l), req)
+			if err != nil {
+				l.DebugContext(ctx, "rpc call failed", "duration", time.Since(start), "error", err)
+			} else {
+				l.DebugContext(ctx, "rpc call", "duration", time.Since(start))
+			}
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"runtime/debug"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/purnet/TicTacToeBot/models"
+)
+
+// maxLoggedBody bounds how much of a request is logged after a panic.
+const maxLoggedBody = 1024
+
+// secretKey matches object keys whose values are never logged.
+var secretKey = regexp.MustCompile(`(?i)token|secret|password|authorization`)
+
+// Correlation is the data of an internal error caused by a panic. Its id
+// also appears in the server's log next to the request that caused it.
+type Correlation struct {
+	Id string `json:"correlationid"`
+}
+
+func (c Correlation) String() string {
+	return "correlation id " + c.Id
+}
+
+// Recoverer is middleware that turns panics in handlers into JSON-RPC
+// internal errors, so that the caller still gets a reply.
+type Recoverer struct {
+	logger *slog.Logger
+	panics atomic.Int64
+}
+
+// NewRecoverer returns a Recoverer logging to logger, or if logger is nil
+// to the logger carried by each request's context.
+func NewRecoverer(logger *slog.Logger) *Recoverer {
+	return &Recoverer{logger: logger}
+}
+
+// Panics returns the number of panics recovered so far.
+func (r *Recoverer) Panics() int64 {
+	return r.panics.Load()
+}
+
+// Middleware recovers panics in next. Each one is logged with a new
+// correlation id, the stack and the request with secrets redacted and
+// long bodies cut short, and answered with an internal error carrying
+// the same id.
+func (r *Recoverer) Middleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req *Request) (result interface{}, err error) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			r.panics.Add(1)
+			c := Correlation{Id: newCorrelationId()}
+			logger := r.logger
+			if logger == nil {
+				logger = Logger(ctx)
+			}
+			logger.ErrorContext(ctx, "recovered panic in rpc handler",
+				"correlationid", c.Id,
+				"method", req.Method,
+				"panic", fmt.Sprint(v),
+				"request", SafeBody(req.Raw),
+				"stack", string(debug.Stack()),
+			)
+			result, err = nil, &models.RpcError{Code: models.InternalError, Message: "Internal error", Data: c}
+		}()
+		return next(ctx, req)
+	}
+}
+
+func newCorrelationId() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// SafeBody returns body in a form fit for logs: values of keys that look
+// like credentials are redacted and the result is cut to a bounded length.
+// Bodies that are not valid JSON are quoted so they cannot forge log lines.
+func SafeBody(body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		body, _ = json.Marshal(redact(v))
+	} else {
+		body = []byte(fmt.Sprintf("%q", body))
+	}
+	if len(body) > maxLoggedBody {
+		// Cut at the start of a rune, not inside one.
+		n := maxLoggedBody
+		for n > 0 && !utf8.RuneStart(body[n]) {
+			n--
+		}
+		return fmt.Sprintf("%s... (%d bytes)", body[:n], len(body))
+	}
+	return string(body)
+}
+
+// redact replaces the values of secret keys anywhere in v.
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if secretKey.MatchString(k) {
+				v[k] = "REDACTED"
+			} else {
+				v[k] = redact(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = redact(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/purnet/TicTacToeBot/models"
+)
+
+func TestRecovererMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	r := NewRecoverer(slog.New(slog.NewJSONHandler(&logs, nil)))
+	s := newTestServer()
+	s.Use(r.Middleware)
+	Handle(s, "Math.Panic", func(ctx context.Context, p addParams) (sum, error) {
+		var m map[string]int
+		m["boom"] = p.A // nil map write
+		return sum{}, nil
+	})
+
+	reply := s.HandleBody(context.Background(), []byte(`{"jsonrpc":"2.0","method":"Math.Panic","params":{"a":1,"token":"hunter2"},"id":1}`))
+	var resp struct {
+		Error struct {
+			Code int         `json:"code"`
+			Data Correlation `json:"data"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(reply, &resp); err != nil {
+		t.Fatalf("reply %s is not valid JSON: %v", reply, err)
+	}
+	if resp.Error.Code != models.InternalError || resp.Error.Data.Id == "" {
+		t.Fatalf("reply = %s, expected an internal error with a correlation id", reply)
+	}
+	if r.Panics() != 1 {
+		t.Errorf("Panics() = %d, expected 1", r.Panics())
+	}
+
+	log := logs.String()
+	if !strings.Contains(log, resp.Error.Data.Id) || !strings.Contains(log, "Math.Panic") {
+		t.Errorf("log %s does not identify the request", log)
+	}
+	if strings.Contains(log, "hunter2") {
+		t.Errorf("log %s contains a secret", log)
+	}
+
+	// Later calls are unaffected, and legacy callers see the id too.
+	reply = s.HandleBody(context.Background(), []byte(`{"method":"Math.Panic","id":2}`))
+	var legacy models.ClientRpcResponse
+	json.Unmarshal(reply, &legacy)
+	if !strings.HasPrefix(legacy.Error, "Internal error: correlation id ") {
+		t.Errorf("legacy reply = %s, expected an internal error with a correlation id", reply)
+	}
+	if resp := call(t, s, `{"jsonrpc":"2.0","method":"Math.Add","params":{"a":1,"b":1},"id":3}`); resp.Result != `{"sum":2}` {
+		t.Errorf("Math.Add after a panic = %v, %v", resp.Result, resp.Error)
+	}
+	if r.Panics() != 2 {
+		t.Errorf("Panics() = %d, expected 2", r.Panics())
+	}
+}
+
+func TestSafeBody(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"a":1}`, `{"a":1}`},
+		{`{"params":{"Token":"x","list":[{"password":"y"}]}}`, `{"params":{"Token":"REDACTED","list":[{"password":"REDACTED"}]}}`},
+		{"not json\n", `"not json\n"`},
+	}
+	for _, tt := range tests {
+		if got := SafeBody([]byte(tt.body)); got != tt.want {
+			t.Errorf("SafeBody(%q) = %s, expected %s", tt.body, got, tt.want)
+		}
+	}
+
+	long := SafeBody([]byte(`"` + strings.Repeat("a", 5000) + `"`))
+	if len(long) > maxLoggedBody+32 || !strings.HasSuffix(long, "(5002 bytes)") {
+		t.Errorf("SafeBody() of a long body = %d bytes ending %q", len(long), long[len(long)-20:])
+	}
+	// Multi-byte runes straddling the limit either way are not split.
+	for _, pad := range []string{"", "a"} {
+		long := SafeBody([]byte(`"` + pad + strings.Repeat("é", 3000) + `"`))
+		if !utf8.ValidString(long) || !strings.Contains(long, "é... (") {
+			t.Errorf("SafeBody() of a long multi-byte body ends %q", long[len(long)-20:])
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Package rpc dispatches JSON-RPC requests to handlers registered by
+// method name.
+//
+// A Server speaks JSON-RPC 2.0, including notifications and batches, and
+// unless it is strict also the Merknera wire format: requests without a
+// "jsonrpc" member, integer ids and errors sent as plain strings. Replies
+// use the format of the request they answer.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/purnet/TicTacToeBot/models"
+)
+
+// Request is a decoded call as seen by handlers and middleware.
+type Request struct {
+	Method string
+	Params json.RawMessage
+	// Id is the JSON id of a JSON-RPC 2.0 request, nil for notifications.
+	Id json.RawMessage
+	// LegacyId is the id of a Merknera request.
+	LegacyId int
+	// Legacy reports that the request used the Merknera wire format.
+	Legacy bool
+	// Notification is set for JSON-RPC 2.0 requests without an id, which
+	// get no reply.
+	Notification bool
+	// Raw is the request as it arrived.
+	Raw json.RawMessage
+}
+
+// HandlerFunc answers a request. Errors that are not a *models.RpcError
+// are reported to the caller as internal errors.
+type HandlerFunc func(ctx context.Context, req *Request) (interface{}, error)
+
+// Middleware wraps a handler, for example to log or time every call.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// DefaultMaxBodyBytes is the largest request body a Server reads unless
+// told otherwise.
+const DefaultMaxBodyBytes = 1 << 20
+
+// Server is an http.Handler dispatching JSON-RPC requests to registered
+// methods. Methods and middleware must be added before the server starts
+// handling requests.
+type Server struct {
+	mu         sync.RWMutex
+	methods    map[string]HandlerFunc
+	middleware []Middleware
+	strict     bool
+	maxBody    int64
+}
+
+// NewServer returns a server with no methods that accepts both wire
+// formats.
+func NewServer() *Server {
+	return &Server{methods: make(map[string]HandlerFunc), maxBody: DefaultMaxBodyBytes}
+}
+
+// Register makes h answer calls to method, replacing any earlier handler.
+func (s *Server) Register(method string, h HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[method] = h
+}
+
+// Use appends middleware to the chain every call passes through. The first
+// middleware added is the outermost.
+func (s *Server) Use(mw ...Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middleware = append(s.middleware, mw...)
+}
+
+// SetStrict controls whether the server rejects the Merknera wire format.
+// A server that is not strict also answers requests too malformed to tell
+// which format they use in the Merknera format.
+func (s *Server) SetStrict(strict bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strict = strict
+}
+
+// SetMaxBodyBytes bounds the size of request bodies. Larger requests are
+// rejected without being read in full.
+func (s *Server) SetMaxBodyBytes(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxBody = n
+}
+
+// Methods returns the names of the registered methods.
+func (s *Server) Methods() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (s *Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	s.mu.RLock()
+	maxBody := s.maxBody
+	s.mu.RUnlock()
+
+	status := http.StatusOK
+	body, err := io.ReadAll(http.MaxBytesReader(rw, req.Body, maxBody))
+	var reply []byte
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		status = http.StatusRequestEntityTooLarge
+		reply = s.reply(&Request{Legacy: !s.isStrict()}, nil, &models.RpcError{
+			Code:    models.InvalidRequest,
+			Message: "Invalid Request",
+			Data:    fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit),
+		})
+	case err != nil:
+		reply = s.reply(&Request{Legacy: !s.isStrict()}, nil, &models.RpcError{Code: models.ParseError, Message: "Parse error", Data: err.Error()})
+	default:
+		reply = s.HandleBody(req.Context(), body)
+	}
+	if reply == nil {
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	rw.Write(reply)
+}
+
+// HandleBody answers a request or batch of requests, returning nil when
+// nothing needs to be sent back because every request was a notification.
+func (s *Server) HandleBody(ctx context.Context, body []byte) []byte {
+	strict := s.isStrict()
+	body = bytes.TrimSpace(body)
+	if !json.Valid(body) {
+		return s.reply(&Request{Legacy: !strict}, nil, &models.RpcError{Code: models.ParseError, Message: "Parse error"})
+	}
+	if body[0] != '[' {
+		return s.handle(ctx, body, strict)
+	}
+
+	var batch []json.RawMessage
+	json.Unmarshal(body, &batch)
+	if len(batch) == 0 {
+		return s.reply(&Request{}, nil, &models.RpcError{Code: models.InvalidRequest, Message: "Invalid Request", Data: "empty batch"})
+	}
+	// Batches only exist in JSON-RPC 2.0, so their requests must use it.
+	var replies []json.RawMessage
+	for _, raw := range batch {
+		if reply := s.handle(ctx, raw, true); reply != nil {
+			replies = append(replies, reply)
+		}
+	}
+	if len(replies) == 0 {
+		return nil
+	}
+	reply, _ := json.Marshal(replies)
+	return reply
+}
+
+func (s *Server) isStrict() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.strict
+}
+
+// handle answers a single request, returning nil for notifications. When
+// strict is set, the request must use JSON-RPC 2.0.
+func (s *Server) handle(ctx context.Context, raw json.RawMessage, strict bool) []byte {
+	req, rpcErr := decodeRequest(raw, strict)
+	if rpcErr != nil {
+		return s.reply(req, nil, rpcErr)
+	}
+	result, err := s.Call(ctx, req)
+	if req.Notification {
+		return nil
+	}
+	return s.reply(req, result, err)
+}
+
+// Call passes req through the middleware chain to the method it names.
+func (s *Server) Call(ctx context.Context, req *Request) (interface{}, error) {
+	s.mu.RLock()
+	h, ok := s.methods[req.Method]
+	middleware := s.middleware
+	s.mu.RUnlock()
+
+	if !ok {
+		h = func(ctx context.Context, req *Request) (interface{}, error) {
+			return nil, &models.RpcError{Code: models.MethodNotFound, Message: "Method not found", Data: req.Method}
+		}
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h(ctx, req)
+}
+
+// decodeRequest parses a single request in either wire format, or only in
+// JSON-RPC 2.0 if strict is set. Requests that are not valid in the format
+// they use are reported as invalid requests.
+func decodeRequest(raw json.RawMessage, strict bool) (*Request, *models.RpcError) {
+	req := &Request{Legacy: !strict, Raw: raw}
+	invalid := func(detail string) (*Request, *models.RpcError) {
+		return req, &models.RpcError{Code: models.InvalidRequest, Message: "Invalid Request", Data: detail}
+	}
+
+	var wire models.Request
+	if err := json.Unmarshal(raw, &wire); err != nil {
+		return invalid(err.Error())
+	}
+	switch {
+	case wire.JsonRpc == models.Version:
+		req.Legacy = false
+	case wire.JsonRpc != "" || strict:
+		req.Legacy = false
+		return invalid(`"jsonrpc" must be "2.0"`)
+	}
+
+	if req.Legacy {
+		if wire.Id != nil && json.Unmarshal(wire.Id, &req.LegacyId) != nil {
+			return invalid("id must be an integer")
+		}
+	} else {
+		switch {
+		case wire.Id == nil:
+			req.Notification = true
+		case wire.Id[0] == '"' || wire.Id[0] == '-' || wire.Id[0] >= '0' && wire.Id[0] <= '9':
+			req.Id = wire.Id
+		case string(wire.Id) != "null":
+			return invalid("id must be a string, number or null")
+		}
+	}
+
+	if wire.Method == "" {
+		return invalid("missing method")
+	}
+	if len(wire.Params) > 0 && wire.Params[0] != '{' && wire.Params[0] != '[' && string(wire.Params) != "null" {
+		return invalid("params must be an object or array")
+	}
+	req.Method, req.Params = wire.Method, wire.Params
+	return req, nil
+}
+
+// reply encodes the response to req in the format it arrived in.
+func (s *Server) reply(req *Request, result interface{}, err error) []byte {
+	rpcErr := AsError(err)
+	if req.Legacy {
+		resp := models.ClientRpcResponse{Result: result, Id: req.LegacyId}
+		if rpcErr != nil {
+			resp = models.ClientRpcResponse{Error: rpcErr.Error(), Id: req.LegacyId}
+		}
+		body, _ := json.Marshal(resp)
+		return body
+	}
+	resp := models.Response{JsonRpc: models.Version, Result: result, Error: rpcErr, Id: req.Id}
+	body, _ := json.Marshal(resp)
+	return body
+}
+
+// AsError returns err as a JSON-RPC error object. Errors that are not
+// already one become internal errors. AsError returns nil if err is nil.
+func AsError(err error) *models.RpcError {
+	if err == nil {
+		return nil
+	}
+	var rpcErr *models.RpcError
+	if !errors.As(err, &rpcErr) {
+		rpcErr = &models.RpcError{Code: models.InternalError, Message: "Internal error", Data: err.Error()}
+	}
+	return rpcErr
+}
+
+// InvalidParams reports err as a JSON-RPC invalid params error.
+func InvalidParams(err error) *models.RpcError {
+	return &models.RpcError{Code: models.InvalidParams, Message: "Invalid params", Data: err.Error()}
+}
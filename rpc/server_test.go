@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/purnet/TicTacToeBot/models"
+)
+
+type addParams struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type sum struct {
+	Sum int `json:"sum"`
+}
+
+func newTestServer() *Server {
+	s := NewServer()
+	Handle(s, "Math.Add", func(ctx context.Context, p addParams) (sum, error) {
+		return sum{p.A + p.B}, nil
+	})
+	Handle(s, "Math.Fail", func(ctx context.Context, p addParams) (sum, error) {
+		return sum{}, errors.New("boom")
+	})
+	Handle(s, "Math.Reject", func(ctx context.Context, p addParams) (sum, error) {
+		return sum{}, &models.RpcError{Code: 42, Message: "rejected"}
+	})
+	return s
+}
+
+func call(t *testing.T, s *Server, body string) models.Response {
+	t.Helper()
+	var resp struct {
+		models.Response
+		Result json.RawMessage `json:"result"`
+	}
+	reply := s.HandleBody(context.Background(), []byte(body))
+	if err := json.Unmarshal(reply, &resp); err != nil {
+		t.Fatalf("reply %s is not valid JSON: %v", reply, err)
+	}
+	if resp.Result != nil {
+		resp.Response.Result = string(resp.Result)
+	}
+	return resp.Response
+}
+
+func TestHandleDecodesTypedParams(t *testing.T) {
+	resp := call(t, newTestServer(), `{"jsonrpc":"2.0","method":"Math.Add","params":{"a":2,"b":3},"id":1}`)
+	if resp.Error != nil || resp.Result != `{"sum":5}` {
+		t.Errorf("Math.Add = %v, %v, expected {\"sum\":5}", resp.Result, resp.Error)
+	}
+
+	resp = call(t, newTestServer(), `{"jsonrpc":"2.0","method":"Math.Add","id":1}`)
+	if resp.Error != nil || resp.Result != `{"sum":0}` {
+		t.Errorf("Math.Add without params = %v, %v, expected {\"sum\":0}", resp.Result, resp.Error)
+	}
+
+	resp = call(t, newTestServer(), `{"jsonrpc":"2.0","method":"Math.Add","params":{"a":"two"},"id":1}`)
+	if resp.Error == nil || resp.Error.Code != models.InvalidParams {
+		t.Errorf("Math.Add with bad params = %v, expected invalid params", resp.Error)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	s := newTestServer()
+	resp := call(t, s, `{"jsonrpc":"2.0","method":"Math.Fail","id":1}`)
+	if resp.Error == nil || resp.Error.Code != models.InternalError || resp.Error.Data != "boom" {
+		t.Errorf("Math.Fail error = %+v, expected an internal error", resp.Error)
+	}
+	resp = call(t, s, `{"jsonrpc":"2.0","method":"Math.Reject","id":1}`)
+	if resp.Error == nil || resp.Error.Code != 42 {
+		t.Errorf("Math.Reject error = %+v, expected code 42", resp.Error)
+	}
+	resp = call(t, s, `{"jsonrpc":"2.0","method":"Math.Divide","id":1}`)
+	if resp.Error == nil || resp.Error.Code != models.MethodNotFound {
+		t.Errorf("Math.Divide error = %+v, expected method not found", resp.Error)
+	}
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	s := newTestServer()
+	var trace []string
+	tag := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, req *Request) (interface{}, error) {
+				trace = append(trace, name+">"+req.Method)
+				result, err := next(ctx, req)
+				trace = append(trace, "<"+name)
+				return result, err
+			}
+		}
+	}
+	s.Use(tag("outer"), tag("inner"))
+
+	call(t, s, `{"jsonrpc":"2.0","method":"Math.Add","id":1}`)
+	call(t, s, `{"jsonrpc":"2.0","method":"Unknown","id":2}`)
+	want := "outer>Math.Add inner>Math.Add <inner <outer outer>Unknown inner>Unknown <inner <outer"
+	if got := strings.Join(trace, " "); got != want {
+		t.Errorf("middleware ran %q, expected %q", got, want)
+	}
+}
+
+func TestMiddlewareCanShortCircuit(t *testing.T) {
+	s := newTestServer()
+	s.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req *Request) (interface{}, error) {
+			if req.Legacy {
+				return nil, &models.RpcError{Code: models.InvalidRequest, Message: "legacy calls disabled"}
+			}
+			return next(ctx, req)
+		}
+	})
+	reply := s.HandleBody(context.Background(), []byte(`{"method":"Math.Add","id":3}`))
+	var legacy models.ClientRpcResponse
+	if err := json.Unmarshal(reply, &legacy); err != nil || legacy.Error != "legacy calls disabled" || legacy.Id != 3 {
+		t.Errorf("legacy reply = %s, expected the middleware's error", reply)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	got := newTestServer().Methods()
+	sort.Strings(got)
+	if strings.Join(got, ",") != "Math.Add,Math.Fail,Math.Reject" {
+		t.Errorf("Methods() = %v", got)
+	}
+}
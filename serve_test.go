@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/purnet/TicTacToeBot/models"
+	"github.com/purnet/TicTacToeBot/rpc"
+)
+
+func serve(bot *TicTacToeBot, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	bot.ServeHTTP(rr, req)
+	return rr
+}
+
+// rpcResponse decodes a JSON-RPC 2.0 response, keeping track of which
+// members were sent.
+type rpcResponse struct {
+	JsonRpc string           `json:"jsonrpc"`
+	Result  *json.RawMessage `json:"result"`
+	Error   *models.RpcError `json:"error"`
+	Id      json.RawMessage  `json:"id"`
+}
+
+func decodeResponse(t *testing.T, body []byte) rpcResponse {
+	t.Helper()
+	var resp rpcResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("response %s is not valid JSON: %v", body, err)
+	}
+	if resp.JsonRpc != "2.0" {
+		t.Errorf("response %s has no jsonrpc member", body)
+	}
+	if (resp.Result == nil) == (resp.Error == nil) {
+		t.Errorf("response %s must have exactly one of result and error", body)
+	}
+	return resp
+}
+
+func TestServeHTTP_JSONRPC2(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		id     string
+		code   int
+		result string
+	}{
+		{"ping", `{"jsonrpc":"2.0","method":"Status.Ping","id":1}`, `1`, 0, `{"ping":"OK"}`},
+		{"string id", `{"jsonrpc":"2.0","method":"Status.Ping","id":"abc"}`, `"abc"`, 0, `{"ping":"OK"}`},
+		{"null id", `{"jsonrpc":"2.0","method":"Status.Ping","id":null}`, `null`, 0, `{"ping":"OK"}`},
+		{"next move", `{"jsonrpc":"2.0","method":"TicTacToe.NextMove","params":{"gameid":1,"mark":"O","gamestate":["X","X","","O","O","","","",""]},"id":2}`, `2`, 0, `{"position":5}`},
+		{"parse error", `{"jsonrpc":"2.0","method":`, `null`, models.ParseError, ``},
+		{"wrong version", `{"jsonrpc":"1.0","method":"Status.Ping","id":3}`, `null`, models.InvalidRequest, ``},
+		{"missing method", `{"jsonrpc":"2.0","id":4}`, `4`, models.InvalidRequest, ``},
+		{"method not a string", `{"jsonrpc":"2.0","method":1,"id":5}`, `null`, models.InvalidRequest, ``},
+		{"object id", `{"jsonrpc":"2.0","method":"Status.Ping","id":{}}`, `null`, models.InvalidRequest, ``},
+		{"scalar params", `{"jsonrpc":"2.0","method":"Status.Ping","params":1,"id":6}`, `6`, models.InvalidRequest, ``},
+		{"unknown method", `{"jsonrpc":"2.0","method":"Unknown.Method","id":7}`, `7`, models.MethodNotFound, ``},
+		{"bad params", `{"jsonrpc":"2.0","method":"TicTacToe.NextMove","params":{"gameid":"x"},"id":8}`, `8`, models.InvalidParams, ``},
+		{"positional params", `{"jsonrpc":"2.0","method":"TicTacToe.Complete","params":[1,"X"],"id":9}`, `9`, models.InvalidParams, ``},
+		{"finished game", `{"jsonrpc":"2.0","method":"TicTacToe.NextMove","params":{"gameid":1,"mark":"O","gamestate":["X","X","X","O","O","","","",""]},"id":10}`, `10`, models.InvalidParams, ``},
+	}
+
+	// Merknera compatibility would answer unparseable bodies in its own
+	// format, so these run strict.
+	bot := NewTicTacToeBot()
+	bot.SetStrictRPC(true)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(bot, tt.body)
+			if rr.Code != http.StatusOK {
+				t.Errorf("status = %v, expected 200", rr.Code)
+			}
+			resp := decodeResponse(t, rr.Body.Bytes())
+			if string(resp.Id) != tt.id {
+				t.Errorf("id = %s, expected %s", resp.Id, tt.id)
+			}
+			if tt.code != 0 {
+				if resp.Error == nil || resp.Error.Code != tt.code {
+					t.Errorf("response %s, expected error code %d", rr.Body, tt.code)
+				}
+				return
+			}
+			if resp.Error != nil {
+				t.Fatalf("unexpected error %+v", resp.Error)
+			}
+			if string(*resp.Result) != tt.result {
+				t.Errorf("result = %s, expected %s", *resp.Result, tt.result)
+			}
+		})
+	}
+}
+
+func TestServeHTTP_Notification(t *testing.T) {
+	rr := serve(NewTicTacToeBot(), `{"jsonrpc":"2.0","method":"TicTacToe.Complete","params":{"gameid":1,"mark":"X","winner":true,"gamestate":["X","X","X","O","O","","","",""]}}`)
+	if rr.Code != http.StatusNoContent || rr.Body.Len() != 0 {
+		t.Errorf("notification got status %v and body %q, expected no reply", rr.Code, rr.Body)
+	}
+
+	// Notifications get no reply even when they fail.
+	rr = serve(NewTicTacToeBot(), `{"jsonrpc":"2.0","method":"Unknown.Method"}`)
+	if rr.Body.Len() != 0 {
+		t.Errorf("failed notification got reply %q", rr.Body)
+	}
+}
+
+func TestServeHTTP_Batch(t *testing.T) {
+	bot := NewTicTacToeBot()
+	rr := serve(bot, `[
+		{"jsonrpc":"2.0","method":"Status.Ping","id":1},
+		{"jsonrpc":"2.0","method":"Status.Ping"},
+		{"jsonrpc":"2.0","method":"Unknown.Method","id":"two"},
+		1
+	]`)
+	var replies []json.RawMessage
+	if err := json.Unmarshal(rr.Body.Bytes(), &replies); err != nil {
+		t.Fatalf("batch reply %s is not an array: %v", rr.Body, err)
+	}
+	if len(replies) != 3 {
+		t.Fatalf("batch reply %s, expected 3 responses", rr.Body)
+	}
+	if resp := decodeResponse(t, replies[0]); string(resp.Id) != "1" || resp.Error != nil {
+		t.Errorf("batch reply 0 = %s, expected the ping result", replies[0])
+	}
+	if resp := decodeResponse(t, replies[1]); string(resp.Id) != `"two"` || resp.Error == nil || resp.Error.Code != models.MethodNotFound {
+		t.Errorf("batch reply 1 = %s, expected method not found", replies[1])
+	}
+	if resp := decodeResponse(t, replies[2]); string(resp.Id) != "null" || resp.Error == nil || resp.Error.Code != models.InvalidRequest {
+		t.Errorf("batch reply 2 = %s, expected invalid request", replies[2])
+	}
+
+	rr = serve(bot, `[]`)
+	if resp := decodeResponse(t, rr.Body.Bytes()); resp.Error == nil || resp.Error.Code != models.InvalidRequest {
+		t.Errorf("empty batch reply = %s, expected invalid request", rr.Body)
+	}
+
+	rr = serve(bot, `[{"jsonrpc":"2.0","method":"Status.Ping"}]`)
+	if rr.Body.Len() != 0 {
+		t.Errorf("batch of notifications got reply %q", rr.Body)
+	}
+}
+
+func TestServeHTTP_MerkneraCompatibility(t *testing.T) {
+	bot := NewTicTacToeBot()
+	tests := []struct {
+		name  string
+		body  string
+		id    int
+		error bool
+	}{
+		{"ping", `{"method":"Status.Ping","id":3}`, 3, false},
+		{"no id", `{"method":"Status.Ping"}`, 0, false},
+		{"unknown method", `{"method":"Unknown.Method","id":4}`, 4, true},
+		{"string id", `{"method":"Status.Ping","id":"abc"}`, 0, true},
+		{"garbage", `not json`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(bot, tt.body)
+			var response models.ClientRpcResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("response %s is not in Merknera format: %v", rr.Body, err)
+			}
+			if strings.Contains(rr.Body.String(), `"jsonrpc"`) {
+				t.Errorf("response %s has a jsonrpc member", rr.Body)
+			}
+			if response.Id != tt.id {
+				t.Errorf("id = %v, expected %v", response.Id, tt.id)
+			}
+			if (response.Error != "") != tt.error {
+				t.Errorf("error = %q, expected error: %v", response.Error, tt.error)
+			}
+		})
+	}
+
+	bot.SetStrictRPC(true)
+	rr := serve(bot, `{"method":"Status.Ping","id":3}`)
+	if resp := decodeResponse(t, rr.Body.Bytes()); resp.Error == nil || resp.Error.Code != models.InvalidRequest {
+		t.Errorf("strict bot answered a Merknera request with %s", rr.Body)
+	}
+}
+
+func TestServeHTTP_MalformedBodies(t *testing.T) {
+	nextMove := `{"jsonrpc":"2.0","method":"TicTacToe.NextMove","params":{"gameid":1,"mark":"X","gamestate":["","","","","","","","",""]},"id":1}`
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   int
+	}{
+		{"empty", ``, http.StatusOK, models.ParseError},
+		{"garbage", "\x00\xff{{[not json", http.StatusOK, models.ParseError},
+		{"truncated", nextMove[:len(nextMove)/2], http.StatusOK, models.ParseError},
+		{"truncated batch", `[` + nextMove + `,` + nextMove[:20], http.StatusOK, models.ParseError},
+		{"oversized", `{"jsonrpc":"2.0","method":"Status.Ping","params":{"pad":"` + strings.Repeat("a", 2<<20) + `"},"id":1}`, http.StatusRequestEntityTooLarge, models.InvalidRequest},
+		{"wrong types", `{"jsonrpc":"2.0","method":"TicTacToe.NextMove","params":{"gamestate":[1,2,3]},"id":1}`, http.StatusOK, models.InvalidParams},
+	}
+
+	bot := NewTicTacToeBot()
+	bot.SetStrictRPC(true)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(bot, tt.body)
+			if rr.Code != tt.status {
+				t.Errorf("status = %v, expected %v", rr.Code, tt.status)
+			}
+			resp := decodeResponse(t, rr.Body.Bytes())
+			if resp.Error == nil || resp.Error.Code != tt.code {
+				t.Errorf("response %.200s, expected error code %d", rr.Body, tt.code)
+			}
+		})
+	}
+
+	// The bot keeps serving afterwards.
+	if rr := serve(bot, nextMove); !strings.Contains(rr.Body.String(), `"position"`) {
+		t.Errorf("NextMove after malformed requests = %s", rr.Body)
+	}
+	if bot.Panics() != 0 {
+		t.Errorf("Panics() = %d, expected malformed bodies to be rejected without panicking", bot.Panics())
+	}
+}
+
+func TestServeHTTP_RecoversPanics(t *testing.T) {
+	bot := NewTicTacToeBot()
+	rpc.Handle(bot.Server(), "Test.Panic", func(ctx context.Context, params models.NextMoveParams) (models.StatusResponseParams, error) {
+		panic("boom")
+	})
+
+	rr := serve(bot, `{"jsonrpc":"2.0","method":"Test.Panic","params":{"gameid":9},"id":1}`)
+	resp := decodeResponse(t, rr.Body.Bytes())
+	if resp.Error == nil || resp.Error.Code != models.InternalError {
+		t.Fatalf("response %s, expected an internal error", rr.Body)
+	}
+	if !strings.Contains(rr.Body.String(), `"correlationid"`) {
+		t.Errorf("response %s has no correlation id", rr.Body)
+	}
+	if bot.Panics() != 1 {
+		t.Errorf("Panics() = %d, expected 1", bot.Panics())
+	}
+
+	// Merknera requests also get a reply.
+	rr = serve(bot, `{"method":"Test.Panic","id":2}`)
+	var legacy models.ClientRpcResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &legacy); err != nil || legacy.Id != 2 || legacy.Error == "" {
+		t.Errorf("legacy response %s, expected an error for id 2", rr.Body)
+	}
+}
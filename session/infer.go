@@ -0,0 +1,140 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of anomaly found between consecutive boards of a game.
+const (
+	// MarkChanged: we were told to play a different mark than before.
+	MarkChanged = "mark changed"
+	// SizeChanged: the board's dimensions or win length changed.
+	SizeChanged = "size changed"
+	// OwnMarkRemoved: a square we had taken is no longer ours.
+	OwnMarkRemoved = "own mark removed"
+	// OwnMarkAdded: one of our marks appeared where we did not play.
+	OwnMarkAdded = "own mark added"
+	// OpponentMarkRemoved: a square the opponent had taken changed.
+	OpponentMarkRemoved = "opponent mark removed"
+	// MultipleMoves: the opponent placed more than one mark.
+	MultipleMoves = "multiple opponent moves"
+	// NoMove: the opponent placed no mark.
+	NoMove = "no opponent move"
+)
+
+// Anomaly is a way in which a board is not a legal successor of the one
+// before it.
+type Anomaly struct {
+	Kind      string `json:"kind"`
+	Positions []int  `json:"positions,omitempty"`
+	Detail    string `json:"detail"`
+}
+
+func (a Anomaly) String() string {
+	return a.Detail
+}
+
+// Anomalies lists the anomalies found in one board.
+type Anomalies []Anomaly
+
+func (as Anomalies) String() string {
+	details := make([]string, len(as))
+	for i, a := range as {
+		details[i] = a.Detail
+	}
+	return strings.Join(details, "; ")
+}
+
+// Inference is what can be told about a board from the game's previous
+// turn: the opponent's move, if it made exactly one, and any anomalies.
+type Inference struct {
+	// Previous reports that the game had an earlier answered turn to
+	// compare with. Without one nothing is inferred.
+	Previous bool `json:"previous"`
+	// OpponentMoves are the squares the opponent took since our last
+	// move.
+	OpponentMoves []int     `json:"opponentmoves,omitempty"`
+	Anomalies     Anomalies `json:"anomalies,omitempty"`
+}
+
+// OpponentMove returns the opponent's move if it made exactly one.
+func (inf Inference) OpponentMove() (int, bool) {
+	if len(inf.OpponentMoves) != 1 {
+		return -1, false
+	}
+	return inf.OpponentMoves[0], true
+}
+
+// Infer compares a board received for a game with the board of its last
+// answered turn plus the move we returned, so a board sent again after we
+// failed to answer it is compared like the first time. Sessions with no
+// answered turn are not compared.
+func Infer(prev Session, mark string, width, height, winLength int, board []string) Inference {
+	n := len(prev.Turns) - 1
+	for n >= 0 && prev.Turns[n].Move < 0 {
+		n--
+	}
+	if n < 0 {
+		return Inference{}
+	}
+	last := prev.Turns[n]
+	inf := Inference{Previous: true}
+	add := func(kind string, positions []int, format string, args ...interface{}) {
+		inf.Anomalies = append(inf.Anomalies, Anomaly{Kind: kind, Positions: positions, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if mark != prev.Mark {
+		add(MarkChanged, nil, "mark changed from %s to %s", prev.Mark, mark)
+		return inf
+	}
+	if width != prev.Width || height != prev.Height || winLength != prev.WinLength || len(board) != len(last.Board) {
+		add(SizeChanged, nil, "board changed from %dx%d (%d in a row) to %dx%d (%d in a row)",
+			prev.Width, prev.Height, prev.WinLength, width, height, winLength)
+		return inf
+	}
+
+	expected := append([]string(nil), last.Board...)
+	if last.Move < len(expected) {
+		expected[last.Move] = mark
+	}
+	var removed, added, overwritten []int
+	for i, was := range expected {
+		now := board[i]
+		switch {
+		case was == now:
+		case was == mark:
+			removed = append(removed, i)
+		case now == mark:
+			added = append(added, i)
+		case was != "":
+			overwritten = append(overwritten, i)
+		default:
+			inf.OpponentMoves = append(inf.OpponentMoves, i)
+		}
+	}
+	if len(removed) > 0 {
+		add(OwnMarkRemoved, removed, "our %s at %s was moved or removed", mark, positions(removed))
+	}
+	if len(added) > 0 {
+		add(OwnMarkAdded, added, "an %s we did not play appeared at %s", mark, positions(added))
+	}
+	if len(overwritten) > 0 {
+		add(OpponentMarkRemoved, overwritten, "the opponent's mark at %s was moved or removed", positions(overwritten))
+	}
+	switch n := len(inf.OpponentMoves); {
+	case n > 1:
+		add(MultipleMoves, inf.OpponentMoves, "the opponent moved %d times, at %s", n, positions(inf.OpponentMoves))
+	case n == 0:
+		add(NoMove, nil, "the opponent did not move")
+	}
+	return inf
+}
+
+func positions(ps []int) string {
+	s := make([]string, len(ps))
+	for i, p := range ps {
+		s[i] = fmt.Sprint(p)
+	}
+	return strings.Join(s, ", ")
+}
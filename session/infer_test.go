@@ -0,0 +1,90 @@
+package session
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// board parses a 3x3 board written as "X-O/-X-/--O".
+func board(s string) []string {
+	var b []string
+	for _, c := range strings.ReplaceAll(s, "/", "") {
+		if c == '-' {
+			b = append(b, "")
+		} else {
+			b = append(b, string(c))
+		}
+	}
+	return b
+}
+
+// played returns a 3x3 session for X whose last turn received prev and
+// answered move.
+func played(prev string, move int) Session {
+	return Session{Mark: "X", Width: 3, Height: 3, WinLength: 3, Turns: []Turn{{Board: board(prev), Move: move}}}
+}
+
+// retried returns played(prev, move) with a later turn that received
+// again and was not answered.
+func retried(prev string, move int, again string) Session {
+	s := played(prev, move)
+	s.Turns = append(s.Turns, Turn{Board: board(again), Move: -1})
+	return s
+}
+
+func TestInfer(t *testing.T) {
+	tests := []struct {
+		name     string
+		prev     Session
+		mark     string
+		next     string
+		opponent []int
+		kinds    []string
+	}{
+		{"legal reply", played("---/---/---", 4), "X", "O--/-X-/---", []int{0}, nil},
+		{"no history", Session{}, "X", "O--/---/---", nil, nil},
+		{"unanswered turn", played("---/---/---", -1), "X", "O--/---/---", nil, nil},
+		{"board sent again", retried("---/---/---", 4, "-O-/-X-/---"), "X", "-O-/-X-/---", []int{1}, nil},
+		{"bad board sent again", retried("---/---/---", 4, "X--/---/---"), "X", "X--/---/---", nil, []string{OwnMarkRemoved, OwnMarkAdded, NoMove}},
+		{"own mark moved", played("---/---/---", 4), "X", "OX-/---/---", []int{0}, []string{OwnMarkRemoved, OwnMarkAdded}},
+		{"own mark overwritten", played("O--/---/---", 4), "X", "O--/-O-/---", nil, []string{OwnMarkRemoved, NoMove}},
+		{"opponent mark removed", played("O--/---/---", 4), "X", "---/-X-/-O-", []int{7}, []string{OpponentMarkRemoved}},
+		{"two opponent moves", played("---/---/---", 4), "X", "O-O/-X-/---", []int{0, 2}, []string{MultipleMoves}},
+		{"no opponent move", played("---/---/---", 4), "X", "---/-X-/---", nil, []string{NoMove}},
+		{"mark changed", played("---/---/---", 4), "O", "O--/-X-/---", nil, []string{MarkChanged}},
+		{"size changed", played("---/---/---", 4), "X", "----/----/----/----", nil, []string{SizeChanged}},
+	}
+	for _, tt := range tests {
+		next := board(tt.next)
+		width := 3
+		if len(next) == 16 {
+			width = 4
+		}
+		inf := Infer(tt.prev, tt.mark, width, width, 3, next)
+		if !reflect.DeepEqual(inf.OpponentMoves, tt.opponent) {
+			t.Errorf("%s: opponent moves = %v, expected %v", tt.name, inf.OpponentMoves, tt.opponent)
+		}
+		var kinds []string
+		for _, a := range inf.Anomalies {
+			kinds = append(kinds, a.Kind)
+			if a.Detail == "" {
+				t.Errorf("%s: anomaly %q has no detail", tt.name, a.Kind)
+			}
+		}
+		if !reflect.DeepEqual(kinds, tt.kinds) {
+			t.Errorf("%s: anomalies = %v, expected %v", tt.name, inf.Anomalies, tt.kinds)
+		}
+		if _, ok := inf.OpponentMove(); ok != (len(tt.opponent) == 1) {
+			t.Errorf("%s: OpponentMove() ok = %v", tt.name, ok)
+		}
+	}
+}
+
+func TestAnomaliesString(t *testing.T) {
+	inf := Infer(played("---/---/---", 4), "X", 3, 3, 3, board("O-O/---/---"))
+	want := "our X at 4 was moved or removed; the opponent moved 2 times, at 0, 2"
+	if got := inf.Anomalies.String(); got != want {
+		t.Errorf("Anomalies.String() = %q, expected %q", got, want)
+	}
+}
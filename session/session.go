@@ -0,0 +1,273 @@
+// Package session tracks the games a bot is playing, keyed by game id, so
+// that the NextMove, Error and Complete calls of one game can be related.
+//
+// A session starts with the first call about its game and closes when the
+// game completes or, if the game server abandons it, when it has been
+// idle for longer than the store's time to live.
+package session
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long a session may stay idle unless a store is told
+// otherwise.
+const DefaultTTL = 30 * time.Minute
+
+// Results of closed sessions.
+const (
+	Win     = "win"
+	Loss    = "loss"
+	Draw    = "draw"
+	Expired = "expired"
+)
+
+// Turn is one NextMove call: the board received and the move returned.
+type Turn struct {
+	Received time.Time `json:"received"`
+	Board    []string  `json:"board"`
+	// Opponent is the opponent's move since our last one, or -1 if it is
+	// not known.
+	Opponent int `json:"opponent"`
+	// Anomalies found comparing the board with the previous turn.
+	Anomalies Anomalies `json:"anomalies,omitempty"`
+	// Move is the position returned, or -1 if no move was returned.
+	Move    int       `json:"move"`
+	Replied time.Time `json:"replied,omitempty"`
+	// Score and Outcome are the engine's evaluation of Move, if the
+	// strategy analysed it.
+	Score   *int   `json:"score,omitempty"`
+	Outcome string `json:"outcome,omitempty"`
+}
+
+// GameError is an error the game server reported for the game.
+type GameError struct {
+	Time    time.Time `json:"time"`
+	Code    int       `json:"code"`
+	Message string    `json:"message"`
+}
+
+// Session is the history of one game.
+type Session struct {
+	GameId    int         `json:"gameid"`
+	Mark      string      `json:"mark"`
+	Width     int         `json:"width"`
+	Height    int         `json:"height"`
+	WinLength int         `json:"winlength"`
+	Started   time.Time   `json:"started"`
+	Updated   time.Time   `json:"updated"`
+	Turns     []Turn      `json:"turns"`
+	Errors    []GameError `json:"errors,omitempty"`
+
+	// Result is empty while the session is live, then Win, Loss, Draw or
+	// Expired.
+	Result     string    `json:"result,omitempty"`
+	FinalBoard []string  `json:"finalboard,omitempty"`
+	Ended      time.Time `json:"ended,omitempty"`
+}
+
+// Closed reports whether the session has ended.
+func (s Session) Closed() bool {
+	return s.Result != ""
+}
+
+// clone returns a copy of s sharing no memory with it.
+func (s *Session) clone() Session {
+	c := *s
+	c.Turns = make([]Turn, len(s.Turns))
+	for i, t := range s.Turns {
+		t.Board = append([]string(nil), t.Board...)
+		t.Anomalies = append(Anomalies(nil), t.Anomalies...)
+		if t.Score != nil {
+			score := *t.Score
+			t.Score = &score
+		}
+		c.Turns[i] = t
+	}
+	c.Errors = append([]GameError(nil), s.Errors...)
+	c.FinalBoard = append([]string(nil), s.FinalBoard...)
+	return c
+}
+
+// Store holds the live sessions. It is safe for concurrent use.
+type Store struct {
+	mu        sync.Mutex
+	live      map[int]*Session
+	ttl       time.Duration
+	onClose   func(Session)
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewStore returns an empty store expiring sessions idle for longer than
+// ttl, or DefaultTTL if ttl is zero.
+func NewStore(ttl time.Duration) *Store {
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	return &Store{live: make(map[int]*Session), ttl: ttl, now: time.Now}
+}
+
+// OnClose sets a function called with every session as it closes, by
+// completing or expiring. It is called without the store's lock held.
+func (s *Store) OnClose(f func(Session)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onClose = f
+}
+
+// Received records a board received for a game, with the mark we play and
+// the game's dimensions, starting a session if there is none. It returns
+// what Infer tells from comparing the board with the previous turn.
+func (s *Store) Received(gameId int, mark string, width, height, winLength int, board []string) Inference {
+	var inf Inference
+	s.update(gameId, func(sess *Session, now time.Time) {
+		inf = Infer(*sess, mark, width, height, winLength, board)
+		opponent, _ := inf.OpponentMove()
+		sess.Mark, sess.Width, sess.Height, sess.WinLength = mark, width, height, winLength
+		sess.Turns = append(sess.Turns, Turn{
+			Received:  now,
+			Board:     append([]string(nil), board...),
+			Opponent:  opponent,
+			Anomalies: inf.Anomalies,
+			Move:      -1,
+		})
+	})
+	return inf
+}
+
+// Infer returns what Received would tell from a board, without recording
+// it.
+func (s *Store) Infer(gameId int, mark string, width, height, winLength int, board []string) Inference {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var prev Session
+	if sess, ok := s.live[gameId]; ok {
+		prev = *sess
+	}
+	return Infer(prev, mark, width, height, winLength, board)
+}
+
+// Moved records the move returned for the last board received.
+func (s *Store) Moved(gameId, position int) {
+	s.update(gameId, func(sess *Session, now time.Time) {
+		if n := len(sess.Turns); n > 0 {
+			sess.Turns[n-1].Move, sess.Turns[n-1].Replied = position, now
+		}
+	})
+}
+
+// Scored records the engine's score and outcome for the move returned for
+// the last board received.
+func (s *Store) Scored(gameId, score int, outcome string) {
+	s.update(gameId, func(sess *Session, now time.Time) {
+		if n := len(sess.Turns); n > 0 {
+			sess.Turns[n-1].Score, sess.Turns[n-1].Outcome = &score, outcome
+		}
+	})
+}
+
+// Error records an error reported for a game.
+func (s *Store) Error(gameId, code int, message string) {
+	s.update(gameId, func(sess *Session, now time.Time) {
+		sess.Errors = append(sess.Errors, GameError{Time: now, Code: code, Message: message})
+	})
+}
+
+// Complete closes a game's session with its result and final board, and
+// returns it. Games without a session get one holding just the result.
+func (s *Store) Complete(gameId int, mark, result string, board []string) Session {
+	var closed Session
+	s.update(gameId, func(sess *Session, now time.Time) {
+		if sess.Mark == "" {
+			sess.Mark = mark
+		}
+		sess.Result, sess.FinalBoard, sess.Ended = result, append([]string(nil), board...), now
+		closed = sess.clone()
+	})
+	return closed
+}
+
+// Get returns the live session of a game.
+func (s *Store) Get(gameId int) (Session, bool) {
+	s.Sweep()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.live[gameId]
+	if !ok {
+		return Session{}, false
+	}
+	return sess.clone(), true
+}
+
+// Live returns the live sessions in game id order.
+func (s *Store) Live() []Session {
+	s.Sweep()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := make([]Session, 0, len(s.live))
+	for _, sess := range s.live {
+		sessions = append(sessions, sess.clone())
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].GameId < sessions[j].GameId })
+	return sessions
+}
+
+// Sweep closes sessions idle for longer than the time to live, returning
+// them.
+func (s *Store) Sweep() []Session {
+	s.mu.Lock()
+	now := s.now()
+	var expired []Session
+	for id, sess := range s.live {
+		if now.Sub(sess.Updated) > s.ttl {
+			sess.Result, sess.Ended = Expired, now
+			expired = append(expired, sess.clone())
+			delete(s.live, id)
+		}
+	}
+	s.lastSweep = now
+	onClose := s.onClose
+	s.mu.Unlock()
+
+	sort.Slice(expired, func(i, j int) bool { return expired[i].GameId < expired[j].GameId })
+	if onClose != nil {
+		for _, sess := range expired {
+			onClose(sess)
+		}
+	}
+	return expired
+}
+
+// update applies f to a game's session, starting one if needed, and
+// closes it if f set a result. Abandoned sessions are swept from time to
+// time.
+func (s *Store) update(gameId int, f func(sess *Session, now time.Time)) {
+	s.mu.Lock()
+	now := s.now()
+	sess, ok := s.live[gameId]
+	if !ok {
+		sess = &Session{GameId: gameId, Started: now}
+		s.live[gameId] = sess
+	}
+	f(sess, now)
+	sess.Updated = now
+	var closed *Session
+	if sess.Closed() {
+		delete(s.live, gameId)
+		c := sess.clone()
+		closed = &c
+	}
+	sweep := now.Sub(s.lastSweep) > s.ttl/4
+	onClose := s.onClose
+	s.mu.Unlock()
+
+	if closed != nil && onClose != nil {
+		onClose(*closed)
+	}
+	if sweep {
+		s.Sweep()
+	}
+}
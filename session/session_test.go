@@ -0,0 +1,137 @@
+package session
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// clock is a manually advanced time source.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func testStore(ttl time.Duration) (*Store, *clock) {
+	c := &clock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	s := NewStore(ttl)
+	s.now = c.Now
+	return s, c
+}
+
+func TestStoreRecordsGames(t *testing.T) {
+	s, c := testStore(time.Hour)
+	var closed []Session
+	s.OnClose(func(sess Session) { closed = append(closed, sess) })
+
+	board := []string{"", "", "", "", "", "", "", "", ""}
+	if inf := s.Received(7, "X", 3, 3, 3, board); inf.Previous {
+		t.Errorf("Received() = %+v for a new game, expected nothing inferred", inf)
+	}
+	board[0] = "X" // must not alter the recorded board
+	c.Advance(time.Second)
+	s.Moved(7, 4)
+	s.Scored(7, 0, "draw")
+	c.Advance(time.Second)
+	inf := s.Received(7, "X", 3, 3, 3, []string{"O", "", "", "", "X", "", "", "", ""})
+	if move, ok := inf.OpponentMove(); !ok || move != 0 || len(inf.Anomalies) != 0 {
+		t.Errorf("Received() = %+v, expected the opponent to have played 0", inf)
+	}
+	s.Error(7, 3, "timeout warning")
+	s.Received(8, "O", 3, 3, 3, board)
+
+	live := s.Live()
+	if len(live) != 2 || live[0].GameId != 7 || live[1].GameId != 8 {
+		t.Fatalf("Live() = %+v", live)
+	}
+	game := live[0]
+	if game.Mark != "X" || len(game.Turns) != 2 || len(game.Errors) != 1 || game.Closed() {
+		t.Errorf("session = %+v", game)
+	}
+	first := game.Turns[0]
+	if first.Board[0] != "" || first.Move != 4 || first.Replied.Sub(first.Received) != time.Second ||
+		first.Score == nil || *first.Score != 0 || first.Outcome != "draw" {
+		t.Errorf("first turn = %+v", first)
+	}
+	if game.Turns[0].Opponent != -1 || game.Turns[1].Opponent != 0 || game.Turns[1].Move != -1 {
+		t.Errorf("turns = %+v, expected the opponent's move in the second, which is unanswered", game.Turns)
+	}
+
+	final := []string{"O", "X", "", "", "X", "", "", "X", ""}
+	done := s.Complete(7, "X", Win, final)
+	if done.Result != Win || !reflect.DeepEqual(done.FinalBoard, final) || done.Ended.IsZero() || len(done.Turns) != 2 {
+		t.Errorf("Complete() = %+v", done)
+	}
+	if _, ok := s.Get(7); ok {
+		t.Error("completed session is still live")
+	}
+	if len(closed) != 1 || closed[0].GameId != 7 {
+		t.Errorf("OnClose saw %+v", closed)
+	}
+
+	// Completing an unknown game still reports its result.
+	if done := s.Complete(9, "O", Draw, final); done.Mark != "O" || done.Result != Draw || len(done.Turns) != 0 {
+		t.Errorf("Complete() of an unknown game = %+v", done)
+	}
+}
+
+func TestStoreExpiresAbandonedSessions(t *testing.T) {
+	s, c := testStore(time.Minute)
+	var closed []Session
+	s.OnClose(func(sess Session) { closed = append(closed, sess) })
+	s.Received(1, "X", 3, 3, 3, make([]string, 9))
+	c.Advance(50 * time.Second)
+	s.Received(2, "X", 3, 3, 3, make([]string, 9))
+	c.Advance(20 * time.Second)
+
+	if _, ok := s.Get(1); ok {
+		t.Error("session idle for 70s survived a 1m time to live")
+	}
+	if _, ok := s.Get(2); !ok {
+		t.Error("session idle for 20s expired")
+	}
+	if len(closed) != 1 || closed[0].GameId != 1 || closed[0].Result != Expired {
+		t.Errorf("OnClose saw %+v", closed)
+	}
+
+	// Writes sweep too.
+	c.Advance(2 * time.Minute)
+	s.Received(3, "X", 3, 3, 3, make([]string, 9))
+	if len(closed) != 2 || closed[1].GameId != 2 {
+		t.Errorf("OnClose saw %+v after a write", closed)
+	}
+}
+
+func TestStoreConcurrentUse(t *testing.T) {
+	s := NewStore(0)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				s.Received(g, "X", 3, 3, 3, make([]string, 9))
+				s.Moved(g, i%9)
+				s.Live()
+			}
+			s.Complete(g, "X", Loss, make([]string, 9))
+		}(g)
+	}
+	wg.Wait()
+	if live := s.Live(); len(live) != 0 {
+		t.Errorf("Live() after every game completed = %d sessions", len(live))
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/merknera"
+)
+
+// runOptions control how run shuts down.
+type runOptions struct {
+	// Drain is how long requests in progress at shutdown get to finish.
+	// Moves still being searched when three quarters of it have passed
+	// are answered with the best move found so far.
+	Drain time.Duration
+	// Deregister takes the bot offline with the game server before run
+	// returns, waiting at most DeregisterTimeout.
+	Deregister        bool
+	DeregisterTimeout time.Duration
+}
+
+// run serves srv on ln and keeps the bot registered through registrar, if
+// it is not nil, until ctx is done or serving fails. When ctx is done it
+// stops accepting requests, drains those in progress and optionally
+// deregisters before returning nil.
+func run(ctx context.Context, srv *http.Server, ln net.Listener, registrar *merknera.Registrar, opts runOptions) error {
+	moves, hurry := context.WithCancel(context.Background())
+	defer hurry()
+	srv.BaseContext = func(net.Listener) context.Context { return moves }
+
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	registered := make(chan struct{})
+	if registrar != nil {
+		go func() {
+			defer close(registered)
+			if err := registrar.Run(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("registration stopped", "error", err)
+			}
+		}()
+	} else {
+		close(registered)
+	}
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down", "drain", opts.Drain)
+	drain, cancel := context.WithTimeout(context.Background(), opts.Drain)
+	defer cancel()
+	timer := time.AfterFunc(opts.Drain*3/4, hurry)
+	defer timer.Stop()
+	if err := srv.Shutdown(drain); err != nil {
+		slog.Warn("requests still in progress after draining, closing connections", "error", err)
+		srv.Close()
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	<-registered
+	if registrar != nil && opts.Deregister {
+		ctx, cancel := context.WithTimeout(context.Background(), opts.DeregisterTimeout)
+		defer cancel()
+		registrar.Deregister(ctx)
+	}
+	slog.Info("shut down")
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/merknera"
+	"github.com/purnet/TicTacToeBot/models"
+	"github.com/purnet/TicTacToeBot/rpc"
+)
+
+// startRun runs bot behind a fresh listener until the returned cancel
+// function is called, returning its URL and run's result.
+func startRun(t *testing.T, bot *TicTacToeBot, registrar *merknera.Registrar, opts runOptions) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, &http.Server{Handler: bot.Handler()}, ln, registrar, opts) }()
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+func TestRunDrainsMovesInProgress(t *testing.T) {
+	bot := NewTicTacToeBot()
+	started := make(chan struct{})
+	var once sync.Once
+	bot.Server().Use(func(next rpc.HandlerFunc) rpc.HandlerFunc {
+		return func(ctx context.Context, req *rpc.Request) (interface{}, error) {
+			once.Do(func() { close(started) })
+			return next(ctx, req)
+		}
+	})
+	url, cancel, done := startRun(t, bot, nil, runOptions{Drain: 400 * time.Millisecond})
+
+	// An empty 4x4 board takes far longer to solve than the drain period,
+	// so the move is cut short rather than dropped.
+	type reply struct {
+		status int
+		body   models.ClientRpcResponse
+		err    error
+	}
+	replies := make(chan reply, 1)
+	go func() {
+		body := `{"method":"TicTacToe.NextMove","params":{"gameid":1,"mark":"X","width":4,"height":4,"winlength":4,` +
+			`"gamestate":["","","","","","","","","","","","","","","",""]},"id":1}`
+		resp, err := http.Post(url, "application/json", strings.NewReader(body))
+		if err != nil {
+			replies <- reply{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		var r reply
+		r.status = resp.StatusCode
+		r.err = json.NewDecoder(resp.Body).Decode(&r.body)
+		replies <- r
+	}()
+
+	<-started
+	start := time.Now()
+	cancel()
+	r := <-replies
+	if r.err != nil || r.status != http.StatusOK || r.body.Error != "" || r.body.Result == nil {
+		t.Fatalf("move in progress at shutdown = %d %+v, %v", r.status, r.body, r.err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("run() = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("shutdown took %v with a 400ms drain", elapsed)
+	}
+	if _, err := http.Post(url, "application/json", strings.NewReader(`{"method":"Status.Ping","id":2}`)); err == nil {
+		t.Error("the bot accepted a request after shutting down")
+	}
+}
+
+func TestRunRegistersAndDeregisters(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	var deregistered models.DeregistrationParams
+	merkneraServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var call struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(req.Body).Decode(&call)
+		mu.Lock()
+		methods = append(methods, call.Method)
+		if call.Method == merknera.DefaultDeregisterMethod {
+			json.Unmarshal(call.Params, &deregistered)
+		}
+		mu.Unlock()
+		rw.Write(CreateRPCResponse(models.RegistrationResponse{Message: "ok"}, "", 1))
+	}))
+	defer merkneraServer.Close()
+
+	bot := NewTicTacToeBot()
+	bot.SetBaseUrl(merkneraServer.URL)
+	params := registrationParams("secret", "TICTACTOE", "bot", "", "2.1", "", "")
+	registrar := merknera.NewRegistrar(bot.Client(), merknera.RegistrarOptions{Params: params})
+	bot.SetRegistrar(registrar)
+	url, cancel, done := startRun(t, bot, registrar, runOptions{Drain: time.Second, Deregister: true, DeregisterTimeout: time.Second})
+
+	for !registrar.Registered() {
+		time.Sleep(time.Millisecond)
+	}
+	resp, err := http.Get(url + "/status")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /status = %v, %v", resp, err)
+	}
+	resp.Body.Close()
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("run() = %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(methods) != 2 || methods[0] != "RegistrationService.Register" || methods[1] != merknera.DefaultDeregisterMethod {
+		t.Errorf("game server saw %v, expected a registration then a deregistration", methods)
+	}
+	if deregistered != (models.DeregistrationParams{Token: "secret", BotName: "bot", BotVersion: "2.1"}) {
+		t.Errorf("deregistered with %+v", deregistered)
+	}
+	if state := registrar.Status().State; state != merknera.StateDeregistered {
+		t.Errorf("registrar state = %q after shutdown", state)
+	}
+}
+
+func TestRunReturnsServeErrors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	err = run(context.Background(), &http.Server{Handler: NewTicTacToeBot()}, ln, nil, runOptions{})
+	if err == nil {
+		t.Error("run() on a closed listener returned nil")
+	}
+}
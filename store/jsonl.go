@@ -0,0 +1,131 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// JSONL is a GameStore appending one JSON object per game to a file.
+// Queries read the whole file.
+type JSONL struct {
+	mu   sync.Mutex
+	f    *os.File
+	path string
+}
+
+// OpenJSONL opens the JSON Lines file at path, creating it if needed. A
+// torn last line, left by a crash while saving, is cut off so that later
+// games are not appended to it.
+func OpenJSONL(path string) (*JSONL, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	if err := cutTornLine(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("store: %s: %w", path, err)
+	}
+	return &JSONL{f: f, path: path}, nil
+}
+
+// cutTornLine truncates f after its last newline.
+func cutTornLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 4096)
+	end := info.Size()
+	for end > 0 {
+		start := max(0, end-int64(len(buf)))
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			break
+		}
+		end = start
+	}
+	if end == info.Size() {
+		return nil
+	}
+	return f.Truncate(end)
+}
+
+// Save appends g to the file and flushes it to stable storage.
+func (s *JSONL) Save(g Game) error {
+	line, err := json.Marshal(g)
+	if err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return errClosed
+	}
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("store: %s: %w", s.path, err)
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("store: %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Query scans the file for the games q selects. A torn last line, being
+// saved by another process, is skipped.
+func (s *JSONL) Query(q Query) ([]Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil, errClosed
+	}
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	defer f.Close()
+
+	var games []Game
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16<<20)
+	var torn error
+	for n := 1; sc.Scan(); n++ {
+		if torn != nil {
+			return nil, torn
+		}
+		var g Game
+		if err := json.Unmarshal(sc.Bytes(), &g); err != nil {
+			torn = fmt.Errorf("store: %s:%d: %w", s.path, n, err)
+			continue
+		}
+		if q.Matches(g) {
+			games = append(games, g)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("store: %s: %w", s.path, err)
+	}
+	return finish(games, q), nil
+}
+
+// Close closes the file.
+func (s *JSONL) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return errClosed
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+var errClosed = errors.New("store: closed")
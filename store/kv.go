@@ -0,0 +1,318 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// KV is an embedded key-value store kept in a single append-only file.
+// Every Put or Delete appends a record; an in-memory index maps each key
+// to its latest value. Compact rewrites the file without overwritten and
+// deleted values. Writes reach stable storage only once Sync is called.
+// It is safe for concurrent use.
+//
+// Each record is a header of a CRC-32 checksum, the key length and the
+// value length, all little-endian uint32, followed by the key and value.
+// A value length of tombstone marks a deletion.
+type KV struct {
+	mu    sync.RWMutex
+	f     *os.File
+	path  string
+	size  int64
+	index map[string]span
+	stale int64
+}
+
+// span locates a value in the file.
+type span struct {
+	off int64
+	n   uint32
+}
+
+const (
+	headerSize = 12
+	tombstone  = ^uint32(0)
+	maxKeySize = 1 << 16
+)
+
+// ErrNotFound is returned by KV.Get for missing keys.
+var ErrNotFound = errors.New("store: key not found")
+
+// OpenKV opens the store at path, creating it if needed. A damaged record
+// at the end of the file, left by a crash while writing, is cut off.
+func OpenKV(path string) (*KV, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	kv := &KV{f: f, path: path, index: make(map[string]span)}
+	if err := kv.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return kv, nil
+}
+
+// load rebuilds the index from the file.
+func (kv *KV) load() error {
+	info, err := kv.f.Stat()
+	if err != nil {
+		return fmt.Errorf("store: %s: %w", kv.path, err)
+	}
+	r := bufio.NewReader(kv.f)
+	var off int64
+	for {
+		key, val, n, err := readRecord(r, info.Size()-off)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Everything from the first bad record on is lost.
+			if err := kv.f.Truncate(off); err != nil {
+				return fmt.Errorf("store: %s: %w", kv.path, err)
+			}
+			break
+		}
+		kv.apply(string(key), off, val, n)
+		off += n
+	}
+	kv.size = off
+	return nil
+}
+
+// readRecord reads one record, returning its key, value (nil for a
+// tombstone) and size. left is the number of bytes left in the file, which
+// bounds what a damaged header can make it allocate.
+func readRecord(r io.Reader, left int64) (key, val []byte, n int64, err error) {
+	var h [headerSize]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, nil, 0, errors.New("short header")
+		}
+		return nil, nil, 0, err
+	}
+	sum := binary.LittleEndian.Uint32(h[0:])
+	klen := binary.LittleEndian.Uint32(h[4:])
+	vlen := binary.LittleEndian.Uint32(h[8:])
+	if klen > maxKeySize {
+		return nil, nil, 0, errors.New("key too long")
+	}
+	body := int64(klen)
+	if vlen != tombstone {
+		body += int64(vlen)
+	}
+	if body > left-headerSize {
+		return nil, nil, 0, errors.New("short record")
+	}
+	buf := make([]byte, body)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, nil, 0, errors.New("short record")
+	}
+	crc := crc32.NewIEEE()
+	crc.Write(h[4:])
+	crc.Write(buf)
+	if crc.Sum32() != sum {
+		return nil, nil, 0, errors.New("checksum mismatch")
+	}
+	key = buf[:klen]
+	if vlen != tombstone {
+		val = buf[klen:]
+	}
+	return key, val, headerSize + body, nil
+}
+
+// apply updates the index for a record of size n at off.
+func (kv *KV) apply(key string, off int64, val []byte, n int64) {
+	if old, ok := kv.index[key]; ok {
+		kv.stale += headerSize + int64(len(key)) + int64(old.n)
+	}
+	if val == nil {
+		delete(kv.index, key)
+		kv.stale += n
+		return
+	}
+	kv.index[key] = span{off: off + headerSize + int64(len(key)), n: uint32(len(val))}
+}
+
+// encode returns the record for key and val, a nil val being a tombstone.
+func encode(key string, val []byte) []byte {
+	rec := make([]byte, headerSize, headerSize+len(key)+len(val))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(len(key)))
+	vlen := uint32(len(val))
+	if val == nil {
+		vlen = tombstone
+	}
+	binary.LittleEndian.PutUint32(rec[8:], vlen)
+	rec = append(rec, key...)
+	rec = append(rec, val...)
+	binary.LittleEndian.PutUint32(rec[0:], crc32.ChecksumIEEE(rec[4:]))
+	return rec
+}
+
+func (kv *KV) write(key string, val []byte) error {
+	if len(key) == 0 || len(key) > maxKeySize {
+		return fmt.Errorf("store: invalid key length %d", len(key))
+	}
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.f == nil {
+		return errClosed
+	}
+	rec := encode(key, val)
+	if _, err := kv.f.WriteAt(rec, kv.size); err != nil {
+		return fmt.Errorf("store: %s: %w", kv.path, err)
+	}
+	kv.apply(key, kv.size, val, int64(len(rec)))
+	kv.size += int64(len(rec))
+	return nil
+}
+
+// Put sets the value of key.
+func (kv *KV) Put(key string, val []byte) error {
+	if val == nil {
+		val = []byte{}
+	}
+	return kv.write(key, val)
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (kv *KV) Delete(key string) error {
+	return kv.write(key, nil)
+}
+
+// Get returns the value of key, or ErrNotFound.
+func (kv *KV) Get(key string) ([]byte, error) {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	if kv.f == nil {
+		return nil, errClosed
+	}
+	s, ok := kv.index[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	val := make([]byte, s.n)
+	if _, err := kv.f.ReadAt(val, s.off); err != nil {
+		return nil, fmt.Errorf("store: %s: %w", kv.path, err)
+	}
+	return val, nil
+}
+
+// Keys returns the keys in [from, to) in order. An empty to means no
+// upper bound.
+func (kv *KV) Keys(from, to string) []string {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	var keys []string
+	for k := range kv.index {
+		if k >= from && (to == "" || k < to) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Prefix returns the keys beginning with prefix in order.
+func (kv *KV) Prefix(prefix string) []string {
+	keys := kv.Keys(prefix, "")
+	i := sort.Search(len(keys), func(i int) bool { return !strings.HasPrefix(keys[i], prefix) })
+	return keys[:i]
+}
+
+// Stale returns the bytes taken by overwritten and deleted values, which
+// Compact reclaims.
+func (kv *KV) Stale() int64 {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return kv.stale
+}
+
+// Compact rewrites the file with only the live values.
+func (kv *KV) Compact() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.f == nil {
+		return errClosed
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(kv.path), ".compact-*")
+	if err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	keys := make([]string, 0, len(kv.index))
+	for k := range kv.index {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	index := make(map[string]span, len(keys))
+	w := bufio.NewWriter(tmp)
+	var off int64
+	for _, k := range keys {
+		s := kv.index[k]
+		val := make([]byte, s.n)
+		if _, err := kv.f.ReadAt(val, s.off); err != nil {
+			tmp.Close()
+			return fmt.Errorf("store: %s: %w", kv.path, err)
+		}
+		rec := encode(k, val)
+		if _, err := w.Write(rec); err != nil {
+			tmp.Close()
+			return fmt.Errorf("store: %w", err)
+		}
+		index[k] = span{off: off + headerSize + int64(len(k)), n: s.n}
+		off += int64(len(rec))
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), kv.path); err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	f, err := os.OpenFile(kv.path, os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	kv.f.Close()
+	kv.f, kv.index, kv.size, kv.stale = f, index, off, 0
+	return nil
+}
+
+// Sync flushes the file to stable storage.
+func (kv *KV) Sync() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.f == nil {
+		return errClosed
+	}
+	return kv.f.Sync()
+}
+
+// Close closes the file.
+func (kv *KV) Close() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.f == nil {
+		return errClosed
+	}
+	err := kv.f.Close()
+	kv.f = nil
+	return err
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func openKV(t *testing.T, path string) *KV {
+	t.Helper()
+	kv, err := OpenKV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kv
+}
+
+func TestKV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kv")
+	kv := openKV(t, path)
+	for _, k := range []string{"b", "a/2", "a/1", "c"} {
+		if err := kv.Put(k, []byte("v"+k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kv.Put("b", []byte("new"))
+	kv.Put("empty", nil)
+	kv.Delete("c")
+	kv.Delete("missing")
+	if err := kv.Put("", []byte("x")); err == nil {
+		t.Error("Put() with an empty key succeeded")
+	}
+	kv.Close()
+
+	kv = openKV(t, path)
+	defer kv.Close()
+	for k, want := range map[string]string{"a/1": "va/1", "b": "new", "empty": ""} {
+		if got, err := kv.Get(k); err != nil || string(got) != want {
+			t.Errorf("Get(%q) = %q, %v, expected %q", k, got, err, want)
+		}
+	}
+	if _, err := kv.Get("c"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a deleted key: %v", err)
+	}
+	if got, want := kv.Keys("a", "b"), []string{"a/1", "a/2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %v, expected %v", got, want)
+	}
+	if got, want := kv.Prefix("a/"), []string{"a/1", "a/2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Prefix() = %v, expected %v", got, want)
+	}
+	if got, want := kv.Keys("b", ""), []string{"b", "empty"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() unbounded = %v, expected %v", got, want)
+	}
+}
+
+func TestKVCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kv")
+	kv := openKV(t, path)
+	for i := 0; i < 10; i++ {
+		kv.Put("k", make([]byte, 100))
+	}
+	kv.Put("gone", []byte("x"))
+	kv.Delete("gone")
+	if kv.Stale() == 0 {
+		t.Error("Stale() = 0 after overwriting")
+	}
+	before, _ := os.Stat(path)
+	if err := kv.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() || kv.Stale() != 0 {
+		t.Errorf("Compact() took the file from %d to %d bytes, %d stale", before.Size(), after.Size(), kv.Stale())
+	}
+	kv.Put("next", []byte("y"))
+	kv.Close()
+
+	kv = openKV(t, path)
+	defer kv.Close()
+	if got := kv.Keys("", ""); !reflect.DeepEqual(got, []string{"k", "next"}) {
+		t.Errorf("after compacting, Keys() = %v", got)
+	}
+	if v, err := kv.Get("k"); err != nil || len(v) != 100 {
+		t.Errorf("after compacting, Get() = %d bytes, %v", len(v), err)
+	}
+}
+
+func TestKVDamagedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kv")
+	kv := openKV(t, path)
+	kv.Put("a", []byte("1"))
+	kv.Put("b", []byte("2"))
+	kv.Close()
+	good, _ := os.Stat(path)
+
+	tests := []struct {
+		name   string
+		damage func(data []byte) []byte
+		keys   []string
+	}{
+		{"short header", func(d []byte) []byte { return append(d, 1, 2, 3) }, []string{"a", "b"}},
+		{"short record", func(d []byte) []byte { return append(d, encode("c", []byte("333"))[:15]...) }, []string{"a", "b"}},
+		{"huge value length", func(d []byte) []byte {
+			rec := encode("c", []byte("3"))
+			rec[8], rec[9], rec[10], rec[11] = 0xfe, 0xff, 0xff, 0xff
+			return append(d, rec...)
+		}, []string{"a", "b"}},
+		{"bad checksum", func(d []byte) []byte { d[len(d)-1] ^= 0xff; return d }, []string{"a"}},
+	}
+	for _, tt := range tests {
+		data, _ := os.ReadFile(path)
+		damaged := filepath.Join(t.TempDir(), "kv")
+		os.WriteFile(damaged, tt.damage(data[:good.Size()]), 0o644)
+		kv := openKV(t, damaged)
+		if got := kv.Keys("", ""); !reflect.DeepEqual(got, tt.keys) {
+			t.Errorf("%s: Keys() = %v, expected %v", tt.name, got, tt.keys)
+		}
+		// Writes after the cut are readable again.
+		kv.Put("z", []byte("9"))
+		kv.Close()
+		kv = openKV(t, damaged)
+		if v, err := kv.Get("z"); err != nil || string(v) != "9" {
+			t.Errorf("%s: write after recovery: %q, %v", tt.name, v, err)
+		}
+		kv.Close()
+	}
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// KVStore is a GameStore on a KV file. Games are keyed by the time they
+// ended, so date queries only read the games in range.
+type KVStore struct {
+	kv  *KV
+	seq atomic.Int64
+}
+
+const gamePrefix = "game/"
+
+// OpenKVStore opens the KV file at path, creating it if needed.
+func OpenKVStore(path string) (*KVStore, error) {
+	kv, err := OpenKV(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &KVStore{kv: kv}
+	s.seq.Store(int64(len(kv.Prefix(gamePrefix))))
+	return s, nil
+}
+
+// gameKey orders games by end time. The sequence number keeps two games
+// with the same id and end time apart.
+func gameKey(ended time.Time, gameId int, seq int64) string {
+	return fmt.Sprintf("%s%s/%010d/%d", gamePrefix, timeKey(ended), gameId, seq)
+}
+
+// timeKey formats t so that keys sort by time, times before 1970 too.
+func timeKey(t time.Time) string {
+	if t.IsZero() {
+		return fmt.Sprintf("%020d", 0)
+	}
+	return fmt.Sprintf("%020d", uint64(t.UnixNano())^1<<63)
+}
+
+// Save stores g under its end time and flushes it to stable storage.
+func (s *KVStore) Save(g Game) error {
+	val, err := json.Marshal(g)
+	if err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	if err := s.kv.Put(gameKey(g.Ended, g.GameId, s.seq.Add(1)), val); err != nil {
+		return err
+	}
+	return s.kv.Sync()
+}
+
+// Query reads the games ending in q's date range and filters them.
+func (s *KVStore) Query(q Query) ([]Game, error) {
+	from, to := gamePrefix, gamePrefix[:len(gamePrefix)-1]+"0"
+	if !q.From.IsZero() {
+		from = gamePrefix + timeKey(q.From)
+	}
+	if !q.To.IsZero() {
+		to = gamePrefix + timeKey(q.To)
+	}
+	var games []Game
+	for _, k := range s.kv.Keys(from, to) {
+		val, err := s.kv.Get(k)
+		if err != nil {
+			return nil, err
+		}
+		var g Game
+		if err := json.Unmarshal(val, &g); err != nil {
+			return nil, fmt.Errorf("store: %s: %w", k, err)
+		}
+		if q.Matches(g) {
+			games = append(games, g)
+		}
+	}
+	return finish(games, q), nil
+}
+
+// Close closes the file.
+func (s *KVStore) Close() error {
+	return s.kv.Close()
+}
@@ -0,0 +1,124 @@
+// Package store keeps the history of finished games, so that they can be
+// looked up after the bot's logs are gone.
+//
+// Games are saved to a GameStore. Two backends are provided: JSONL, an
+// append-only JSON Lines file, and KVStore, built on KV, an embedded
+// log-structured key-value store. Both live in a single local file.
+package store
+
+import (
+	"sort"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/session"
+)
+
+// Game is a finished game: its session, with every board received, move
+// returned, error reported and their times, and whether we won.
+type Game struct {
+	session.Session
+	Winner bool `json:"winner"`
+}
+
+// NewGame returns the record of a closed session.
+func NewGame(s session.Session) Game {
+	return Game{Session: s, Winner: s.Result == session.Win}
+}
+
+// Duration returns how long the game lasted.
+func (g Game) Duration() time.Duration {
+	return g.Ended.Sub(g.Started)
+}
+
+// OpponentMoves returns the opponent's moves in order, as far as they can
+// be told: marks on the first board we received, the moves inferred
+// between turns, and a last move found on the final board.
+func (g Game) OpponentMoves() []int {
+	opponent := "X"
+	if g.Mark == "X" {
+		opponent = "O"
+	}
+	var moves []int
+	if len(g.Turns) > 0 {
+		for i, m := range g.Turns[0].Board {
+			if m == opponent {
+				moves = append(moves, i)
+			}
+		}
+	}
+	for _, t := range g.Turns[min(1, len(g.Turns)):] {
+		if t.Opponent >= 0 {
+			moves = append(moves, t.Opponent)
+		}
+	}
+	if n := len(g.Turns); n > 0 && g.Turns[n-1].Move >= 0 && len(g.FinalBoard) == len(g.Turns[n-1].Board) {
+		last := g.Turns[n-1]
+		for i, m := range g.FinalBoard {
+			if m == opponent && last.Board[i] != opponent && i != last.Move {
+				moves = append(moves, i)
+			}
+		}
+	}
+	return moves
+}
+
+// Query selects games. Zero fields match every game.
+type Query struct {
+	// From and To bound the time the game ended, From inclusive and To
+	// exclusive.
+	From, To time.Time
+	// Result is session.Win, Loss, Draw or Expired.
+	Result string
+	// OpponentOpening matches games whose opponent moves, as returned by
+	// OpponentMoves, begin with these.
+	OpponentOpening []int
+	// Match, if set, must accept the game too.
+	Match func(Game) bool
+	// Limit caps the number of games returned, the most recent kept.
+	Limit int
+}
+
+// Matches reports whether q selects g.
+func (q Query) Matches(g Game) bool {
+	if !q.From.IsZero() && g.Ended.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !g.Ended.Before(q.To) {
+		return false
+	}
+	if q.Result != "" && g.Result != q.Result {
+		return false
+	}
+	if len(q.OpponentOpening) > 0 {
+		moves := g.OpponentMoves()
+		if len(moves) < len(q.OpponentOpening) {
+			return false
+		}
+		for i, m := range q.OpponentOpening {
+			if moves[i] != m {
+				return false
+			}
+		}
+	}
+	return q.Match == nil || q.Match(g)
+}
+
+// GameStore saves finished games and finds them again. Implementations
+// are safe for concurrent use.
+type GameStore interface {
+	// Save adds a game. Saving a game id again keeps both games.
+	Save(g Game) error
+	// Query returns the games q selects, oldest first.
+	Query(q Query) ([]Game, error)
+	// Close releases the store's resources.
+	Close() error
+}
+
+// finish orders games oldest first and applies q's limit.
+func finish(games []Game, q Query) []Game {
+	sort.SliceStable(games, func(i, j int) bool { return games[i].Ended.Before(games[j].Ended) })
+	if q.Limit > 0 && len(games) > q.Limit {
+		games = games[len(games)-q.Limit:]
+	}
+	return games
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/purnet/TicTacToeBot/session"
+)
+
+var day = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// game returns a finished 3x3 game for X. The opponent, O, opens at
+// opening; we answer at 4.
+func game(id int, ended time.Time, result string, opening int) Game {
+	first := make([]string, 9)
+	second := make([]string, 9)
+	second[4] = "X"
+	second[opening] = "O"
+	final := append([]string(nil), second...)
+	return NewGame(session.Session{
+		GameId: id, Mark: "X", Width: 3, Height: 3, WinLength: 3,
+		Started: ended.Add(-time.Minute),
+		Updated: ended,
+		Turns: []session.Turn{
+			{Received: ended.Add(-time.Minute), Board: first, Opponent: -1, Move: 4, Replied: ended.Add(-time.Minute)},
+			{Received: ended.Add(-time.Second), Board: second, Opponent: opening, Move: -1},
+		},
+		Errors:     []session.GameError{{Time: ended.Add(-time.Second), Code: 2, Message: "slow"}},
+		Result:     result,
+		FinalBoard: final,
+		Ended:      ended,
+	})
+}
+
+func ids(games []Game) []int {
+	var ids []int
+	for _, g := range games {
+		ids = append(ids, g.GameId)
+	}
+	return ids
+}
+
+var backends = []struct {
+	name string
+	open func(path string) (GameStore, error)
+}{
+	{"jsonl", func(path string) (GameStore, error) { return OpenJSONL(path) }},
+	{"kv", func(path string) (GameStore, error) { return OpenKVStore(path) }},
+}
+
+func TestGameStores(t *testing.T) {
+	for _, b := range backends {
+		b := b
+		t.Run(b.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "games")
+			s, err := b.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved := []Game{
+				game(3, day.Add(3*time.Hour), session.Loss, 0),
+				game(1, day.Add(1*time.Hour), session.Win, 0),
+				game(2, day.Add(2*time.Hour), session.Draw, 2),
+				game(4, day.Add(26*time.Hour), session.Win, 8),
+			}
+			for _, g := range saved {
+				if err := s.Save(g); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tests := []struct {
+				name string
+				q    Query
+				ids  []int
+			}{
+				{"all", Query{}, []int{1, 2, 3, 4}},
+				{"by date", Query{From: day.Add(2 * time.Hour), To: day.Add(24 * time.Hour)}, []int{2, 3}},
+				{"by result", Query{Result: session.Win}, []int{1, 4}},
+				{"by opening", Query{OpponentOpening: []int{0}}, []int{1, 3}},
+				{"limit", Query{Limit: 2}, []int{3, 4}},
+				{"match", Query{Match: func(g Game) bool { return g.GameId%2 == 0 }}, []int{2, 4}},
+				{"combined", Query{From: day, Result: session.Win, OpponentOpening: []int{8}}, []int{4}},
+				{"nothing", Query{Result: session.Expired}, nil},
+			}
+			for _, tt := range tests {
+				games, err := s.Query(tt.q)
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				if got := ids(games); !reflect.DeepEqual(got, tt.ids) {
+					t.Errorf("%s: Query() = games %v, expected %v", tt.name, got, tt.ids)
+				}
+			}
+
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Save(saved[0]); err == nil {
+				t.Error("Save() after Close() succeeded")
+			}
+
+			s, err = b.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			games, err := s.Query(Query{Result: session.Win})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(games) != 2 || !games[0].Winner || games[1].Duration() != time.Minute {
+				t.Fatalf("after reopening, Query() = %+v", games)
+			}
+			got := games[0]
+			if want := saved[1]; !reflect.DeepEqual(got.FinalBoard, want.FinalBoard) || len(got.Turns) != 2 ||
+				len(got.Errors) != 1 || !got.Ended.Equal(want.Ended) || got.Turns[1].Opponent != 0 {
+				t.Errorf("after reopening, game = %+v, expected %+v", got, want)
+			}
+
+			// The same game saved twice is kept twice.
+			if err := s.Save(saved[1]); err != nil {
+				t.Fatal(err)
+			}
+			if games, _ := s.Query(Query{Result: session.Win}); len(games) != 3 {
+				t.Errorf("after saving a game again, %d wins, expected 3", len(games))
+			}
+		})
+	}
+}
+
+func TestOpponentMoves(t *testing.T) {
+	g := game(1, day, session.Loss, 0)
+	g.Turns[0].Board[8] = "O" // the opponent went first
+	g.FinalBoard[6] = "O"     // and won after our last reply
+	g.Turns[1].Move = 2
+	if got, want := g.OpponentMoves(), []int{8, 0, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OpponentMoves() = %v, expected %v", got, want)
+	}
+}
+
+func TestJSONLTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+	s, err := OpenJSONL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(game(1, day, session.Win, 0)); err != nil {
+		t.Fatal(err)
+	}
+	// A crash while saving the next game.
+	s.f.WriteString(`{"gameid":2,"mark":`)
+	games, err := s.Query(Query{})
+	if err != nil || len(games) != 1 {
+		t.Fatalf("Query() with a torn last line = %v, %v", ids(games), err)
+	}
+	s.Close()
+
+	s, err = OpenJSONL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Save(game(3, day.Add(time.Hour), session.Win, 0)); err != nil {
+		t.Fatal(err)
+	}
+	games, err = s.Query(Query{})
+	if got := ids(games); err != nil || !reflect.DeepEqual(got, []int{1, 3}) {
+		t.Errorf("Query() after reopening and saving = %v, %v, expected games [1 3]", got, err)
+	}
+}
+
+func TestJSONLCorruptLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+	os.WriteFile(path, []byte("{\"gameid\":1}\nnot json\n{\"gameid\":3}\n"), 0o644)
+	s, err := OpenJSONL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Query(Query{}); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("Query() with a bad line in the middle: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/purnet/TicTacToeBot/engine"
+	"github.com/purnet/TicTacToeBot/models"
+	"github.com/purnet/TicTacToeBot/rpc"
+)
+
+// validateNextMove checks that params describe a game in progress in which
+// it is Mark's turn, and returns its board. Errors are JSON-RPC invalid
+// params errors.
+func validateNextMove(params models.NextMoveParams) (engine.Board, error) {
+	board, err := validateBoard(params.Width, params.Height, params.WinLength, params.GameState)
+	if err != nil {
+		return engine.Board{}, err
+	}
+	mark, err := validateMark(params.Mark)
+	if err != nil {
+		return engine.Board{}, err
+	}
+	if over, winner := board.Winner(); over {
+		if winner == engine.Empty {
+			return engine.Board{}, invalid("the game is already drawn")
+		}
+		return engine.Board{}, invalid(fmt.Sprintf("the game is already won by %s", winner))
+	}
+	// Either mark may have started, so only a mark a move behind the other
+	// is known to be on turn.
+	if ahead := board.Count(mark) - board.Count(mark.Opponent()); ahead > 0 {
+		return engine.Board{}, invalid(fmt.Sprintf("it is not %s's turn: %s has %d more piece(s) than %s", mark, mark, ahead, mark.Opponent()))
+	}
+	return board, nil
+}
+
+// validateComplete checks that params describe a legal position and a
+// player of the game.
+func validateComplete(params models.Complete) (engine.Board, error) {
+	board, err := validateBoard(params.Width, params.Height, params.WinLength, params.GameState)
+	if err != nil {
+		return engine.Board{}, err
+	}
+	if _, err := validateMark(params.Mark); err != nil {
+		return engine.Board{}, err
+	}
+	return board, nil
+}
+
+// maxBoardSide is the widest and tallest board the bot plays. Larger
+// boards, which a request body has room for, take too long to parse and
+// search for a move budget to mean much.
+const maxBoardSide = 32
+
+// validateBoard checks that gameState is a board of the given variant that
+// could arise in play, with the players' piece counts differing by at most
+// one and at most one of them having a line.
+func validateBoard(width, height, winLength int, gameState []string) (engine.Board, error) {
+	// Zero is an omitted dimension, which takes its default.
+	if width < 0 || height < 0 || winLength < 0 {
+		return engine.Board{}, invalid(fmt.Sprintf("board dimensions %dx%d (%d in a row) must be positive", width, height, winLength))
+	}
+	if width > maxBoardSide || height > maxBoardSide {
+		return engine.Board{}, invalid(fmt.Sprintf("board %dx%d is larger than %dx%d", width, height, maxBoardSide, maxBoardSide))
+	}
+	board, err := boardSize(width, height, winLength).ParseStrings(gameState)
+	if err != nil {
+		return engine.Board{}, rpc.InvalidParams(err)
+	}
+	x, o := board.Count(engine.X), board.Count(engine.O)
+	if x-o > 1 || o-x > 1 {
+		return engine.Board{}, invalid(fmt.Sprintf("impossible position: X has %d pieces and O has %d", x, o))
+	}
+	if board.HasLine(engine.X) && board.HasLine(engine.O) {
+		return engine.Board{}, invalid("impossible position: both X and O have a line")
+	}
+	return board, nil
+}
+
+// validateMark checks that mark names a player.
+func validateMark(mark string) (engine.Mark, error) {
+	switch m := engine.Mark(mark); m {
+	case engine.X, engine.O:
+		return m, nil
+	}
+	return engine.Empty, invalid(fmt.Sprintf("mark %q must be \"X\" or \"O\"", mark))
+}
+
+func invalid(detail string) error {
+	return rpc.InvalidParams(errors.New(detail))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/purnet/TicTacToeBot/models"
+)
+
+func TestValidateNextMove(t *testing.T) {
+	tests := []struct {
+		name   string
+		params models.NextMoveParams
+		reason string
+	}{
+		{"empty board", models.NextMoveParams{Mark: "X", GameState: []string{"", "", "", "", "", "", "", "", ""}}, ""},
+		{"O to move", models.NextMoveParams{Mark: "O", GameState: []string{"X", "", "", "", "", "", "", "", ""}}, ""},
+		{"O started", models.NextMoveParams{Mark: "X", GameState: []string{"O", "", "", "", "", "", "", "", ""}}, ""},
+		{"4x4", models.NextMoveParams{Mark: "X", Width: 4, Height: 4, GameState: make([]string, 16)}, ""},
+		{"too short", models.NextMoveParams{Mark: "X", GameState: []string{"", "", ""}}, "needs 9 squares"},
+		{"too long", models.NextMoveParams{Mark: "X", GameState: make([]string, 10)}, "needs 9 squares"},
+		{"missing board", models.NextMoveParams{Mark: "X"}, "needs 9 squares"},
+		{"lower case mark", models.NextMoveParams{Mark: "O", GameState: []string{"x", "", "", "", "", "", "", "", ""}}, `square 0 holds "x"`},
+		{"unknown symbol", models.NextMoveParams{Mark: "O", GameState: []string{"", "", "", "", "Z", "", "", "", ""}}, `square 4 holds "Z"`},
+		{"unknown player", models.NextMoveParams{Mark: "Z", GameState: []string{"", "", "", "", "", "", "", "", ""}}, `mark "Z"`},
+		{"missing player", models.NextMoveParams{GameState: []string{"", "", "", "", "", "", "", "", ""}}, `mark ""`},
+		{"too many X", models.NextMoveParams{Mark: "O", GameState: []string{"X", "X", "", "", "", "", "", "", ""}}, "impossible position"},
+		{"wrong turn", models.NextMoveParams{Mark: "X", GameState: []string{"X", "", "", "", "", "", "", "", ""}}, "not X's turn"},
+		{"won", models.NextMoveParams{Mark: "O", GameState: []string{"X", "X", "X", "O", "O", "", "", "", ""}}, "already won by X"},
+		{"full", models.NextMoveParams{Mark: "X", GameState: []string{"X", "O", "X", "X", "O", "O", "O", "X", "X"}}, "already drawn"},
+		{"bad size", models.NextMoveParams{Mark: "X", Width: 3, Height: 3, WinLength: 4, GameState: make([]string, 9)}, "does not fit"},
+		{"largest", models.NextMoveParams{Mark: "X", Width: 32, Height: 32, WinLength: 5, GameState: make([]string, 32*32)}, ""},
+		{"negative width", models.NextMoveParams{Mark: "X", Width: -3, GameState: make([]string, 9)}, "must be positive"},
+		{"negative height", models.NextMoveParams{Mark: "X", Width: 3, Height: -1, GameState: make([]string, 9)}, "must be positive"},
+		{"negative win length", models.NextMoveParams{Mark: "X", WinLength: -3, GameState: make([]string, 9)}, "must be positive"},
+		{"too wide", models.NextMoveParams{Mark: "X", Width: 500, Height: 3, GameState: make([]string, 1500)}, "larger than 32x32"},
+		{"too tall", models.NextMoveParams{Mark: "X", Width: 3, Height: 33, GameState: make([]string, 99)}, "larger than 32x32"},
+		{"two winners", models.NextMoveParams{Mark: "X", GameState: []string{"X", "X", "X", "O", "O", "O", "", "", ""}}, "both X and O have a line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validateNextMove(tt.params)
+			checkInvalidParams(t, err, tt.reason)
+		})
+	}
+}
+
+func TestValidateComplete(t *testing.T) {
+	tests := []struct {
+		name   string
+		params models.Complete
+		reason string
+	}{
+		{"won", models.Complete{Mark: "X", Winner: true, GameState: []string{"X", "X", "X", "O", "O", "", "", "", ""}}, ""},
+		{"drawn", models.Complete{Mark: "O", GameState: []string{"X", "O", "X", "X", "O", "O", "O", "X", "X"}}, ""},
+		{"too short", models.Complete{Mark: "X", GameState: []string{"X"}}, "needs 9 squares"},
+		{"unknown symbol", models.Complete{Mark: "X", GameState: []string{"X", "X", "X", "o", "O", "", "", "", ""}}, `square 3 holds "o"`},
+		{"impossible", models.Complete{Mark: "X", GameState: []string{"X", "X", "X", "", "", "", "", "", ""}}, "impossible position"},
+		{"two winners", models.Complete{Mark: "O", GameState: []string{"X", "X", "X", "", "", "", "O", "O", "O"}}, "both X and O have a line"},
+		{"negative size", models.Complete{Mark: "X", Width: -4, Height: -4, GameState: make([]string, 9)}, "must be positive"},
+		{"too large", models.Complete{Mark: "X", Width: 100, Height: 100, GameState: make([]string, 10000)}, "larger than 32x32"},
+		{"unknown player", models.Complete{Mark: "x", GameState: []string{"X", "X", "X", "O", "O", "", "", "", ""}}, `mark "x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validateComplete(tt.params)
+			checkInvalidParams(t, err, tt.reason)
+		})
+	}
+}
+
+// checkInvalidParams checks that err is nil when reason is empty, and
+// otherwise an invalid params error mentioning reason.
+func checkInvalidParams(t *testing.T, err error, reason string) {
+	t.Helper()
+	if reason == "" {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	var rpcErr *models.RpcError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != models.InvalidParams {
+		t.Fatalf("error = %v, expected invalid params", err)
+	}
+	if !strings.Contains(err.Error(), reason) {
+		t.Errorf("error = %q, expected it to mention %q", err, reason)
+	}
+}
+
+func TestServeHTTP_InvalidParams(t *testing.T) {
+	rr := serve(NewTicTacToeBot(), `{"jsonrpc":"2.0","method":"TicTacToe.NextMove","params":{"gameid":1,"mark":"X","gamestate":["X","",""]},"id":1}`)
+	resp := decodeResponse(t, rr.Body.Bytes())
+	if resp.Error == nil || resp.Error.Code != models.InvalidParams {
+		t.Errorf("NextMove with a short board = %s, expected invalid params", rr.Body)
+	}
+
+	rr = serve(NewTicTacToeBot(), `{"method":"TicTacToe.NextMove","params":{"gameid":1,"mark":"X","gamestate":["","","","","","","","",""]},"id":2}`)
+	if !strings.Contains(rr.Body.String(), `"position":`) {
+		t.Errorf("NextMove on a valid board = %s, expected a position", rr.Body)
+	}
+}